@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
-	"html/template"
+	"io/fs"
 	"log/slog"
 	"net"
 	"net/http"
@@ -16,12 +16,18 @@ import (
 
 	"github.com/rohitxdev/go-api/assets"
 	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/activity"
 	"github.com/rohitxdev/go-api/deps/cache"
 	"github.com/rohitxdev/go-api/deps/config"
 	"github.com/rohitxdev/go-api/deps/email"
+	"github.com/rohitxdev/go-api/deps/identity"
+	"github.com/rohitxdev/go-api/deps/lockout"
 	"github.com/rohitxdev/go-api/deps/postgres"
+	"github.com/rohitxdev/go-api/deps/ratelimit"
 	"github.com/rohitxdev/go-api/deps/redis"
+	"github.com/rohitxdev/go-api/deps/usage"
 	"github.com/rohitxdev/go-api/handler"
+	"github.com/rohitxdev/go-api/util"
 )
 
 func run() error {
@@ -50,16 +56,6 @@ func run() error {
 		level.Set(slog.LevelDebug)
 	}
 
-	// Email
-	templates, err := template.ParseFS(assets.FS, "templates/emails/*.tmpl")
-	if err != nil {
-		return fmt.Errorf("failed to parse email templates: %w", err)
-	}
-	ec, err := email.New(&email.SMTPCredentials{}, templates)
-	if err != nil {
-		return fmt.Errorf("failed to initialize email client: %w", err)
-	}
-
 	// Cache
 	cache, err := cache.New[string](time.Hour * 12)
 	if err != nil {
@@ -74,7 +70,65 @@ func run() error {
 	}
 	defer pg.Close()
 	logger.Info("connected to postgres server")
-	repo := repository.New(pg)
+	repo := repository.NewStore(pg)
+
+	// Email
+	templatesFS, err := fs.Sub(assets.FS, "templates/emails")
+	if err != nil {
+		return fmt.Errorf("failed to open email templates directory: %w", err)
+	}
+
+	var transport email.Transport
+	switch cfg.EmailTransport {
+	case email.TransportFile:
+		transport, err = email.NewFileTransport(path.Join(cfg.TmpDir, "emails"))
+	case email.TransportMemory:
+		transport = email.NewMemoryTransport(100)
+	default:
+		transport, err = email.NewSMTPTransport(&email.SMTPCredentials{
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			TLSMode:  cfg.SMTPTLSMode,
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to initialize email transport: %w", err)
+	}
+
+	if cfg.EmailOutboxEnabled {
+		outbox := email.NewOutbox(repo, transport, logger, nil)
+		outboxCtx, stopOutbox := context.WithCancel(ctx)
+		outboxDone := make(chan struct{})
+		go func() {
+			outbox.Run(outboxCtx)
+			close(outboxDone)
+		}()
+		// Let the in-flight batch finish before the postgres pool is closed.
+		defer func() {
+			stopOutbox()
+			<-outboxDone
+		}()
+		transport = outbox
+	}
+
+	ec, err := email.New(
+		transport,
+		&email.Sender{
+			Address: cfg.SMTPFromAddress,
+			Name:    util.Coalesce(cfg.SMTPFromName, cfg.AppName),
+		},
+		templatesFS,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to initialize email client: %w", err)
+	}
+	// Inspected through '/dev/emails/sent'.
+	if cfg.AppEnv == config.EnvDevelopment {
+		ec.CaptureRecent(100)
+	}
+	logger.Info("initialized email client", slog.String("transport", cfg.EmailTransport), slog.Bool("outbox", cfg.EmailOutboxEnabled))
 
 	// Redis
 	rdb, err := redis.New(ctx, cfg.RedisURL, cfg.AppName)
@@ -84,13 +138,46 @@ func run() error {
 	defer rdb.Close()
 	logger.Info("connected to redis server")
 
+	// Session activity
+	sessionTracker := activity.NewTracker(rdb, repo, configStore, logger, nil)
+	trackerCtx, stopTracker := context.WithCancel(ctx)
+	trackerDone := make(chan struct{})
+	go func() {
+		sessionTracker.Run(trackerCtx)
+		close(trackerDone)
+	}()
+	// Flush buffered activity before redis & postgres are closed.
+	defer func() {
+		stopTracker()
+		<-trackerDone
+	}()
+
+	// Usage metering
+	usageMeter := usage.NewMeter(rdb, repo, logger, nil)
+	meterCtx, stopMeter := context.WithCancel(ctx)
+	meterDone := make(chan struct{})
+	go func() {
+		usageMeter.Run(meterCtx)
+		close(meterDone)
+	}()
+	// Flush buffered usage before redis & postgres are closed.
+	defer func() {
+		stopMeter()
+		<-meterDone
+	}()
+
 	deps := handler.Dependencies{
-		Config: configStore,
-		Cache:  cache,
-		Redis:  rdb,
-		Repo:   repo,
-		Logger: logger,
-		Email:  ec,
+		Config:         configStore,
+		Cache:          cache,
+		RateLimiter:    ratelimit.New(rdb, logger),
+		Redis:          rdb,
+		Repo:           repo,
+		Logger:         logger,
+		OTPGuard:       lockout.NewGuard(rdb, nil),
+		Email:          ec,
+		OIDCProviders:  identity.NewProviders(),
+		SessionTracker: sessionTracker,
+		Usage:          usageMeter,
 	}
 
 	listener, err := net.Listen("tcp", net.JoinHostPort(cfg.HTTPHost, cfg.HTTPPort))
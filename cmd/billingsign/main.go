@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rohitxdev/go-api/deps/billing"
+)
+
+var ErrSecretMissing = errors.New("-secret is missing")
+
+// Prints the billing webhook signature header of a payload file, so that fixtures can be sent to a local server, e.g.
+//
+//	curl -k https://localhost:8443/webhooks/billing -H "$(go run cmd/billingsign/main.go -secret=... -file=cmd/billingsign/fixtures/subscription.created.json)" --data-binary @cmd/billingsign/fixtures/subscription.created.json
+func main() {
+	secret := flag.String("secret", os.Getenv("BILLING_WEBHOOK_SECRET"), "billing webhook secret, defaults to $BILLING_WEBHOOK_SECRET")
+	file := flag.String("file", "", "path of the payload to sign, defaults to stdin")
+	flag.Parse()
+
+	if *secret == "" {
+		panic(ErrSecretMissing)
+	}
+
+	var (
+		payload []byte
+		err     error
+	)
+	if *file == "" {
+		payload, err = io.ReadAll(os.Stdin)
+	} else {
+		// #nosec G304: the path is provided by the developer running the command
+		payload, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		panic(fmt.Errorf("failed to read payload: %w", err))
+	}
+
+	fmt.Printf("%s: %s\n", billing.HeaderSignature, billing.Sign(payload, *secret, time.Now()))
+}
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE email_outbox (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'sending', 'sent', 'failed')),
+    attempts INT DEFAULT 0 NOT NULL,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    sent_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_email_outbox_timestamps ON email_outbox;
+
+CREATE TRIGGER enforce_email_outbox_timestamps
+BEFORE UPDATE ON email_outbox
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_next_attempt_at ON email_outbox(next_attempt_at)
+WHERE status IN ('pending', 'sending');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_email_outbox_next_attempt_at;
+
+DROP TRIGGER IF EXISTS enforce_email_outbox_timestamps ON email_outbox;
+
+DROP TABLE email_outbox;
+-- +goose StatementEnd
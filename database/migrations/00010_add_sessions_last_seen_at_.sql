@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE sessions DROP COLUMN IF EXISTS last_seen_at;
+-- +goose StatementEnd
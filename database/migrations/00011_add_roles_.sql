@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_accounts ADD COLUMN role CITEXT NOT NULL DEFAULT 'member'
+    CHECK (role IN ('owner', 'admin', 'member', 'viewer'));
+
+-- Global role, independent of account memberships.
+ALTER TABLE users ADD COLUMN role CITEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'staff'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+ALTER TABLE user_accounts DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
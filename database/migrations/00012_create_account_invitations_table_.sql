@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE account_invitations (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    email CITEXT NOT NULL,
+    role CITEXT NOT NULL DEFAULT 'member'
+        CHECK (role IN ('admin', 'member', 'viewer')),
+    -- SHA-256 of the token sent in the invitation email.
+    token_hash TEXT NOT NULL UNIQUE,
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'accepted', 'declined', 'revoked')),
+    expires_at TIMESTAMPTZ NOT NULL,
+    responded_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_account_invitation_timestamps ON account_invitations;
+
+CREATE TRIGGER enforce_account_invitation_timestamps
+BEFORE UPDATE ON account_invitations
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+-- An email can only have one open invitation per account.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_invitations_pending_email ON account_invitations(account_id, email)
+WHERE status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_account_invitations_pending_email;
+
+DROP TRIGGER IF EXISTS enforce_account_invitation_timestamps ON account_invitations;
+
+DROP TABLE account_invitations;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE billing_events (
+    -- ID assigned by the billing provider, so that redelivered events are only applied once.
+    id TEXT PRIMARY KEY
+        CHECK (char_length(id) BETWEEN 1 AND 255),
+    type TEXT NOT NULL
+        CHECK (char_length(type) BETWEEN 1 AND 255),
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_billing_event_timestamps ON billing_events;
+
+CREATE TRIGGER enforce_billing_event_timestamps
+BEFORE UPDATE ON billing_events
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS enforce_billing_event_timestamps ON billing_events;
+
+DROP TABLE billing_events;
+-- +goose StatementEnd
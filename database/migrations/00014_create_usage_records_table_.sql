@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE usage_records (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    account_id UUID NOT NULL REFERENCES accounts(id) ON DELETE CASCADE,
+    metric TEXT NOT NULL
+        CHECK (char_length(metric) BETWEEN 1 AND 64),
+    -- Start of the calendar month (UTC) the usage belongs to.
+    period_start TIMESTAMPTZ NOT NULL,
+    quantity BIGINT DEFAULT 0 NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (account_id, metric, period_start)
+);
+
+DROP TRIGGER IF EXISTS enforce_usage_record_timestamps ON usage_records;
+
+CREATE TRIGGER enforce_usage_record_timestamps
+BEFORE UPDATE ON usage_records
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS enforce_usage_record_timestamps ON usage_records;
+
+DROP TABLE usage_records;
+-- +goose StatementEnd
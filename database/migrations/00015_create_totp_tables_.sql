@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE totp_credentials (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    -- Encrypted with ENCRYPTION_KEY.
+    secret_encrypted BYTEA NOT NULL,
+    -- NULL until the user has entered a first code, which is when 2FA takes effect.
+    confirmed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_totp_credential_timestamps ON totp_credentials;
+
+CREATE TRIGGER enforce_totp_credential_timestamps
+BEFORE UPDATE ON totp_credentials
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+CREATE TABLE recovery_codes (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash BYTEA NOT NULL
+        CHECK (octet_length(code_hash) BETWEEN 8 AND 512),
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_recovery_code_timestamps ON recovery_codes;
+
+CREATE TRIGGER enforce_recovery_code_timestamps
+BEFORE UPDATE ON recovery_codes
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_recovery_codes_user_id;
+
+DROP TRIGGER IF EXISTS enforce_recovery_code_timestamps ON recovery_codes;
+
+DROP TABLE recovery_codes;
+
+DROP TRIGGER IF EXISTS enforce_totp_credential_timestamps ON totp_credentials;
+
+DROP TABLE totp_credentials;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE webauthn_credentials (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    credential_id BYTEA NOT NULL UNIQUE,
+    name TEXT NOT NULL
+        CHECK (char_length(name) BETWEEN 1 AND 64),
+    -- The credential record as serialized by the WebAuthn library, including its public key & sign count.
+    credential JSONB NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_webauthn_credential_timestamps ON webauthn_credentials;
+
+CREATE TRIGGER enforce_webauthn_credential_timestamps
+BEFORE UPDATE ON webauthn_credentials
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+CREATE INDEX IF NOT EXISTS idx_webauthn_credentials_user_id ON webauthn_credentials(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_webauthn_credentials_user_id;
+
+DROP TRIGGER IF EXISTS enforce_webauthn_credential_timestamps ON webauthn_credentials;
+
+DROP TABLE webauthn_credentials;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_identities (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    -- Key of the provider in the config, e.g. 'google'.
+    provider TEXT NOT NULL,
+    -- The 'sub' claim, which identifies the user at the provider.
+    subject TEXT NOT NULL,
+    email CITEXT,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    UNIQUE (provider, subject)
+);
+
+DROP TRIGGER IF EXISTS enforce_user_identity_timestamps ON user_identities;
+
+CREATE TRIGGER enforce_user_identity_timestamps
+BEFORE UPDATE ON user_identities
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+
+DROP TRIGGER IF EXISTS enforce_user_identity_timestamps ON user_identities;
+
+DROP TABLE user_identities;
+-- +goose StatementEnd
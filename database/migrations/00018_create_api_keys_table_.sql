@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE api_keys (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL
+        CHECK (char_length(name) BETWEEN 1 AND 64),
+    -- Public part of the key, which it is looked up by.
+    prefix TEXT NOT NULL UNIQUE,
+    -- Salted Argon2 hash of the secret part of the key.
+    key_hash BYTEA NOT NULL,
+    scopes TEXT[] NOT NULL,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_api_key_timestamps ON api_keys;
+
+CREATE TRIGGER enforce_api_key_timestamps
+BEFORE UPDATE ON api_keys
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_api_keys_user_id;
+
+DROP TRIGGER IF EXISTS enforce_api_key_timestamps ON api_keys;
+
+DROP TABLE api_keys;
+-- +goose StatementEnd
@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE refresh_tokens (
+    id UUID DEFAULT uuidv7() PRIMARY KEY,
+    -- Every token rotated from the same grant shares the family of the first one, so that they can be revoked together.
+    family_id UUID NOT NULL,
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    -- SHA-256 of the token.
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    -- Set when the token is rotated. Using it again means it has leaked.
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+DROP TRIGGER IF EXISTS enforce_refresh_token_timestamps ON refresh_tokens;
+
+CREATE TRIGGER enforce_refresh_token_timestamps
+BEFORE UPDATE ON refresh_tokens
+FOR EACH ROW
+EXECUTE PROCEDURE enforce_timestamps();
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family_id ON refresh_tokens(family_id);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens(session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_refresh_tokens_session_id;
+DROP INDEX IF EXISTS idx_refresh_tokens_family_id;
+
+DROP TRIGGER IF EXISTS enforce_refresh_token_timestamps ON refresh_tokens;
+
+DROP TABLE refresh_tokens;
+-- +goose StatementEnd
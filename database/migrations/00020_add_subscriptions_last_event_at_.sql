@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Time the billing provider created the last applied event at, so that events delivered out of order can't undo newer ones.
+ALTER TABLE subscriptions ADD COLUMN last_event_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS last_event_at;
+-- +goose StatementEnd
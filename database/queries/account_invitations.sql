@@ -0,0 +1,48 @@
+-- name: CreateAccountInvitation :one
+INSERT INTO account_invitations (account_id, email, role, token_hash, invited_by, expires_at)
+VALUES (@account_id, @email, @role, @token_hash, @invited_by, @expires_at)
+RETURNING *;
+
+-- name: ListAccountInvitations :many
+SELECT * FROM account_invitations
+WHERE account_id = @account_id
+AND status = 'pending'
+ORDER BY created_at DESC;
+
+-- name: CountPendingAccountInvitations :one
+-- Expired invitations can't be accepted, so they don't count.
+SELECT COUNT(*) FROM account_invitations
+WHERE account_id = @account_id
+AND status = 'pending'
+AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: GetPendingAccountInvitationByTokenHash :one
+SELECT * FROM account_invitations
+WHERE token_hash = @token_hash
+AND status = 'pending'
+AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: RenewAccountInvitation :one
+-- Replaces the token, so that links in previous emails stop working.
+UPDATE account_invitations
+SET token_hash = @token_hash,
+    expires_at = @expires_at
+WHERE id = @id
+AND account_id = @account_id
+AND status = 'pending'
+RETURNING *;
+
+-- name: RespondToAccountInvitation :execrows
+UPDATE account_invitations
+SET status = @status,
+    responded_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND status = 'pending';
+
+-- name: RevokeAccountInvitation :execrows
+UPDATE account_invitations
+SET status = 'revoked',
+    responded_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND account_id = @account_id
+AND status = 'pending';
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- name: CreateAccount :one
+INSERT INTO accounts (name)
+VALUES (@name)
+RETURNING *;
+
+-- name: GetAccount :one
+SELECT * FROM accounts
+WHERE id = @id;
+
+-- name: LockAccount :one
+-- Locks the account until the end of the transaction, so that changes to its seats are made one at a time.
+SELECT * FROM accounts
+WHERE id = @id
+FOR UPDATE;
+
+-- name: UpdateAccountName :one
+UPDATE accounts
+SET name = @name
+WHERE id = @id
+RETURNING *;
+
+-- name: DeleteAccount :execrows
+DELETE FROM accounts
+WHERE id = @id;
\ No newline at end of file
@@ -0,0 +1,34 @@
+-- name: CreateApiKey :one
+INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+VALUES (@user_id, @name, @prefix, @key_hash, @scopes)
+RETURNING *;
+
+-- name: GetApiKeyByPrefix :one
+SELECT * FROM api_keys
+WHERE prefix = @prefix
+AND revoked_at IS NULL;
+
+-- name: GetUserByApiKeyId :one
+SELECT u.* FROM users AS u
+JOIN api_keys AS k ON u.id = k.user_id
+WHERE k.id = @api_key_id;
+
+-- name: ListApiKeys :many
+SELECT * FROM api_keys
+WHERE user_id = @user_id
+AND revoked_at IS NULL
+ORDER BY created_at;
+
+-- name: RevokeApiKey :execrows
+UPDATE api_keys
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND user_id = @user_id
+AND revoked_at IS NULL;
+
+-- name: TouchApiKey :exec
+-- Updates the last use at most once a minute, so that busy keys don't write on every request.
+UPDATE api_keys
+SET last_used_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute');
\ No newline at end of file
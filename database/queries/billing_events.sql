@@ -0,0 +1,5 @@
+-- name: CreateBillingEvent :execrows
+-- Returns 0 if the event has already been received.
+INSERT INTO billing_events (id, type, payload)
+VALUES (@id, @type, @payload)
+ON CONFLICT (id) DO NOTHING;
\ No newline at end of file
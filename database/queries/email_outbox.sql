@@ -0,0 +1,54 @@
+-- name: DeleteSentEmails :execrows
+DELETE FROM email_outbox
+WHERE status = 'sent'
+AND sent_at < @sent_before;
+
+-- name: EnqueueEmail :one
+INSERT INTO email_outbox (payload)
+VALUES (@payload)
+RETURNING id;
+
+-- name: ClaimEmails :many
+-- Rows stuck in 'sending' (e.g. after a crash) are reclaimed once their lease passes.
+UPDATE email_outbox
+SET status = 'sending',
+    next_attempt_at = @locked_until
+WHERE id IN (
+    SELECT id FROM email_outbox
+    WHERE status IN ('pending', 'sending')
+    AND next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY next_attempt_at
+    LIMIT @batch_size
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkEmailSent :exec
+-- The body is dropped, as it may contain secrets like OTPs & sign-in links.
+UPDATE email_outbox
+SET status = 'sent',
+    payload = payload - 'TextBody' - 'HTMLBody' - 'Attachments',
+    sent_at = CURRENT_TIMESTAMP,
+    last_error = NULL
+WHERE id = @id;
+
+-- name: RescheduleEmail :exec
+UPDATE email_outbox
+SET status = 'pending',
+    attempts = @attempts,
+    last_error = @last_error,
+    next_attempt_at = @next_attempt_at
+WHERE id = @id;
+
+-- name: MarkEmailFailed :exec
+UPDATE email_outbox
+SET status = 'failed',
+    attempts = @attempts,
+    last_error = @last_error
+WHERE id = @id;
+
+-- name: ListFailedEmails :many
+SELECT * FROM email_outbox
+WHERE status = 'failed'
+ORDER BY updated_at DESC
+LIMIT @max_count;
@@ -10,11 +10,24 @@ LIMIT 1;
 INSERT INTO otps (user_id, code_hash, expires_at)
 VALUES (@user_id, @code_hash, @expires_at);
 
--- name: IncrementOtpAttempts :exec
+-- name: IncrementOtpAttempts :one
 UPDATE otps
 SET attempts = attempts + 1
-WHERE user_id = @user_id;
+WHERE id = @id
+RETURNING attempts;
 
--- name: DeleteOtp :exec
-DELETE FROM otps
-WHERE id = @id;
\ No newline at end of file
+-- name: ConsumeOtp :execrows
+-- Marks the OTP as used, unless it has been used or invalidated already.
+UPDATE otps
+SET consumed_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND consumed_at IS NULL
+AND expires_at > CURRENT_TIMESTAMP;
+
+-- name: ExpireOtps :exec
+-- Invalidates the outstanding OTPs of the user.
+UPDATE otps
+SET expires_at = CURRENT_TIMESTAMP
+WHERE user_id = @user_id
+AND consumed_at IS NULL
+AND expires_at > CURRENT_TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- name: CreateRecoveryCodes :exec
+INSERT INTO recovery_codes (user_id, code_hash)
+SELECT @user_id::uuid, v.code_hash
+FROM unnest(@code_hashes::bytea[]) AS v(code_hash);
+
+-- name: ListUnusedRecoveryCodes :many
+SELECT * FROM recovery_codes
+WHERE user_id = @user_id
+AND used_at IS NULL
+ORDER BY created_at;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = @user_id;
\ No newline at end of file
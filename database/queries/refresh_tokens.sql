@@ -0,0 +1,24 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (family_id, session_id, token_hash, scopes, expires_at)
+VALUES (@family_id, @session_id, @token_hash, @scopes, @expires_at)
+RETURNING *;
+
+-- name: GetRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = @token_hash;
+
+-- name: UseRefreshToken :one
+-- Marks the token as rotated. Returns no rows if it was already rotated, or has been revoked or has expired.
+UPDATE refresh_tokens
+SET used_at = CURRENT_TIMESTAMP
+WHERE token_hash = @token_hash
+AND used_at IS NULL
+AND revoked_at IS NULL
+AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE family_id = @family_id
+AND revoked_at IS NULL;
\ No newline at end of file
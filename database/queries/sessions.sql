@@ -1,9 +1,48 @@
 -- name: GetUserBySessionId :one
 SELECT u.* FROM users AS u
 JOIN sessions AS s ON u.id = s.user_id
-WHERE s.id = @session_id;
+WHERE s.id = @session_id
+AND s.revoked_at IS NULL
+AND s.expires_at > CURRENT_TIMESTAMP;
 
 -- name: CreateSession :one
 INSERT INTO sessions(user_id,user_agent,ip_address,expires_at)
 VALUES (@user_id,@user_agent,@ip_address,@expires_at)
-RETURNING id;
\ No newline at end of file
+RETURNING id;
+
+-- name: RevokeSession :exec
+UPDATE sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND revoked_at IS NULL;
+
+-- name: ListUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = @user_id
+AND revoked_at IS NULL
+AND expires_at > CURRENT_TIMESTAMP
+ORDER BY created_at DESC;
+
+-- name: RevokeUserSession :execrows
+UPDATE sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = @id
+AND user_id = @user_id
+AND revoked_at IS NULL;
+
+-- name: RevokeOtherUserSessions :execrows
+UPDATE sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = @user_id
+AND id <> @current_session_id
+AND revoked_at IS NULL;
+
+-- name: TouchSessions :exec
+-- Slides the expiry of each session to the idle timeout from when it was last seen, capped at its absolute lifetime.
+UPDATE sessions AS s
+SET last_seen_at = v.seen_at,
+    expires_at = LEAST(v.seen_at + @idle_timeout::interval, s.created_at + @max_lifetime::interval)
+FROM unnest(@ids::uuid[], @seen_ats::timestamptz[]) AS v(id, seen_at)
+WHERE s.id = v.id
+AND s.revoked_at IS NULL
+AND v.seen_at > s.last_seen_at;
\ No newline at end of file
@@ -1,2 +1,15 @@
 -- name: GetSubscriptionByAccountID :one
-SELECT * FROM subscriptions WHERE account_id = @account_id;
\ No newline at end of file
+SELECT * FROM subscriptions WHERE account_id = @account_id;
+
+-- name: UpsertSubscription :execrows
+-- Returns 0 if the subscription has already been updated by a newer event, which is then ignored.
+INSERT INTO subscriptions (account_id, plan_id, status, starts_at, ends_at, last_event_at)
+VALUES (@account_id, @plan_id, @status, @starts_at, @ends_at, @last_event_at)
+ON CONFLICT (account_id) DO UPDATE
+SET plan_id = @plan_id,
+    status = @status,
+    starts_at = @starts_at,
+    ends_at = @ends_at,
+    last_event_at = @last_event_at
+WHERE subscriptions.last_event_at IS NULL
+OR subscriptions.last_event_at <= @last_event_at;
\ No newline at end of file
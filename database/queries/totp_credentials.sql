@@ -0,0 +1,22 @@
+-- name: GetTotpCredential :one
+SELECT * FROM totp_credentials
+WHERE user_id = @user_id;
+
+-- name: UpsertTotpCredential :one
+-- Replaces the secret of an unconfirmed credential. Returns no rows if the credential is confirmed.
+INSERT INTO totp_credentials (user_id, secret_encrypted)
+VALUES (@user_id, @secret_encrypted)
+ON CONFLICT (user_id) DO UPDATE
+SET secret_encrypted = EXCLUDED.secret_encrypted
+WHERE totp_credentials.confirmed_at IS NULL
+RETURNING *;
+
+-- name: ConfirmTotpCredential :execrows
+UPDATE totp_credentials
+SET confirmed_at = CURRENT_TIMESTAMP
+WHERE user_id = @user_id
+AND confirmed_at IS NULL;
+
+-- name: DeleteTotpCredential :execrows
+DELETE FROM totp_credentials
+WHERE user_id = @user_id;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- name: AddUsageRecords :exec
+-- Adds the quantities to the records of each account, metric & period. Accounts deleted since the usage was buffered are skipped.
+INSERT INTO usage_records (account_id, metric, period_start, quantity)
+SELECT v.account_id, v.metric, v.period_start, v.quantity
+FROM unnest(@account_ids::uuid[], @metrics::text[], @period_starts::timestamptz[], @quantities::bigint[]) AS v(account_id, metric, period_start, quantity)
+JOIN accounts AS a ON a.id = v.account_id
+ON CONFLICT (account_id, metric, period_start) DO UPDATE
+SET quantity = usage_records.quantity + EXCLUDED.quantity;
+
+-- name: GetUsageRecord :one
+SELECT * FROM usage_records
+WHERE account_id = @account_id
+AND metric = @metric
+AND period_start = @period_start;
+
+-- name: ListUsageRecords :many
+SELECT * FROM usage_records
+WHERE account_id = @account_id
+AND period_start = @period_start
+ORDER BY metric;
\ No newline at end of file
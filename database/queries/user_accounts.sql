@@ -1,2 +1,70 @@
 -- name: GetUserAccountsByUserID :many
-SELECT a.* FROM accounts AS a JOIN user_accounts as ua ON ua.account_id = a.id WHERE ua.user_id = @user_id;
\ No newline at end of file
+SELECT a.* FROM accounts AS a JOIN user_accounts as ua ON ua.account_id = a.id WHERE ua.user_id = @user_id;
+
+-- name: GetUserAccount :one
+SELECT * FROM user_accounts
+WHERE user_id = @user_id
+AND account_id = @account_id
+AND status = 'active';
+
+-- name: ListAccountMembers :many
+SELECT ua.*, u.email FROM user_accounts AS ua
+JOIN users AS u ON u.id = ua.user_id
+WHERE ua.account_id = @account_id
+ORDER BY ua.created_at;
+
+-- name: UpdateUserAccountRole :one
+UPDATE user_accounts
+SET role = @role
+WHERE user_id = @user_id
+AND account_id = @account_id
+RETURNING *;
+
+-- name: CreateUserAccount :one
+INSERT INTO user_accounts (user_id, account_id, role)
+VALUES (@user_id, @account_id, @role)
+RETURNING *;
+
+-- name: ListUserAccounts :many
+-- Active memberships of the user, along with the accounts.
+SELECT a.*, ua.role FROM accounts AS a
+JOIN user_accounts AS ua ON ua.account_id = a.id
+WHERE ua.user_id = @user_id
+AND ua.status = 'active'
+ORDER BY a.created_at;
+
+-- name: DemoteAccountOwners :exec
+UPDATE user_accounts
+SET role = 'admin'
+WHERE account_id = @account_id
+AND role = 'owner';
+
+-- name: DeleteAccountMembers :exec
+DELETE FROM user_accounts
+WHERE account_id = @account_id;
+
+-- name: UpsertUserAccount :one
+-- Re-invited members that were deactivated get their membership back with the new role. Active memberships are left as they are & return no rows.
+INSERT INTO user_accounts (user_id, account_id, role, status)
+VALUES (@user_id, @account_id, @role, @status)
+ON CONFLICT (user_id, account_id) DO UPDATE
+SET role = @role,
+    status = @status
+WHERE user_accounts.status <> 'active'
+RETURNING *;
+
+-- name: ActivateUserAccounts :exec
+-- Activates memberships accepted before the user verified their email.
+UPDATE user_accounts
+SET status = 'active'
+WHERE user_id = @user_id
+AND status = 'pending';
+
+-- name: AccountHasMemberWithEmail :one
+SELECT EXISTS (
+    SELECT 1 FROM user_accounts AS ua
+    JOIN users AS u ON u.id = ua.user_id
+    WHERE ua.account_id = @account_id
+    AND u.email = @email
+    AND ua.status = 'active'
+);
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE provider = @provider
+AND subject = @subject;
+
+-- name: UpsertUserIdentity :one
+-- Links the identity to the user, unless it is already linked to one. The identity is returned either way.
+INSERT INTO user_identities (user_id, provider, subject, email)
+VALUES (@user_id, @provider, @subject, @email)
+ON CONFLICT (provider, subject) DO UPDATE
+SET email = EXCLUDED.email
+RETURNING *;
\ No newline at end of file
@@ -4,7 +4,7 @@ VALUES ($1 , $2)
 RETURNING *;
 
 -- name: GetUserByID :one
-SELECT id, username
+SELECT id, username, email
 FROM users
 WHERE id = $1;
 
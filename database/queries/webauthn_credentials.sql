@@ -0,0 +1,21 @@
+-- name: CreateWebauthnCredential :one
+INSERT INTO webauthn_credentials (user_id, credential_id, name, credential)
+VALUES (@user_id, @credential_id, @name, @credential)
+RETURNING *;
+
+-- name: ListWebauthnCredentials :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = @user_id
+ORDER BY created_at;
+
+-- name: UpdateWebauthnCredentialUsage :exec
+-- Stores the credential record after a sign-in, which updates its sign count.
+UPDATE webauthn_credentials
+SET credential = @credential,
+    last_used_at = CURRENT_TIMESTAMP
+WHERE credential_id = @credential_id;
+
+-- name: DeleteWebauthnCredential :execrows
+DELETE FROM webauthn_credentials
+WHERE id = @id
+AND user_id = @user_id;
\ No newline at end of file
@@ -17,6 +17,53 @@ type Account struct {
 	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
 }
 
+type AccountInvitation struct {
+	ID          pgtype.UUID        `db:"id" json:"id"`
+	AccountID   pgtype.UUID        `db:"account_id" json:"account_id"`
+	Email       string             `db:"email" json:"email"`
+	Role        string             `db:"role" json:"role"`
+	TokenHash   string             `db:"token_hash" json:"token_hash"`
+	InvitedBy   pgtype.UUID        `db:"invited_by" json:"invited_by"`
+	Status      string             `db:"status" json:"status"`
+	ExpiresAt   pgtype.Timestamptz `db:"expires_at" json:"expires_at"`
+	RespondedAt pgtype.Timestamptz `db:"responded_at" json:"responded_at"`
+	CreatedAt   pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
+type ApiKey struct {
+	ID         pgtype.UUID        `db:"id" json:"id"`
+	UserID     pgtype.UUID        `db:"user_id" json:"user_id"`
+	Name       string             `db:"name" json:"name"`
+	Prefix     string             `db:"prefix" json:"prefix"`
+	KeyHash    []byte             `db:"key_hash" json:"key_hash"`
+	Scopes     []string           `db:"scopes" json:"scopes"`
+	LastUsedAt pgtype.Timestamptz `db:"last_used_at" json:"last_used_at"`
+	RevokedAt  pgtype.Timestamptz `db:"revoked_at" json:"revoked_at"`
+	CreatedAt  pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
+type BillingEvent struct {
+	ID        string             `db:"id" json:"id"`
+	Type      string             `db:"type" json:"type"`
+	Payload   []byte             `db:"payload" json:"payload"`
+	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
+type EmailOutbox struct {
+	ID            pgtype.UUID        `db:"id" json:"id"`
+	Payload       []byte             `db:"payload" json:"payload"`
+	Status        string             `db:"status" json:"status"`
+	Attempts      int32              `db:"attempts" json:"attempts"`
+	LastError     *string            `db:"last_error" json:"last_error"`
+	NextAttemptAt pgtype.Timestamptz `db:"next_attempt_at" json:"next_attempt_at"`
+	SentAt        pgtype.Timestamptz `db:"sent_at" json:"sent_at"`
+	CreatedAt     pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
 type Otp struct {
 	ID         pgtype.UUID        `db:"id" json:"id"`
 	UserID     pgtype.UUID        `db:"user_id" json:"user_id"`
@@ -28,27 +75,70 @@ type Otp struct {
 	UpdatedAt  pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
 }
 
-type Session struct {
+type RecoveryCode struct {
 	ID        pgtype.UUID        `db:"id" json:"id"`
 	UserID    pgtype.UUID        `db:"user_id" json:"user_id"`
-	UserAgent *string            `db:"user_agent" json:"user_agent"`
-	IpAddress netip.Addr         `db:"ip_address" json:"ip_address"`
-	ExpiresAt pgtype.Timestamptz `db:"expires_at" json:"expires_at"`
+	CodeHash  []byte             `db:"code_hash" json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `db:"used_at" json:"used_at"`
 	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
 	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
 }
 
-type Subscription struct {
+type RefreshToken struct {
 	ID        pgtype.UUID        `db:"id" json:"id"`
-	AccountID pgtype.UUID        `db:"account_id" json:"account_id"`
-	PlanID    string             `db:"plan_id" json:"plan_id"`
-	Status    string             `db:"status" json:"status"`
-	StartsAt  pgtype.Timestamptz `db:"starts_at" json:"starts_at"`
-	EndsAt    pgtype.Timestamptz `db:"ends_at" json:"ends_at"`
+	FamilyID  pgtype.UUID        `db:"family_id" json:"family_id"`
+	SessionID pgtype.UUID        `db:"session_id" json:"session_id"`
+	TokenHash string             `db:"token_hash" json:"token_hash"`
+	Scopes    []string           `db:"scopes" json:"scopes"`
+	ExpiresAt pgtype.Timestamptz `db:"expires_at" json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `db:"used_at" json:"used_at"`
+	RevokedAt pgtype.Timestamptz `db:"revoked_at" json:"revoked_at"`
 	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
 	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
 }
 
+type Session struct {
+	ID         pgtype.UUID        `db:"id" json:"id"`
+	UserID     pgtype.UUID        `db:"user_id" json:"user_id"`
+	UserAgent  *string            `db:"user_agent" json:"user_agent"`
+	IpAddress  netip.Addr         `db:"ip_address" json:"ip_address"`
+	ExpiresAt  pgtype.Timestamptz `db:"expires_at" json:"expires_at"`
+	CreatedAt  pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt  pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	RevokedAt  pgtype.Timestamptz `db:"revoked_at" json:"revoked_at"`
+	LastSeenAt pgtype.Timestamptz `db:"last_seen_at" json:"last_seen_at"`
+}
+
+type Subscription struct {
+	ID          pgtype.UUID        `db:"id" json:"id"`
+	AccountID   pgtype.UUID        `db:"account_id" json:"account_id"`
+	PlanID      string             `db:"plan_id" json:"plan_id"`
+	Status      string             `db:"status" json:"status"`
+	StartsAt    pgtype.Timestamptz `db:"starts_at" json:"starts_at"`
+	EndsAt      pgtype.Timestamptz `db:"ends_at" json:"ends_at"`
+	CreatedAt   pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	LastEventAt pgtype.Timestamptz `db:"last_event_at" json:"last_event_at"`
+}
+
+type TotpCredential struct {
+	UserID          pgtype.UUID        `db:"user_id" json:"user_id"`
+	SecretEncrypted []byte             `db:"secret_encrypted" json:"secret_encrypted"`
+	ConfirmedAt     pgtype.Timestamptz `db:"confirmed_at" json:"confirmed_at"`
+	CreatedAt       pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
+type UsageRecord struct {
+	ID          pgtype.UUID        `db:"id" json:"id"`
+	AccountID   pgtype.UUID        `db:"account_id" json:"account_id"`
+	Metric      string             `db:"metric" json:"metric"`
+	PeriodStart pgtype.Timestamptz `db:"period_start" json:"period_start"`
+	Quantity    int64              `db:"quantity" json:"quantity"`
+	CreatedAt   pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
 type User struct {
 	ID         pgtype.UUID        `db:"id" json:"id"`
 	Username   *string            `db:"username" json:"username"`
@@ -56,6 +146,7 @@ type User struct {
 	VerifiedAt pgtype.Timestamptz `db:"verified_at" json:"verified_at"`
 	CreatedAt  pgtype.Timestamptz `db:"created_at" json:"created_at"`
 	UpdatedAt  pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	Role       string             `db:"role" json:"role"`
 }
 
 type UserAccount struct {
@@ -64,4 +155,26 @@ type UserAccount struct {
 	Status    string             `db:"status" json:"status"`
 	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
 	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	Role      string             `db:"role" json:"role"`
+}
+
+type UserIdentity struct {
+	ID        pgtype.UUID        `db:"id" json:"id"`
+	UserID    pgtype.UUID        `db:"user_id" json:"user_id"`
+	Provider  string             `db:"provider" json:"provider"`
+	Subject   string             `db:"subject" json:"subject"`
+	Email     *string            `db:"email" json:"email"`
+	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+}
+
+type WebauthnCredential struct {
+	ID           pgtype.UUID        `db:"id" json:"id"`
+	UserID       pgtype.UUID        `db:"user_id" json:"user_id"`
+	CredentialID []byte             `db:"credential_id" json:"credential_id"`
+	Name         string             `db:"name" json:"name"`
+	Credential   []byte             `db:"credential" json:"credential"`
+	LastUsedAt   pgtype.Timestamptz `db:"last_used_at" json:"last_used_at"`
+	CreatedAt    pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt    pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
 }
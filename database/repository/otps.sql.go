@@ -11,6 +11,23 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const consumeOtp = `-- name: ConsumeOtp :execrows
+UPDATE otps
+SET consumed_at = CURRENT_TIMESTAMP
+WHERE id = $1
+AND consumed_at IS NULL
+AND expires_at > CURRENT_TIMESTAMP
+`
+
+// Marks the OTP as used, unless it has been used or invalidated already.
+func (q *Queries) ConsumeOtp(ctx context.Context, id pgtype.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, consumeOtp, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const createOtp = `-- name: CreateOtp :exec
 INSERT INTO otps (user_id, code_hash, expires_at)
 VALUES ($1, $2, $3)
@@ -27,13 +44,17 @@ func (q *Queries) CreateOtp(ctx context.Context, arg CreateOtpParams) error {
 	return err
 }
 
-const deleteOtp = `-- name: DeleteOtp :exec
-DELETE FROM otps
-WHERE id = $1
+const expireOtps = `-- name: ExpireOtps :exec
+UPDATE otps
+SET expires_at = CURRENT_TIMESTAMP
+WHERE user_id = $1
+AND consumed_at IS NULL
+AND expires_at > CURRENT_TIMESTAMP
 `
 
-func (q *Queries) DeleteOtp(ctx context.Context, id pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, deleteOtp, id)
+// Invalidates the outstanding OTPs of the user.
+func (q *Queries) ExpireOtps(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, expireOtps, userID)
 	return err
 }
 
@@ -62,13 +83,16 @@ func (q *Queries) GetOtpByUserId(ctx context.Context, userID pgtype.UUID) (*Otp,
 	return &i, err
 }
 
-const incrementOtpAttempts = `-- name: IncrementOtpAttempts :exec
+const incrementOtpAttempts = `-- name: IncrementOtpAttempts :one
 UPDATE otps
 SET attempts = attempts + 1
-WHERE user_id = $1
+WHERE id = $1
+RETURNING attempts
 `
 
-func (q *Queries) IncrementOtpAttempts(ctx context.Context, userID pgtype.UUID) error {
-	_, err := q.db.Exec(ctx, incrementOtpAttempts, userID)
-	return err
+func (q *Queries) IncrementOtpAttempts(ctx context.Context, id pgtype.UUID) (int32, error) {
+	row := q.db.QueryRow(ctx, incrementOtpAttempts, id)
+	var attempts int32
+	err := row.Scan(&attempts)
+	return attempts, err
 }
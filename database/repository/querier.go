@@ -12,21 +12,104 @@ import (
 )
 
 type Querier interface {
+	AccountHasMemberWithEmail(ctx context.Context, arg AccountHasMemberWithEmailParams) (bool, error)
+	// Activates memberships accepted before the user verified their email.
+	ActivateUserAccounts(ctx context.Context, userID pgtype.UUID) error
+	// Adds the quantities to the records of each account, metric & period. Accounts deleted since the usage was buffered are skipped.
+	AddUsageRecords(ctx context.Context, arg AddUsageRecordsParams) error
+	// Rows stuck in 'sending' (e.g. after a crash) are reclaimed once their lease passes.
+	ClaimEmails(ctx context.Context, arg ClaimEmailsParams) ([]*EmailOutbox, error)
+	ConfirmTotpCredential(ctx context.Context, userID pgtype.UUID) (int64, error)
+	// Marks the OTP as used, unless it has been used or invalidated already.
+	ConsumeOtp(ctx context.Context, id pgtype.UUID) (int64, error)
+	// Expired invitations can't be accepted, so they don't count.
+	CountPendingAccountInvitations(ctx context.Context, accountID pgtype.UUID) (int64, error)
+	CreateAccount(ctx context.Context, name *string) (*Account, error)
+	CreateAccountInvitation(ctx context.Context, arg CreateAccountInvitationParams) (*AccountInvitation, error)
+	CreateApiKey(ctx context.Context, arg CreateApiKeyParams) (*ApiKey, error)
+	// Returns 0 if the event has already been received.
+	CreateBillingEvent(ctx context.Context, arg CreateBillingEventParams) (int64, error)
 	CreateOtp(ctx context.Context, arg CreateOtpParams) error
+	CreateRecoveryCodes(ctx context.Context, arg CreateRecoveryCodesParams) error
+	CreateRefreshToken(ctx context.Context, arg CreateRefreshTokenParams) (*RefreshToken, error)
 	CreateSession(ctx context.Context, arg CreateSessionParams) (pgtype.UUID, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (*User, error)
-	DeleteOtp(ctx context.Context, id pgtype.UUID) error
+	CreateUserAccount(ctx context.Context, arg CreateUserAccountParams) (*UserAccount, error)
+	CreateWebauthnCredential(ctx context.Context, arg CreateWebauthnCredentialParams) (*WebauthnCredential, error)
+	DeleteAccount(ctx context.Context, id pgtype.UUID) (int64, error)
+	DeleteAccountMembers(ctx context.Context, accountID pgtype.UUID) error
+	DeleteRecoveryCodes(ctx context.Context, userID pgtype.UUID) error
+	DeleteSentEmails(ctx context.Context, sentBefore pgtype.Timestamptz) (int64, error)
+	DeleteTotpCredential(ctx context.Context, userID pgtype.UUID) (int64, error)
 	DeleteUser(ctx context.Context, id pgtype.UUID) (pgconn.CommandTag, error)
+	DeleteWebauthnCredential(ctx context.Context, arg DeleteWebauthnCredentialParams) (int64, error)
+	DemoteAccountOwners(ctx context.Context, accountID pgtype.UUID) error
+	EnqueueEmail(ctx context.Context, payload []byte) (pgtype.UUID, error)
+	// Invalidates the outstanding OTPs of the user.
+	ExpireOtps(ctx context.Context, userID pgtype.UUID) error
+	GetAccount(ctx context.Context, id pgtype.UUID) (*Account, error)
+	GetApiKeyByPrefix(ctx context.Context, prefix string) (*ApiKey, error)
 	GetOtpByUserId(ctx context.Context, userID pgtype.UUID) (*Otp, error)
+	GetPendingAccountInvitationByTokenHash(ctx context.Context, tokenHash string) (*AccountInvitation, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
 	GetSubscriptionByAccountID(ctx context.Context, accountID pgtype.UUID) (*Subscription, error)
+	GetTotpCredential(ctx context.Context, userID pgtype.UUID) (*TotpCredential, error)
+	GetUsageRecord(ctx context.Context, arg GetUsageRecordParams) (*UsageRecord, error)
+	GetUserAccount(ctx context.Context, arg GetUserAccountParams) (*UserAccount, error)
 	GetUserAccountsByUserID(ctx context.Context, userID pgtype.UUID) ([]*Account, error)
+	GetUserByApiKeyId(ctx context.Context, apiKeyID pgtype.UUID) (*User, error)
 	GetUserByEmail(ctx context.Context, email string) (*GetUserByEmailRow, error)
 	GetUserByID(ctx context.Context, id pgtype.UUID) (*GetUserByIDRow, error)
 	GetUserBySessionId(ctx context.Context, sessionID pgtype.UUID) (*User, error)
-	IncrementOtpAttempts(ctx context.Context, userID pgtype.UUID) error
+	GetUserIdentity(ctx context.Context, arg GetUserIdentityParams) (*UserIdentity, error)
+	IncrementOtpAttempts(ctx context.Context, id pgtype.UUID) (int32, error)
+	ListAccountInvitations(ctx context.Context, accountID pgtype.UUID) ([]*AccountInvitation, error)
+	ListAccountMembers(ctx context.Context, accountID pgtype.UUID) ([]*ListAccountMembersRow, error)
+	ListApiKeys(ctx context.Context, userID pgtype.UUID) ([]*ApiKey, error)
+	ListFailedEmails(ctx context.Context, maxCount int32) ([]*EmailOutbox, error)
+	ListUnusedRecoveryCodes(ctx context.Context, userID pgtype.UUID) ([]*RecoveryCode, error)
+	ListUsageRecords(ctx context.Context, arg ListUsageRecordsParams) ([]*UsageRecord, error)
+	// Active memberships of the user, along with the accounts.
+	ListUserAccounts(ctx context.Context, userID pgtype.UUID) ([]*ListUserAccountsRow, error)
+	ListUserSessions(ctx context.Context, userID pgtype.UUID) ([]*Session, error)
 	ListUsers(ctx context.Context, arg ListUsersParams) ([]*ListUsersRow, error)
+	ListWebauthnCredentials(ctx context.Context, userID pgtype.UUID) ([]*WebauthnCredential, error)
+	// Locks the account until the end of the transaction, so that changes to its seats are made one at a time.
+	LockAccount(ctx context.Context, id pgtype.UUID) (*Account, error)
+	MarkEmailFailed(ctx context.Context, arg MarkEmailFailedParams) error
+	// The body is dropped, as it may contain secrets like OTPs & sign-in links.
+	MarkEmailSent(ctx context.Context, id pgtype.UUID) error
+	// Replaces the token, so that links in previous emails stop working.
+	RenewAccountInvitation(ctx context.Context, arg RenewAccountInvitationParams) (*AccountInvitation, error)
+	RescheduleEmail(ctx context.Context, arg RescheduleEmailParams) error
+	RespondToAccountInvitation(ctx context.Context, arg RespondToAccountInvitationParams) (int64, error)
+	RevokeAccountInvitation(ctx context.Context, arg RevokeAccountInvitationParams) (int64, error)
+	RevokeApiKey(ctx context.Context, arg RevokeApiKeyParams) (int64, error)
+	RevokeOtherUserSessions(ctx context.Context, arg RevokeOtherUserSessionsParams) (int64, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID pgtype.UUID) error
+	RevokeSession(ctx context.Context, id pgtype.UUID) error
+	RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error)
+	// Updates the last use at most once a minute, so that busy keys don't write on every request.
+	TouchApiKey(ctx context.Context, id pgtype.UUID) error
+	// Slides the expiry of each session to the idle timeout from when it was last seen, capped at its absolute lifetime.
+	TouchSessions(ctx context.Context, arg TouchSessionsParams) error
+	UpdateAccountName(ctx context.Context, arg UpdateAccountNameParams) (*Account, error)
 	UpdateUser(ctx context.Context, arg UpdateUserParams) (*User, error)
+	UpdateUserAccountRole(ctx context.Context, arg UpdateUserAccountRoleParams) (*UserAccount, error)
+	// Stores the credential record after a sign-in, which updates its sign count.
+	UpdateWebauthnCredentialUsage(ctx context.Context, arg UpdateWebauthnCredentialUsageParams) error
+	// Returns 0 if the subscription has already been updated by a newer event, which is then ignored.
+	UpsertSubscription(ctx context.Context, arg UpsertSubscriptionParams) (int64, error)
+	// Replaces the secret of an unconfirmed credential. Returns no rows if the credential is confirmed.
+	UpsertTotpCredential(ctx context.Context, arg UpsertTotpCredentialParams) (*TotpCredential, error)
 	UpsertUser(ctx context.Context, email string) (*User, error)
+	// Re-invited members that were deactivated get their membership back with the new role. Active memberships are left as they are & return no rows.
+	UpsertUserAccount(ctx context.Context, arg UpsertUserAccountParams) (*UserAccount, error)
+	// Links the identity to the user, unless it is already linked to one. The identity is returned either way.
+	UpsertUserIdentity(ctx context.Context, arg UpsertUserIdentityParams) (*UserIdentity, error)
+	UseRecoveryCode(ctx context.Context, id pgtype.UUID) (int64, error)
+	// Marks the token as rotated. Returns no rows if it was already rotated, or has been revoked or has expired.
+	UseRefreshToken(ctx context.Context, tokenHash string) (*RefreshToken, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -38,9 +38,11 @@ func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (p
 }
 
 const getUserBySessionId = `-- name: GetUserBySessionId :one
-SELECT u.id, u.username, u.email, u.verified_at, u.created_at, u.updated_at FROM users AS u
+SELECT u.id, u.username, u.email, u.verified_at, u.created_at, u.updated_at, u.role FROM users AS u
 JOIN sessions AS s ON u.id = s.user_id
 WHERE s.id = $1
+AND s.revoked_at IS NULL
+AND s.expires_at > CURRENT_TIMESTAMP
 `
 
 func (q *Queries) GetUserBySessionId(ctx context.Context, sessionID pgtype.UUID) (*User, error) {
@@ -53,6 +55,127 @@ func (q *Queries) GetUserBySessionId(ctx context.Context, sessionID pgtype.UUID)
 		&i.VerifiedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
 	)
 	return &i, err
 }
+
+const listUserSessions = `-- name: ListUserSessions :many
+SELECT id, user_id, user_agent, ip_address, expires_at, created_at, updated_at, revoked_at, last_seen_at FROM sessions
+WHERE user_id = $1
+AND revoked_at IS NULL
+AND expires_at > CURRENT_TIMESTAMP
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListUserSessions(ctx context.Context, userID pgtype.UUID) ([]*Session, error) {
+	rows, err := q.db.Query(ctx, listUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*Session{}
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RevokedAt,
+			&i.LastSeenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeOtherUserSessions = `-- name: RevokeOtherUserSessions :execrows
+UPDATE sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE user_id = $1
+AND id <> $2
+AND revoked_at IS NULL
+`
+
+type RevokeOtherUserSessionsParams struct {
+	UserID           pgtype.UUID `db:"user_id" json:"user_id"`
+	CurrentSessionID pgtype.UUID `db:"current_session_id" json:"current_session_id"`
+}
+
+func (q *Queries) RevokeOtherUserSessions(ctx context.Context, arg RevokeOtherUserSessionsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeOtherUserSessions, arg.UserID, arg.CurrentSessionID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const revokeSession = `-- name: RevokeSession :exec
+UPDATE sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1
+AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeSession(ctx context.Context, id pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, revokeSession, id)
+	return err
+}
+
+const revokeUserSession = `-- name: RevokeUserSession :execrows
+UPDATE sessions
+SET revoked_at = CURRENT_TIMESTAMP
+WHERE id = $1
+AND user_id = $2
+AND revoked_at IS NULL
+`
+
+type RevokeUserSessionParams struct {
+	ID     pgtype.UUID `db:"id" json:"id"`
+	UserID pgtype.UUID `db:"user_id" json:"user_id"`
+}
+
+func (q *Queries) RevokeUserSession(ctx context.Context, arg RevokeUserSessionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, revokeUserSession, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const touchSessions = `-- name: TouchSessions :exec
+UPDATE sessions AS s
+SET last_seen_at = v.seen_at,
+    expires_at = LEAST(v.seen_at + $1::interval, s.created_at + $2::interval)
+FROM unnest($3::uuid[], $4::timestamptz[]) AS v(id, seen_at)
+WHERE s.id = v.id
+AND s.revoked_at IS NULL
+AND v.seen_at > s.last_seen_at
+`
+
+type TouchSessionsParams struct {
+	IdleTimeout pgtype.Interval      `db:"idle_timeout" json:"idle_timeout"`
+	MaxLifetime pgtype.Interval      `db:"max_lifetime" json:"max_lifetime"`
+	Ids         []pgtype.UUID        `db:"ids" json:"ids"`
+	SeenAts     []pgtype.Timestamptz `db:"seen_ats" json:"seen_ats"`
+}
+
+// Slides the expiry of each session to the idle timeout from when it was last seen, capped at its absolute lifetime.
+func (q *Queries) TouchSessions(ctx context.Context, arg TouchSessionsParams) error {
+	_, err := q.db.Exec(ctx, touchSessions,
+		arg.IdleTimeout,
+		arg.MaxLifetime,
+		arg.Ids,
+		arg.SeenAts,
+	)
+	return err
+}
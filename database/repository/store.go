@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Store runs queries on their own or together in a transaction.
+type Store interface {
+	Querier
+	// InTx runs fn in a transaction that is committed if fn returns nil & rolled back otherwise.
+	InTx(ctx context.Context, fn func(repo Querier) error) error
+}
+
+type poolStore struct {
+	*Queries
+	pool *pgxpool.Pool
+}
+
+// NewStore returns a store that runs queries on the pool.
+func NewStore(pool *pgxpool.Pool) Store {
+	return &poolStore{
+		Queries: New(pool),
+		pool:    pool,
+	}
+}
+
+func (s *poolStore) InTx(ctx context.Context, fn func(repo Querier) error) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		return fn(s.WithTx(tx))
+	})
+}
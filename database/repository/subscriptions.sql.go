@@ -12,7 +12,7 @@ import (
 )
 
 const getSubscriptionByAccountID = `-- name: GetSubscriptionByAccountID :one
-SELECT id, account_id, plan_id, status, starts_at, ends_at, created_at, updated_at FROM subscriptions WHERE account_id = $1
+SELECT id, account_id, plan_id, status, starts_at, ends_at, created_at, updated_at, last_event_at FROM subscriptions WHERE account_id = $1
 `
 
 func (q *Queries) GetSubscriptionByAccountID(ctx context.Context, accountID pgtype.UUID) (*Subscription, error) {
@@ -27,6 +27,45 @@ func (q *Queries) GetSubscriptionByAccountID(ctx context.Context, accountID pgty
 		&i.EndsAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.LastEventAt,
 	)
 	return &i, err
 }
+
+const upsertSubscription = `-- name: UpsertSubscription :execrows
+INSERT INTO subscriptions (account_id, plan_id, status, starts_at, ends_at, last_event_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (account_id) DO UPDATE
+SET plan_id = $2,
+    status = $3,
+    starts_at = $4,
+    ends_at = $5,
+    last_event_at = $6
+WHERE subscriptions.last_event_at IS NULL
+OR subscriptions.last_event_at <= $6
+`
+
+type UpsertSubscriptionParams struct {
+	AccountID   pgtype.UUID        `db:"account_id" json:"account_id"`
+	PlanID      string             `db:"plan_id" json:"plan_id"`
+	Status      string             `db:"status" json:"status"`
+	StartsAt    pgtype.Timestamptz `db:"starts_at" json:"starts_at"`
+	EndsAt      pgtype.Timestamptz `db:"ends_at" json:"ends_at"`
+	LastEventAt pgtype.Timestamptz `db:"last_event_at" json:"last_event_at"`
+}
+
+// Returns 0 if the subscription has already been updated by a newer event, which is then ignored.
+func (q *Queries) UpsertSubscription(ctx context.Context, arg UpsertSubscriptionParams) (int64, error) {
+	result, err := q.db.Exec(ctx, upsertSubscription,
+		arg.AccountID,
+		arg.PlanID,
+		arg.Status,
+		arg.StartsAt,
+		arg.EndsAt,
+		arg.LastEventAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -11,6 +11,115 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const accountHasMemberWithEmail = `-- name: AccountHasMemberWithEmail :one
+SELECT EXISTS (
+    SELECT 1 FROM user_accounts AS ua
+    JOIN users AS u ON u.id = ua.user_id
+    WHERE ua.account_id = $1
+    AND u.email = $2
+    AND ua.status = 'active'
+)
+`
+
+type AccountHasMemberWithEmailParams struct {
+	AccountID pgtype.UUID `db:"account_id" json:"account_id"`
+	Email     string      `db:"email" json:"email"`
+}
+
+func (q *Queries) AccountHasMemberWithEmail(ctx context.Context, arg AccountHasMemberWithEmailParams) (bool, error) {
+	row := q.db.QueryRow(ctx, accountHasMemberWithEmail, arg.AccountID, arg.Email)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const activateUserAccounts = `-- name: ActivateUserAccounts :exec
+UPDATE user_accounts
+SET status = 'active'
+WHERE user_id = $1
+AND status = 'pending'
+`
+
+// Activates memberships accepted before the user verified their email.
+func (q *Queries) ActivateUserAccounts(ctx context.Context, userID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, activateUserAccounts, userID)
+	return err
+}
+
+const createUserAccount = `-- name: CreateUserAccount :one
+INSERT INTO user_accounts (user_id, account_id, role)
+VALUES ($1, $2, $3)
+RETURNING user_id, account_id, status, created_at, updated_at, role
+`
+
+type CreateUserAccountParams struct {
+	UserID    pgtype.UUID `db:"user_id" json:"user_id"`
+	AccountID pgtype.UUID `db:"account_id" json:"account_id"`
+	Role      string      `db:"role" json:"role"`
+}
+
+func (q *Queries) CreateUserAccount(ctx context.Context, arg CreateUserAccountParams) (*UserAccount, error) {
+	row := q.db.QueryRow(ctx, createUserAccount, arg.UserID, arg.AccountID, arg.Role)
+	var i UserAccount
+	err := row.Scan(
+		&i.UserID,
+		&i.AccountID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+	)
+	return &i, err
+}
+
+const deleteAccountMembers = `-- name: DeleteAccountMembers :exec
+DELETE FROM user_accounts
+WHERE account_id = $1
+`
+
+func (q *Queries) DeleteAccountMembers(ctx context.Context, accountID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, deleteAccountMembers, accountID)
+	return err
+}
+
+const demoteAccountOwners = `-- name: DemoteAccountOwners :exec
+UPDATE user_accounts
+SET role = 'admin'
+WHERE account_id = $1
+AND role = 'owner'
+`
+
+func (q *Queries) DemoteAccountOwners(ctx context.Context, accountID pgtype.UUID) error {
+	_, err := q.db.Exec(ctx, demoteAccountOwners, accountID)
+	return err
+}
+
+const getUserAccount = `-- name: GetUserAccount :one
+SELECT user_id, account_id, status, created_at, updated_at, role FROM user_accounts
+WHERE user_id = $1
+AND account_id = $2
+AND status = 'active'
+`
+
+type GetUserAccountParams struct {
+	UserID    pgtype.UUID `db:"user_id" json:"user_id"`
+	AccountID pgtype.UUID `db:"account_id" json:"account_id"`
+}
+
+func (q *Queries) GetUserAccount(ctx context.Context, arg GetUserAccountParams) (*UserAccount, error) {
+	row := q.db.QueryRow(ctx, getUserAccount, arg.UserID, arg.AccountID)
+	var i UserAccount
+	err := row.Scan(
+		&i.UserID,
+		&i.AccountID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+	)
+	return &i, err
+}
+
 const getUserAccountsByUserID = `-- name: GetUserAccountsByUserID :many
 SELECT a.id, a.name, a.created_at, a.updated_at FROM accounts AS a JOIN user_accounts as ua ON ua.account_id = a.id WHERE ua.user_id = $1
 `
@@ -39,3 +148,156 @@ func (q *Queries) GetUserAccountsByUserID(ctx context.Context, userID pgtype.UUI
 	}
 	return items, nil
 }
+
+const listAccountMembers = `-- name: ListAccountMembers :many
+SELECT ua.user_id, ua.account_id, ua.status, ua.created_at, ua.updated_at, ua.role, u.email FROM user_accounts AS ua
+JOIN users AS u ON u.id = ua.user_id
+WHERE ua.account_id = $1
+ORDER BY ua.created_at
+`
+
+type ListAccountMembersRow struct {
+	UserID    pgtype.UUID        `db:"user_id" json:"user_id"`
+	AccountID pgtype.UUID        `db:"account_id" json:"account_id"`
+	Status    string             `db:"status" json:"status"`
+	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	Role      string             `db:"role" json:"role"`
+	Email     string             `db:"email" json:"email"`
+}
+
+func (q *Queries) ListAccountMembers(ctx context.Context, accountID pgtype.UUID) ([]*ListAccountMembersRow, error) {
+	rows, err := q.db.Query(ctx, listAccountMembers, accountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ListAccountMembersRow{}
+	for rows.Next() {
+		var i ListAccountMembersRow
+		if err := rows.Scan(
+			&i.UserID,
+			&i.AccountID,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Role,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserAccounts = `-- name: ListUserAccounts :many
+SELECT a.id, a.name, a.created_at, a.updated_at, ua.role FROM accounts AS a
+JOIN user_accounts AS ua ON ua.account_id = a.id
+WHERE ua.user_id = $1
+AND ua.status = 'active'
+ORDER BY a.created_at
+`
+
+type ListUserAccountsRow struct {
+	ID        pgtype.UUID        `db:"id" json:"id"`
+	Name      *string            `db:"name" json:"name"`
+	CreatedAt pgtype.Timestamptz `db:"created_at" json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `db:"updated_at" json:"updated_at"`
+	Role      string             `db:"role" json:"role"`
+}
+
+// Active memberships of the user, along with the accounts.
+func (q *Queries) ListUserAccounts(ctx context.Context, userID pgtype.UUID) ([]*ListUserAccountsRow, error) {
+	rows, err := q.db.Query(ctx, listUserAccounts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ListUserAccountsRow{}
+	for rows.Next() {
+		var i ListUserAccountsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateUserAccountRole = `-- name: UpdateUserAccountRole :one
+UPDATE user_accounts
+SET role = $1
+WHERE user_id = $2
+AND account_id = $3
+RETURNING user_id, account_id, status, created_at, updated_at, role
+`
+
+type UpdateUserAccountRoleParams struct {
+	Role      string      `db:"role" json:"role"`
+	UserID    pgtype.UUID `db:"user_id" json:"user_id"`
+	AccountID pgtype.UUID `db:"account_id" json:"account_id"`
+}
+
+func (q *Queries) UpdateUserAccountRole(ctx context.Context, arg UpdateUserAccountRoleParams) (*UserAccount, error) {
+	row := q.db.QueryRow(ctx, updateUserAccountRole, arg.Role, arg.UserID, arg.AccountID)
+	var i UserAccount
+	err := row.Scan(
+		&i.UserID,
+		&i.AccountID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+	)
+	return &i, err
+}
+
+const upsertUserAccount = `-- name: UpsertUserAccount :one
+INSERT INTO user_accounts (user_id, account_id, role, status)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id, account_id) DO UPDATE
+SET role = $3,
+    status = $4
+WHERE user_accounts.status <> 'active'
+RETURNING user_id, account_id, status, created_at, updated_at, role
+`
+
+type UpsertUserAccountParams struct {
+	UserID    pgtype.UUID `db:"user_id" json:"user_id"`
+	AccountID pgtype.UUID `db:"account_id" json:"account_id"`
+	Role      string      `db:"role" json:"role"`
+	Status    string      `db:"status" json:"status"`
+}
+
+// Re-invited members that were deactivated get their membership back with the new role. Active memberships are left as they are & return no rows.
+func (q *Queries) UpsertUserAccount(ctx context.Context, arg UpsertUserAccountParams) (*UserAccount, error) {
+	row := q.db.QueryRow(ctx, upsertUserAccount,
+		arg.UserID,
+		arg.AccountID,
+		arg.Role,
+		arg.Status,
+	)
+	var i UserAccount
+	err := row.Scan(
+		&i.UserID,
+		&i.AccountID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Role,
+	)
+	return &i, err
+}
@@ -15,7 +15,7 @@ import (
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (email, username)
 VALUES ($1 , $2)
-RETURNING id, username, email, verified_at, created_at, updated_at
+RETURNING id, username, email, verified_at, created_at, updated_at, role
 `
 
 type CreateUserParams struct {
@@ -33,6 +33,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*User,
 		&i.VerifiedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
 	)
 	return &i, err
 }
@@ -74,7 +75,7 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (*GetUserByE
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, username
+SELECT id, username, email
 FROM users
 WHERE id = $1
 `
@@ -82,12 +83,13 @@ WHERE id = $1
 type GetUserByIDRow struct {
 	ID       pgtype.UUID `db:"id" json:"id"`
 	Username *string     `db:"username" json:"username"`
+	Email    string      `db:"email" json:"email"`
 }
 
 func (q *Queries) GetUserByID(ctx context.Context, id pgtype.UUID) (*GetUserByIDRow, error) {
 	row := q.db.QueryRow(ctx, getUserByID, id)
 	var i GetUserByIDRow
-	err := row.Scan(&i.ID, &i.Username)
+	err := row.Scan(&i.ID, &i.Username, &i.Email)
 	return &i, err
 }
 
@@ -143,7 +145,7 @@ SET username = COALESCE(NULLIF($1, ''), username),
     email = COALESCE(NULLIF($2, ''), email),
     verified_at = COALESCE($3, verified_at)
 WHERE id = $4
-RETURNING id, username, email, verified_at, created_at, updated_at
+RETURNING id, username, email, verified_at, created_at, updated_at, role
 `
 
 type UpdateUserParams struct {
@@ -168,6 +170,7 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (*User,
 		&i.VerifiedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
 	)
 	return &i, err
 }
@@ -177,7 +180,7 @@ INSERT INTO users (email)
 VALUES ($1)
 ON CONFLICT (email) DO UPDATE
 SET email = $1
-RETURNING id, username, email, verified_at, created_at, updated_at
+RETURNING id, username, email, verified_at, created_at, updated_at, role
 `
 
 func (q *Queries) UpsertUser(ctx context.Context, email string) (*User, error) {
@@ -190,6 +193,7 @@ func (q *Queries) UpsertUser(ctx context.Context, email string) (*User, error) {
 		&i.VerifiedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Role,
 	)
 	return &i, err
 }
@@ -0,0 +1,132 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	redisutil "github.com/rohitxdev/go-api/deps/redis"
+)
+
+const (
+	// Hash of session ID -> unix timestamp of when it was last seen, waiting to be flushed to postgres.
+	bufferKey   = "sessions:last_seen"
+	throttleKey = "sessions:last_seen:throttle:"
+)
+
+type TrackerOpts struct {
+	// A session's activity is recorded at most once per this duration.
+	Throttle time.Duration
+	// How often buffered activity is written to postgres.
+	FlushInterval time.Duration
+}
+
+var defaultTrackerOpts = TrackerOpts{
+	Throttle:      time.Minute * 5,
+	FlushInterval: time.Minute,
+}
+
+// Tracker records when sessions were last seen and slides their expiry. Activity is buffered in redis and flushed to postgres in batches, so that authenticated requests don't write to postgres.
+type Tracker struct {
+	rdb    *redis.Client
+	repo   repository.Querier
+	config *config.Store
+	logger *slog.Logger
+	opts   TrackerOpts
+}
+
+func NewTracker(rdb *redis.Client, repo repository.Querier, configStore *config.Store, logger *slog.Logger, opts *TrackerOpts) *Tracker {
+	if opts == nil {
+		opts = &defaultTrackerOpts
+	}
+
+	return &Tracker{
+		rdb:    rdb,
+		repo:   repo,
+		config: configStore,
+		logger: logger,
+		opts:   *opts,
+	}
+}
+
+// Touch records that the session was just used.
+func (t *Tracker) Touch(ctx context.Context, sessionID pgtype.UUID) error {
+	id := sessionID.String()
+
+	ok, err := t.rdb.SetNX(ctx, throttleKey+id, 1, t.opts.Throttle).Result()
+	if err != nil {
+		return fmt.Errorf("failed to throttle session activity: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	if err = t.rdb.HSet(ctx, bufferKey, id, time.Now().Unix()).Err(); err != nil {
+		return fmt.Errorf("failed to buffer session activity: %w", err)
+	}
+	return nil
+}
+
+// Run flushes buffered activity until ctx is cancelled, with a final flush before returning.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := t.flush(context.WithoutCancel(ctx)); err != nil {
+				t.logger.Error("failed to flush session activity", slog.String("error", err.Error()))
+			}
+			return
+		case <-ticker.C:
+			if err := t.flush(ctx); err != nil {
+				t.logger.Error("failed to flush session activity", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func (t *Tracker) flush(ctx context.Context) error {
+	err := redisutil.DrainHash(ctx, t.rdb, bufferKey, redisutil.MergeMax, func(entries map[string]string) error {
+		ids := make([]pgtype.UUID, 0, len(entries))
+		seenAts := make([]pgtype.Timestamptz, 0, len(entries))
+		for id, ts := range entries {
+			sessionID, err := uuid.Parse(id)
+			if err != nil {
+				continue
+			}
+			unix, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				continue
+			}
+			ids = append(ids, pgtype.UUID{Bytes: sessionID, Valid: true})
+			seenAts = append(seenAts, pgtype.Timestamptz{Time: time.Unix(unix, 0), Valid: true})
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		cfg := t.config.Get()
+		if err := t.repo.TouchSessions(ctx, repository.TouchSessionsParams{
+			IdleTimeout: pgtype.Interval{Microseconds: cfg.SessionIdleTimeout.Microseconds(), Valid: true},
+			MaxLifetime: pgtype.Interval{Microseconds: cfg.SessionMaxLifetime.Microseconds(), Valid: true},
+			Ids:         ids,
+			SeenAts:     seenAts,
+		}); err != nil {
+			return fmt.Errorf("failed to update sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drain session activity buffer: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package billing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	const (
+		secret    = "whsec_test"
+		tolerance = time.Minute * 5
+	)
+	payload := []byte(`{"id":"evt_1","type":"subscription.created"}`)
+	now := time.Now()
+	signed := Sign(payload, secret, now)
+	// Changes the last hex digit of the signature.
+	lastDigit := "0"
+	if strings.HasSuffix(signed, "0") {
+		lastDigit = "1"
+	}
+	tampered := signed[:len(signed)-1] + lastDigit
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		secret  string
+		wantErr error
+	}{
+		{"valid", payload, signed, secret, nil},
+		{"valid within tolerance", payload, Sign(payload, secret, now.Add(-tolerance+time.Second)), secret, nil},
+		{"rotated secret", payload, Sign(payload, "whsec_old", now) + ",v1=" + computeSignature(payload, secret, now.Unix()), secret, nil},
+		{"tampered payload", []byte(`{"id":"evt_1","type":"subscription.cancelled"}`), signed, secret, ErrInvalidSignature},
+		{"tampered signature", payload, tampered, secret, ErrInvalidSignature},
+		{"wrong secret", payload, Sign(payload, "whsec_other", now), secret, ErrInvalidSignature},
+		{"stale timestamp", payload, Sign(payload, secret, now.Add(-tolerance-time.Second)), secret, ErrTimestampExpired},
+		{"future timestamp", payload, Sign(payload, secret, now.Add(tolerance+time.Second)), secret, ErrTimestampExpired},
+		{"empty secret", payload, Sign(payload, "", now), "", ErrMissingSecret},
+		{"missing header", payload, "", secret, ErrMissingSignature},
+		{"missing timestamp", payload, "v1=" + computeSignature(payload, secret, now.Unix()), secret, ErrInvalidSignature},
+		{"malformed timestamp", payload, "t=abc,v1=" + computeSignature(payload, secret, now.Unix()), secret, ErrInvalidSignature},
+		{"missing signature", payload, strings.Split(signed, ",")[0], secret, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.payload, tt.header, tt.secret, tolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifySignature() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -21,6 +21,8 @@ var (
 	ErrBuildInfoNotSet     = errors.New("build info is not set")
 	ErrConfigNil           = errors.New("config is nil")
 	ErrStoreNotInitialized = errors.New("config store not initialized")
+	ErrSMTPNotConfigured   = errors.New("SMTP_HOST, SMTP_PORT & SMTP_FROM_ADDRESS are required when email-dependent features are enabled")
+	ErrSMTPRequired        = errors.New("EMAIL_TRANSPORT must be smtp in production")
 )
 
 type Build struct {
@@ -36,30 +38,118 @@ type Runtime struct {
 	HTTPHost       string      `json:"http_host" validate:"required" env:"HTTP_HOST"`
 	HTTPPort       string      `json:"http_port" validate:"required" env:"HTTP_PORT"`
 	AllowedOrigins []string    `json:"allowed_origins" validate:"required,dive,min=1" env:"ALLOWED_ORIGINS"`
-	Debug          bool        `json:"debug" env:"DEBUG"`
+	// URL clients reach the server at, e.g. 'https://api.example.com'. Links to the server, e.g. in emails, are built from it rather than from the request's Host header, which clients control.
+	PublicURL string `json:"public_url" validate:"required,url" env:"PUBLIC_URL"`
+	Debug     bool   `json:"debug" env:"DEBUG"`
+	// Domain that passkeys are registered for, e.g. 'example.com'. Passkeys are unavailable if it is empty. Origins of the ceremonies must be allowed origins.
+	WebAuthnRPID string `json:"webauthn_rp_id" validate:"omitempty,hostname_rfc1123" env:"WEBAUTHN_RP_ID"`
+	// Sessions expire after being idle for this long, but never live longer than the max lifetime.
+	SessionIdleTimeout time.Duration `json:"session_idle_timeout" validate:"required" env:"SESSION_IDLE_TIMEOUT" envDefault:"168h"`
+	SessionMaxLifetime time.Duration `json:"session_max_lifetime" validate:"required,gtefield=SessionIdleTimeout" env:"SESSION_MAX_LIFETIME" envDefault:"720h"`
 }
 
 type Secrets struct {
 	PostgresURL   string `json:"postgres_url" validate:"required,url" env:"POSTGRES_URL"`
 	RedisURL      string `json:"redis_url" validate:"required,url" env:"REDIS_URL"`
 	SessionSecret string `json:"session_secret" validate:"required,len=64" env:"SESSION_SECRET"`
+	// Encrypts secrets stored in postgres, e.g. TOTP secrets. Features that need it are unavailable if it is empty.
+	EncryptionKey string `json:"encryption_key" validate:"omitempty,len=32" env:"ENCRYPTION_KEY"`
+	// Signs access tokens, as a base64 encoded 32-byte Ed25519 seed. Access tokens are unavailable if it is empty.
+	JWTSigningKey string `json:"jwt_signing_key" validate:"omitempty,base64" env:"JWT_SIGNING_KEY"`
+	// Signs the requests of the billing provider's webhooks. Webhooks are rejected if it is empty.
+	BillingWebhookSecret string `json:"billing_webhook_secret" env:"BILLING_WEBHOOK_SECRET"`
+	// Identity providers for 'Sign in with <provider>' as JSON, see 'OIDCProviders'. None are enabled if it is empty.
+	OIDCProviders OIDCProviders `json:"oidc_providers" validate:"dive,required" env:"OIDC_PROVIDERS"`
+}
+
+type SMTP struct {
+	// 'file' writes emails into TMP_DIR & 'memory' only keeps them in the process, both are meant for development.
+	EmailTransport  string `json:"email_transport" validate:"required,oneof=smtp file memory" env:"EMAIL_TRANSPORT" envDefault:"smtp"`
+	SMTPHost        string `json:"smtp_host" validate:"omitempty,hostname_rfc1123|ip" env:"SMTP_HOST"`
+	SMTPPort        int    `json:"smtp_port" validate:"omitempty,min=1,max=65535" env:"SMTP_PORT"`
+	SMTPUsername    string `json:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword    string `json:"smtp_password" env:"SMTP_PASSWORD"`
+	SMTPTLSMode     string `json:"smtp_tls_mode" validate:"required,oneof=starttls tls" env:"SMTP_TLS_MODE" envDefault:"starttls"`
+	SMTPFromAddress string `json:"smtp_from_address" validate:"omitempty,email" env:"SMTP_FROM_ADDRESS"`
+	SMTPFromName    string `json:"smtp_from_name" env:"SMTP_FROM_NAME"`
 }
 
 type Features struct {
 	EmailVerificationEnabled bool `json:"email_verification_enabled" env:"EMAIL_VERIFICATION_ENABLED"`
+	// Queues emails in Postgres & delivers them in the background with retries.
+	EmailOutboxEnabled bool `json:"email_outbox_enabled" env:"EMAIL_OUTBOX_ENABLED" envDefault:"true"`
+	// Echoes OTP codes back in API responses. Only honoured in testing & development environments.
+	OTPEchoEnabled bool `json:"otp_echo_enabled" env:"OTP_ECHO_ENABLED"`
+}
+
+type Billing struct {
+	// Plan catalogue as JSON, see 'Plans'. Defaults to a free, a pro & an enterprise plan.
+	Plans Plans `json:"plans" validate:"dive,required" env:"PLANS"`
+	// Plan of accounts that don't have a subscription.
+	DefaultPlanID string `json:"default_plan_id" validate:"required" env:"DEFAULT_PLAN_ID" envDefault:"free"`
+	// Max age of a billing webhook request, so that captured requests can't be replayed later.
+	BillingWebhookTolerance time.Duration `json:"billing_webhook_tolerance" validate:"required" env:"BILLING_WEBHOOK_TOLERANCE" envDefault:"5m"`
+}
+
+// RateLimits are applied per client, see 'RateLimit' for the format.
+type RateLimits struct {
+	// Every route under '/auth', per IP.
+	RateLimitAuth RateLimit `json:"rate_limit_auth" env:"RATE_LIMIT_AUTH" envDefault:"30/1m"`
+	// Sending OTP emails, per IP. Applies on top of the '/auth' limit.
+	RateLimitOTPSend RateLimit `json:"rate_limit_otp_send" env:"RATE_LIMIT_OTP_SEND" envDefault:"5/15m"`
+	// Verifying OTP codes, per IP. Applies on top of the '/auth' limit.
+	RateLimitOTPVerify RateLimit `json:"rate_limit_otp_verify" env:"RATE_LIMIT_OTP_VERIFY" envDefault:"10/15m"`
+	// Sending magic links, per IP. Applies on top of the '/auth' limit.
+	RateLimitMagicLinkSend RateLimit `json:"rate_limit_magic_link_send" env:"RATE_LIMIT_MAGIC_LINK_SEND" envDefault:"5/15m"`
+	// Every route under '/accounts', per user.
+	RateLimitAccounts RateLimit `json:"rate_limit_accounts" env:"RATE_LIMIT_ACCOUNTS" envDefault:"300/1m"`
+}
+
+// Tokens are issued by '/auth/token' to clients that can't use the session cookie, e.g. mobile apps.
+type Tokens struct {
+	// Access tokens stay valid until they expire unless their session is revoked, so they should be short-lived.
+	AccessTokenTTL time.Duration `json:"access_token_ttl" validate:"required" env:"ACCESS_TOKEN_TTL" envDefault:"15m"`
+	// The 'aud' claim of access tokens, which is checked when they are used.
+	AccessTokenAudience string `json:"access_token_audience" validate:"required" env:"ACCESS_TOKEN_AUDIENCE" envDefault:"api"`
+	// Each refresh token expires this long after it was issued, but never outlives its session.
+	RefreshTokenTTL time.Duration `json:"refresh_token_ttl" validate:"required" env:"REFRESH_TOKEN_TTL" envDefault:"720h"`
 }
 
 type Config struct {
 	Build
 	Runtime
 	Secrets
+	SMTP
 	Features
+	Billing
+	RateLimits
+	Tokens
+}
+
+// EmailRequired reports whether any enabled feature depends on sending emails. Sign-in by OTP & magic link is always enabled, so emails are only optional where their codes are echoed back instead.
+func (cfg *Config) EmailRequired() bool {
+	return cfg.AppEnv == EnvProduction || cfg.EmailVerificationEnabled || !cfg.CanEchoOTP()
 }
 
 func validateConfig(cfg *Config) error {
 	if err := util.Validate.Struct(cfg); err != nil {
 		return fmt.Errorf("config validation failed: %w", err)
 	}
+	if cfg.EmailRequired() && cfg.EmailTransport == "smtp" && (cfg.SMTPHost == "" || cfg.SMTPPort == 0 || cfg.SMTPFromAddress == "") {
+		return fmt.Errorf("config validation failed: %w", ErrSMTPNotConfigured)
+	}
+	// The other transports never deliver emails.
+	if cfg.AppEnv == EnvProduction && cfg.EmailTransport != "smtp" {
+		return fmt.Errorf("config validation failed: %w", ErrSMTPRequired)
+	}
+	if cfg.JWTSigningKey != "" {
+		if _, err := cfg.JWTKey(); err != nil {
+			return fmt.Errorf("config validation failed: %w", err)
+		}
+	}
+	if _, err := cfg.Plan(cfg.DefaultPlanID); err != nil {
+		return fmt.Errorf("config validation failed: default plan: %w", err)
+	}
 	return nil
 }
 
@@ -82,6 +172,9 @@ func loadConfig() (*Config, error) {
 	if err := env.Parse(&cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse env as config: %w", err)
 	}
+	if cfg.Plans == nil {
+		cfg.Plans = defaultPlans()
+	}
 
 	if err := validateConfig(&cfg); err != nil {
 		return nil, err
@@ -90,6 +183,11 @@ func loadConfig() (*Config, error) {
 	return &cfg, nil
 }
 
+// CanEchoOTP reports whether plaintext OTP codes may be returned in API responses.
+func (cfg *Config) CanEchoOTP() bool {
+	return cfg.OTPEchoEnabled && (cfg.AppEnv == EnvTest || cfg.AppEnv == EnvDevelopment)
+}
+
 type Store struct {
 	cfg atomic.Pointer[Config]
 }
@@ -106,6 +204,26 @@ func NewStore() (*Store, error) {
 	return &store, nil
 }
 
+// NewStoreFromConfig returns a store holding a copy of cfg instead of loading it from the environment, e.g. in tests. Defaults that aren't env tags are applied & the config is validated like a loaded one.
+func NewStoreFromConfig(cfg *Config) (*Store, error) {
+	if cfg == nil {
+		return nil, ErrConfigNil
+	}
+
+	val := *cfg
+	if val.Plans == nil {
+		val.Plans = defaultPlans()
+	}
+	if err := validateConfig(&val); err != nil {
+		return nil, err
+	}
+
+	var store Store
+	store.cfg.Store(&val)
+
+	return &store, nil
+}
+
 func (s *Store) Get() *Config {
 	return s.cfg.Load()
 }
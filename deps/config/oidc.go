@@ -0,0 +1,29 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// OIDCProvider is an OpenID Connect provider that users can sign in with, e.g. Google. Its endpoints & signing keys are discovered from the issuer URL.
+type OIDCProvider struct {
+	// Shown to users, e.g. 'Google'.
+	Name         string `json:"name" validate:"required"`
+	IssuerURL    string `json:"issuer_url" validate:"required,url"`
+	ClientID     string `json:"client_id" validate:"required"`
+	ClientSecret string `json:"client_secret"`
+	// Requested on top of 'openid', 'email' & 'profile'.
+	Scopes []string `json:"scopes"`
+}
+
+// OIDCProviders are keyed by the provider ID used in routes & stored in 'user_identities.provider'. They are read from the OIDC_PROVIDERS env var as JSON.
+type OIDCProviders map[string]*OIDCProvider
+
+func (p *OIDCProviders) UnmarshalText(text []byte) error {
+	var providers map[string]*OIDCProvider
+	if err := json.Unmarshal(text, &providers); err != nil {
+		return fmt.Errorf("failed to unmarshal OIDC providers: %w", err)
+	}
+	*p = providers
+	return nil
+}
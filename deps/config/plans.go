@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownPlan = errors.New("unknown plan")
+)
+
+// Features that plans can include.
+const (
+	FeatureAPIKeys = "api_keys"
+)
+
+// Limits that plans can set.
+const (
+	LimitSeats       = "seats"
+	LimitAPIRequests = "api_requests"
+)
+
+// Unlimited is the value of a limit that doesn't apply. A limit missing from a plan is 0, not unlimited, so that forgetting one doesn't give it away.
+const Unlimited int64 = -1
+
+// Plan is what a subscription to it entitles an account to.
+type Plan struct {
+	Name     string           `json:"name" validate:"required"`
+	Features []string         `json:"features"`
+	Limits   map[string]int64 `json:"limits"`
+}
+
+// Plans is the plan catalogue, keyed by the plan ID stored in 'subscriptions.plan_id'. It is read from the PLANS env var as JSON.
+type Plans map[string]*Plan
+
+func (p *Plans) UnmarshalText(text []byte) error {
+	var plans map[string]*Plan
+	if err := json.Unmarshal(text, &plans); err != nil {
+		return fmt.Errorf("failed to unmarshal plans: %w", err)
+	}
+	*p = plans
+	return nil
+}
+
+func defaultPlans() Plans {
+	return Plans{
+		"free": {
+			Name:     "Free",
+			Features: []string{},
+			Limits: map[string]int64{
+				LimitSeats:       3,
+				LimitAPIRequests: 10_000,
+			},
+		},
+		"pro": {
+			Name:     "Pro",
+			Features: []string{FeatureAPIKeys},
+			Limits: map[string]int64{
+				LimitSeats:       25,
+				LimitAPIRequests: 1_000_000,
+			},
+		},
+		"enterprise": {
+			Name:     "Enterprise",
+			Features: []string{FeatureAPIKeys},
+			Limits: map[string]int64{
+				LimitSeats:       Unlimited,
+				LimitAPIRequests: Unlimited,
+			},
+		},
+	}
+}
+
+// Plan returns the plan with the ID from the catalogue.
+func (cfg *Config) Plan(id string) (*Plan, error) {
+	plan, ok := cfg.Plans[id]
+	if !ok || plan == nil {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownPlan, id)
+	}
+	return plan, nil
+}
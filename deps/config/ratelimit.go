@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows a number of requests per period, written as '<requests>/<period>', e.g. '10/1m'. A zero value disables the limit.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (r *RateLimit) UnmarshalText(text []byte) error {
+	requests, period, ok := strings.Cut(string(text), "/")
+	if !ok {
+		return fmt.Errorf("invalid rate limit %q: expected '<requests>/<period>'", text)
+	}
+
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid rate limit %q: requests must be a non-negative integer", text)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid rate limit %q: period must be a positive duration", text)
+	}
+
+	*r = RateLimit{Requests: n, Period: d}
+	return nil
+}
+
+func (r RateLimit) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r RateLimit) String() string {
+	return strconv.Itoa(r.Requests) + "/" + r.Period.String()
+}
+
+// Enabled reports whether the limit applies.
+func (r RateLimit) Enabled() bool {
+	return r.Requests > 0 && r.Period > 0
+}
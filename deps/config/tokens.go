@@ -0,0 +1,28 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrJWTNotConfigured = errors.New("JWT_SIGNING_KEY is not set")
+)
+
+// JWTKey returns the key that access tokens are signed with.
+func (cfg *Config) JWTKey() (ed25519.PrivateKey, error) {
+	if cfg.JWTSigningKey == "" {
+		return nil, ErrJWTNotConfigured
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(cfg.JWTSigningKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWT signing key: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("JWT signing key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
@@ -1,14 +1,27 @@
 package email
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"html/template"
-	"io"
+	"io/fs"
 	"net/http"
+	"slices"
+	"time"
 
 	"github.com/oklog/ulid/v2"
-	"gopkg.in/gomail.v2"
+	"github.com/rohitxdev/go-api/util"
+)
+
+var (
+	ErrTransportNil = errors.New("email transport is nil")
+)
+
+const (
+	// TLSModeStartTLS upgrades a plain connection using STARTTLS.
+	TLSModeStartTLS = "starttls"
+	// TLSModeTLS uses implicit TLS from the start of the connection, usually on port 465.
+	TLSModeTLS = "tls"
 )
 
 type SMTPCredentials struct {
@@ -16,19 +29,43 @@ type SMTPCredentials struct {
 	Password string
 	Host     string
 	Port     int
+	TLSMode  string
+	// Bounds connecting & sending each message. Defaults to 30 seconds.
+	Timeout time.Duration
+}
+
+// Sender is used as the 'From' address of emails that don't specify one.
+type Sender struct {
+	Address string
+	Name    string
 }
 
 type Client struct {
-	dialer    *gomail.Dialer
-	templates *template.Template
+	transport Transport
+	sender    Sender
+	templates map[string]*emailTemplate
+	// Recently sent messages, only kept when enabled with 'CaptureRecent'.
+	recent *MemoryTransport
 }
 
-func New(sc *SMTPCredentials, templates *template.Template) (*Client, error) {
-	dialer := gomail.NewDialer(sc.Host, sc.Port, sc.Username, sc.Password)
+// New creates an email client. 'templatesFS' must contain the email templates & 'partials.tmpl' at its root.
+func New(transport Transport, sender *Sender, templatesFS fs.FS) (*Client, error) {
+	if transport == nil {
+		return nil, ErrTransportNil
+	}
+
+	templates, err := parseTemplates(templatesFS)
+	if err != nil {
+		return nil, err
+	}
+
 	client := Client{
-		dialer:    dialer,
+		transport: transport,
 		templates: templates,
 	}
+	if sender != nil {
+		client.sender = *sender
+	}
 
 	return &client, nil
 }
@@ -47,68 +84,85 @@ type BaseOpts struct {
 	ToAddresses     []string
 	Cc              []string
 	Bcc             []string
+	// Language of the template variant, e.g. "es". Falls back to English.
+	Language string
 	// Prevent email stacking in the same thread on the email client.
 	NoStack bool
 }
 
-// 'send' sends an email with raw content of the specified MIME type.
-func (ec *Client) send(opts *BaseOpts, mimeType string, body string, attachments ...Attachment) error {
-	msg := gomail.NewMessage()
-
-	msg.SetHeaders(map[string][]string{
-		"From":    {msg.FormatAddress(opts.FromAddress, opts.FromName)},
-		"Subject": {opts.Subject},
-		"To":      opts.ToAddresses,
-	})
+// 'send' sends an email with a plain-text body and an optional HTML alternative.
+func (ec *Client) send(ctx context.Context, opts *BaseOpts, textBody string, htmlBody string, attachments ...Attachment) error {
+	msg := Message{
+		FromAddress: opts.FromAddress,
+		FromName:    opts.FromName,
+		To:          opts.ToAddresses,
+		Cc:          opts.Cc,
+		Bcc:         opts.Bcc,
+		Subject:     opts.Subject,
+		Headers:     map[string][]string{},
+		TextBody:    textBody,
+		HTMLBody:    htmlBody,
+	}
 
+	if msg.FromAddress == "" {
+		msg.FromAddress, msg.FromName = ec.sender.Address, util.Coalesce(msg.FromName, ec.sender.Name)
+	}
 	if opts.NoStack {
-		msg.SetHeader("X-Entity-Ref-ID", ulid.Make().String())
+		msg.Headers["X-Entity-Ref-ID"] = []string{ulid.Make().String()}
 	}
 	if opts.UnsubscribeLink != "" {
-		msg.SetHeader("List-Unsubscribe", opts.UnsubscribeLink)
-	}
-	if len(opts.Cc) > 0 {
-		msg.SetHeader("Cc", opts.Cc...)
-	}
-	if len(opts.Bcc) > 0 {
-		msg.SetHeader("Bcc", opts.Bcc...)
+		msg.Headers["List-Unsubscribe"] = []string{opts.UnsubscribeLink}
 	}
 
-	msg.SetBody(mimeType, body)
-
 	for _, attachment := range attachments {
 		if attachment.ContentType == "" {
 			attachment.ContentType = http.DetectContentType(attachment.Data)
 		}
-		msg.Attach(
-			attachment.Filename,
-			gomail.SetCopyFunc(func(w io.Writer) error {
-				_, err := w.Write(attachment.Data)
-				return err
-			}),
-			gomail.SetHeader(map[string][]string{
-				"Content-Type": {attachment.ContentType},
-			}),
-		)
+		msg.Attachments = append(msg.Attachments, attachment)
 	}
 
-	if err := ec.dialer.DialAndSend(msg); err != nil {
+	if err := ec.transport.Send(ctx, &msg); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
+	if ec.recent != nil {
+		return ec.recent.Send(ctx, &msg)
+	}
 	return nil
 }
 
-// SendHtml sends an HTML email using a template.
-func (ec *Client) SendHTML(opts *BaseOpts, templateName string, data map[string]any, attachments ...Attachment) error {
-	var buf bytes.Buffer
-	if err := ec.templates.ExecuteTemplate(&buf, templateName+".tmpl", data); err != nil {
-		// '%q' prints in quotes
-		return fmt.Errorf("failed to execute template %q: %w", templateName, err)
+// CaptureRecent keeps the last 'limit' sent messages in memory for inspection. It must be called before the client is used.
+func (ec *Client) CaptureRecent(limit int) {
+	ec.recent = NewMemoryTransport(limit)
+}
+
+// Recent returns up to 'n' of the most recently sent messages, newest first. Returns nil if capturing is disabled.
+func (ec *Client) Recent(n int) []*Message {
+	if ec.recent == nil {
+		return nil
 	}
-	return ec.send(opts, "text/html", buf.String(), attachments...)
+
+	messages := ec.recent.Messages()
+	slices.Reverse(messages)
+	if n < len(messages) {
+		messages = messages[:n]
+	}
+	return messages
+}
+
+// SendHTML renders a template in the language of 'opts' and sends it as a multipart email with HTML & plain-text alternatives. The template's subject is used unless 'opts' sets one.
+func (ec *Client) SendHTML(ctx context.Context, opts *BaseOpts, templateName string, data map[string]any, attachments ...Attachment) error {
+	rendered, err := ec.Render(templateName, util.Coalesce(opts.Language, DefaultLanguage), data)
+	if err != nil {
+		return err
+	}
+
+	resolved := *opts
+	resolved.Subject = util.Coalesce(opts.Subject, rendered.Subject)
+
+	return ec.send(ctx, &resolved, rendered.TextBody, rendered.HTMLBody, attachments...)
 }
 
 // SendText sends a plain text email.
-func (ec *Client) SendText(opts *BaseOpts, body string, attachments ...Attachment) error {
-	return ec.send(opts, "text/plain", body, attachments...)
+func (ec *Client) SendText(ctx context.Context, opts *BaseOpts, body string, attachments ...Attachment) error {
+	return ec.send(ctx, opts, body, "", attachments...)
 }
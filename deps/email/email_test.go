@@ -0,0 +1,71 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSendHTMLLocalizesMultipart(t *testing.T) {
+	transport := NewMemoryTransport(0)
+	ec := newTestClient(t, transport)
+	data := map[string]any{"code": "ABC123", "validMinutes": 10, "year": 2026}
+
+	tests := []struct {
+		name        string
+		opts        BaseOpts
+		wantSubject string
+		wantText    string
+	}{
+		{name: "English", opts: BaseOpts{}, wantSubject: "Your sign-in code", wantText: "use the code below to sign in"},
+		{name: "variant", opts: BaseOpts{Language: "de"}, wantSubject: "Dein Anmeldecode", wantText: "verwende den folgenden Code"},
+		{name: "fallback", opts: BaseOpts{Language: "ja"}, wantSubject: "Your sign-in code", wantText: "use the code below to sign in"},
+		{name: "subject override", opts: BaseOpts{Language: "de", Subject: "Custom"}, wantSubject: "Custom", wantText: "verwende den folgenden Code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport.Reset()
+			tt.opts.ToAddresses = []string{"user@example.com"}
+			if err := ec.SendHTML(context.Background(), &tt.opts, "auth-otp", data); err != nil {
+				t.Fatalf("failed to send: %v", err)
+			}
+
+			messages := transport.Messages()
+			if len(messages) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(messages))
+			}
+			msg := messages[0]
+			if msg.Subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", msg.Subject, tt.wantSubject)
+			}
+			if msg.FromAddress != "noreply@example.com" || msg.FromName != "go-api" {
+				t.Errorf("from = %q <%s>, want the default sender", msg.FromName, msg.FromAddress)
+			}
+			// Both alternatives carry the same content, the text one without markup.
+			if !strings.Contains(msg.TextBody, tt.wantText) || !strings.Contains(msg.TextBody, "ABC123") || strings.Contains(msg.TextBody, "<p>") {
+				t.Errorf("text body = %q", msg.TextBody)
+			}
+			if !strings.Contains(msg.HTMLBody, tt.wantText) || !strings.Contains(msg.HTMLBody, "ABC123") {
+				t.Errorf("HTML body = %q", msg.HTMLBody)
+			}
+
+			var wire strings.Builder
+			if _, err := msg.compose().WriteTo(&wire); err != nil {
+				t.Fatalf("failed to compose: %v", err)
+			}
+			for _, want := range []string{"multipart/alternative", "text/plain", "text/html"} {
+				if !strings.Contains(wire.String(), want) {
+					t.Errorf("composed message doesn't contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	ec := newTestClient(t, NewMemoryTransport(0))
+	if _, err := ec.Render("missing", DefaultLanguage, nil); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("err = %v, want %v", err, ErrTemplateNotFound)
+	}
+}
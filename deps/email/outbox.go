@@ -0,0 +1,210 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/util"
+)
+
+type OutboxOpts struct {
+	// How often the outbox is polled for due messages.
+	PollInterval time.Duration
+	// Max messages claimed per poll.
+	BatchSize int32
+	// Messages are marked as failed after these many delivery attempts.
+	MaxAttempts int32
+	// Delay before the first retry, doubled on every subsequent attempt.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// How long a claimed message stays invisible to other workers. It must be longer than sending takes, see the timeout of 'SMTPCredentials'.
+	Lease time.Duration
+	// Sent messages are deleted once they are older than this, checked every PurgeInterval.
+	SentRetention time.Duration
+	PurgeInterval time.Duration
+}
+
+var defaultOutboxOpts = OutboxOpts{
+	PollInterval:   time.Second * 5,
+	BatchSize:      10,
+	MaxAttempts:    8,
+	InitialBackoff: time.Second * 30,
+	MaxBackoff:     time.Hour,
+	Lease:          time.Minute * 2,
+	SentRetention:  time.Hour * 24,
+	PurgeInterval:  time.Hour,
+}
+
+// Outbox is a transport that persists messages in Postgres. Messages are delivered in the background through the wrapped transport by 'Run'.
+type Outbox struct {
+	repo      repository.Querier
+	transport Transport
+	breaker   *util.CircuitBreaker
+	logger    *slog.Logger
+	opts      OutboxOpts
+}
+
+func NewOutbox(repo repository.Querier, transport Transport, logger *slog.Logger, opts *OutboxOpts) *Outbox {
+	if opts == nil {
+		opts = &defaultOutboxOpts
+	}
+
+	return &Outbox{
+		repo:      repo,
+		transport: transport,
+		logger:    logger,
+		opts:      *opts,
+		breaker: util.NewCircuitBreaker(&util.CircuitBreakerOpts{
+			ResetTimeout:     time.Minute,
+			FailureThreshold: 5,
+			SuccessThreshold: 2,
+			OnStatusChange: func(from uint, to uint) {
+				logger.Warn("email transport circuit-breaker status changed", slog.Uint64("from", uint64(from)), slog.Uint64("to", uint64(to)))
+			},
+		}),
+	}
+}
+
+// Send enqueues the message for background delivery.
+func (o *Outbox) Send(ctx context.Context, msg *Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal email: %w", err)
+	}
+
+	if _, err = o.repo.EnqueueEmail(ctx, payload); err != nil {
+		return fmt.Errorf("failed to enqueue email: %w", err)
+	}
+	return nil
+}
+
+// Run delivers due messages until ctx is cancelled. An in-flight batch is always completed before returning.
+func (o *Outbox) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.opts.PollInterval)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(o.opts.PurgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-purgeTicker.C:
+			if err := o.purge(context.WithoutCancel(ctx)); err != nil {
+				o.logger.Error("failed to purge sent emails", slog.String("error", err.Error()))
+			}
+		case <-ticker.C:
+			// Keep draining while full batches come back.
+			for {
+				n, err := o.deliverBatch(context.WithoutCancel(ctx))
+				if err != nil {
+					o.logger.Error("failed to deliver email batch", slog.String("error", err.Error()))
+				}
+				if err != nil || n < int(o.opts.BatchSize) || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
+
+// purge deletes sent messages older than the retention, so that the outbox doesn't keep recipients forever.
+func (o *Outbox) purge(ctx context.Context) error {
+	n, err := o.repo.DeleteSentEmails(ctx, pgtype.Timestamptz{
+		Time:  time.Now().Add(-o.opts.SentRetention),
+		Valid: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete sent emails: %w", err)
+	}
+	if n > 0 {
+		o.logger.Debug("purged sent emails", slog.Int64("count", n))
+	}
+	return nil
+}
+
+func (o *Outbox) deliverBatch(ctx context.Context) (int, error) {
+	rows, err := o.repo.ClaimEmails(ctx, repository.ClaimEmailsParams{
+		LockedUntil: pgtype.Timestamptz{
+			Time:  time.Now().Add(o.opts.Lease),
+			Valid: true,
+		},
+		BatchSize: o.opts.BatchSize,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim emails: %w", err)
+	}
+
+	for _, row := range rows {
+		if err := o.deliver(ctx, row); err != nil {
+			o.logger.Error("failed to update email status", slog.String("email_id", row.ID.String()), slog.String("error", err.Error()))
+		}
+	}
+
+	return len(rows), nil
+}
+
+func (o *Outbox) deliver(ctx context.Context, row *repository.EmailOutbox) error {
+	var msg Message
+	if err := json.Unmarshal(row.Payload, &msg); err != nil {
+		// A malformed payload will never succeed, so there is no point in retrying.
+		return o.fail(ctx, row, row.Attempts+1, fmt.Errorf("failed to unmarshal email: %w", err))
+	}
+
+	_, err := o.breaker.Do(func() (any, error) {
+		return nil, o.transport.Send(ctx, &msg)
+	})
+	if err == nil {
+		return o.repo.MarkEmailSent(ctx, row.ID)
+	}
+
+	// The attempt never reached the server, so it doesn't count.
+	if errors.Is(err, util.ErrorCircuitBreakerOpen) {
+		return o.reschedule(ctx, row, row.Attempts, err, time.Minute)
+	}
+
+	attempts := row.Attempts + 1
+	if attempts >= o.opts.MaxAttempts {
+		o.logger.Warn("email delivery failed permanently", slog.String("email_id", row.ID.String()), slog.String("error", err.Error()))
+		return o.fail(ctx, row, attempts, err)
+	}
+
+	return o.reschedule(ctx, row, attempts, err, o.backoff(attempts))
+}
+
+// backoff returns the exponential delay before the next attempt, capped at MaxBackoff.
+func (o *Outbox) backoff(attempts int32) time.Duration {
+	delay := o.opts.InitialBackoff << (attempts - 1)
+	if delay <= 0 || delay > o.opts.MaxBackoff {
+		return o.opts.MaxBackoff
+	}
+	return delay
+}
+
+func (o *Outbox) reschedule(ctx context.Context, row *repository.EmailOutbox, attempts int32, cause error, delay time.Duration) error {
+	lastError := cause.Error()
+	return o.repo.RescheduleEmail(ctx, repository.RescheduleEmailParams{
+		ID:        row.ID,
+		Attempts:  attempts,
+		LastError: &lastError,
+		NextAttemptAt: pgtype.Timestamptz{
+			Time:  time.Now().Add(delay),
+			Valid: true,
+		},
+	})
+}
+
+func (o *Outbox) fail(ctx context.Context, row *repository.EmailOutbox, attempts int32, cause error) error {
+	lastError := cause.Error()
+	return o.repo.MarkEmailFailed(ctx, repository.MarkEmailFailedParams{
+		ID:        row.ID,
+		Attempts:  attempts,
+		LastError: &lastError,
+	})
+}
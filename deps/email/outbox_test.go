@@ -0,0 +1,288 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+)
+
+// fakeOutboxRepo keeps the outbox in memory, like the queries of 'email_outbox.sql'. Other queries panic through the nil 'repository.Querier'.
+type fakeOutboxRepo struct {
+	repository.Querier
+	mu   sync.Mutex
+	rows map[pgtype.UUID]*repository.EmailOutbox
+}
+
+func newFakeOutboxRepo() *fakeOutboxRepo {
+	return &fakeOutboxRepo{rows: map[pgtype.UUID]*repository.EmailOutbox{}}
+}
+
+func (r *fakeOutboxRepo) EnqueueEmail(ctx context.Context, payload []byte) (pgtype.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := pgtype.UUID{Bytes: uuid.Must(uuid.NewV7()), Valid: true}
+	r.rows[id] = &repository.EmailOutbox{
+		ID:            id,
+		Payload:       payload,
+		Status:        "pending",
+		NextAttemptAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	return id, nil
+}
+
+func (r *fakeOutboxRepo) ClaimEmails(ctx context.Context, arg repository.ClaimEmailsParams) ([]*repository.EmailOutbox, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var claimed []*repository.EmailOutbox
+	for _, row := range r.rows {
+		if len(claimed) == int(arg.BatchSize) {
+			break
+		}
+		if (row.Status == "pending" || row.Status == "sending") && !row.NextAttemptAt.Time.After(time.Now()) {
+			row.Status = "sending"
+			row.NextAttemptAt = arg.LockedUntil
+			copied := *row
+			claimed = append(claimed, &copied)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *fakeOutboxRepo) MarkEmailSent(ctx context.Context, id pgtype.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rows[id].Status = "sent"
+	r.rows[id].LastError = nil
+	return nil
+}
+
+func (r *fakeOutboxRepo) RescheduleEmail(ctx context.Context, arg repository.RescheduleEmailParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := r.rows[arg.ID]
+	row.Status = "pending"
+	row.Attempts = arg.Attempts
+	row.LastError = arg.LastError
+	row.NextAttemptAt = arg.NextAttemptAt
+	return nil
+}
+
+func (r *fakeOutboxRepo) MarkEmailFailed(ctx context.Context, arg repository.MarkEmailFailedParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := r.rows[arg.ID]
+	row.Status = "failed"
+	row.Attempts = arg.Attempts
+	row.LastError = arg.LastError
+	return nil
+}
+
+// passTime makes every pending row due, as if its backoff had passed.
+func (r *fakeOutboxRepo) passTime() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, row := range r.rows {
+		row.NextAttemptAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	}
+}
+
+func (r *fakeOutboxRepo) only(t *testing.T) repository.EmailOutbox {
+	t.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.rows) != 1 {
+		t.Fatalf("outbox has %d rows, want 1", len(r.rows))
+	}
+	for _, row := range r.rows {
+		return *row
+	}
+	panic("unreachable")
+}
+
+// fakeTransport fails every send while err is set.
+type fakeTransport struct {
+	mu    sync.Mutex
+	err   error
+	sends int
+}
+
+func (t *fakeTransport) Send(ctx context.Context, msg *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sends++
+	return t.err
+}
+
+var testOutboxOpts = OutboxOpts{
+	PollInterval:   time.Second,
+	BatchSize:      10,
+	MaxAttempts:    3,
+	InitialBackoff: time.Second * 30,
+	MaxBackoff:     time.Minute,
+	Lease:          time.Minute * 2,
+	SentRetention:  time.Hour,
+	PurgeInterval:  time.Hour,
+}
+
+func newTestOutbox(t *testing.T, transport Transport) (*Outbox, *fakeOutboxRepo) {
+	t.Helper()
+
+	repo := newFakeOutboxRepo()
+	opts := testOutboxOpts
+	return NewOutbox(repo, transport, slog.New(slog.NewTextHandler(io.Discard, nil)), &opts), repo
+}
+
+func deliver(t *testing.T, o *Outbox) int {
+	t.Helper()
+
+	n, err := o.deliverBatch(context.Background())
+	if err != nil {
+		t.Fatalf("failed to deliver batch: %v", err)
+	}
+	return n
+}
+
+func TestOutboxDelivers(t *testing.T) {
+	transport := &fakeTransport{}
+	o, repo := newTestOutbox(t, transport)
+
+	if err := o.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+	if transport.sends != 0 {
+		t.Fatal("message was sent before the outbox was run")
+	}
+
+	if n := deliver(t, o); n != 1 {
+		t.Fatalf("claimed %d messages, want 1", n)
+	}
+	if row := repo.only(t); row.Status != "sent" || transport.sends != 1 {
+		t.Errorf("status = %q after %d sends, want sent after 1", row.Status, transport.sends)
+	}
+	if n := deliver(t, o); n != 0 {
+		t.Errorf("claimed %d messages after delivery, want 0", n)
+	}
+}
+
+func TestOutboxRetriesWithBackoff(t *testing.T) {
+	transport := &fakeTransport{err: errors.New("connection refused")}
+	o, repo := newTestOutbox(t, transport)
+	if err := o.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+
+	for attempt, wantDelay := range []time.Duration{time.Second * 30, time.Minute} {
+		start := time.Now()
+		deliver(t, o)
+
+		row := repo.only(t)
+		if row.Status != "pending" || row.Attempts != int32(attempt+1) || row.LastError == nil {
+			t.Fatalf("attempt %d: status = %q, attempts = %d, want pending after %d", attempt+1, row.Status, row.Attempts, attempt+1)
+		}
+		if delay := row.NextAttemptAt.Time.Sub(start); delay < wantDelay || delay > wantDelay+time.Second {
+			t.Errorf("attempt %d: retried after %s, want %s", attempt+1, delay, wantDelay)
+		}
+		// Not due before its backoff has passed.
+		if n := deliver(t, o); n != 0 {
+			t.Errorf("attempt %d: claimed %d messages before the backoff passed", attempt+1, n)
+		}
+		repo.passTime()
+	}
+
+	deliver(t, o)
+	if row := repo.only(t); row.Status != "failed" || row.Attempts != testOutboxOpts.MaxAttempts {
+		t.Errorf("status = %q after %d attempts, want failed after %d", row.Status, row.Attempts, testOutboxOpts.MaxAttempts)
+	}
+	if transport.sends != int(testOutboxOpts.MaxAttempts) {
+		t.Errorf("sent %d times, want %d", transport.sends, testOutboxOpts.MaxAttempts)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	o, _ := newTestOutbox(t, &fakeTransport{})
+
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second * 30},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: time.Minute},
+		// Would overflow without the cap.
+		{attempts: 64, want: time.Minute},
+	}
+	for _, tt := range tests {
+		if got := o.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestOutboxCircuitBreaker(t *testing.T) {
+	transport := &fakeTransport{err: errors.New("connection refused")}
+	o, repo := newTestOutbox(t, transport)
+	o.opts.MaxAttempts = 100
+
+	const messages = 8
+	for range messages {
+		if err := o.Send(context.Background(), testMessage()); err != nil {
+			t.Fatalf("failed to enqueue: %v", err)
+		}
+	}
+	start := time.Now()
+	deliver(t, o)
+
+	// The breaker opens after 5 failures, after which the server isn't tried anymore.
+	if transport.sends != 5 {
+		t.Errorf("sent %d times, want 5", transport.sends)
+	}
+	var counted, skipped int
+	for _, row := range repo.rows {
+		switch row.Attempts {
+		case 1:
+			counted++
+		case 0:
+			skipped++
+			// Skipped messages wait for the breaker to reset instead of backing off.
+			if delay := row.NextAttemptAt.Time.Sub(start); delay < time.Minute || delay > time.Minute+time.Second {
+				t.Errorf("skipped message retried after %s, want 1m", delay)
+			}
+		}
+		if row.Status != "pending" {
+			t.Errorf("status = %q, want pending", row.Status)
+		}
+	}
+	if counted != 5 || skipped != messages-5 {
+		t.Errorf("%d attempts counted & %d skipped, want 5 & %d", counted, skipped, messages-5)
+	}
+}
+
+func TestOutboxFailsMalformedPayload(t *testing.T) {
+	transport := &fakeTransport{}
+	o, repo := newTestOutbox(t, transport)
+	if _, err := repo.EnqueueEmail(context.Background(), []byte("not json")); err != nil {
+		t.Fatalf("failed to enqueue: %v", err)
+	}
+
+	deliver(t, o)
+	if row := repo.only(t); row.Status != "failed" || transport.sends != 0 {
+		t.Errorf("status = %q after %d sends, want failed without sending", row.Status, transport.sends)
+	}
+}
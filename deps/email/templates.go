@@ -0,0 +1,131 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+)
+
+const (
+	// DefaultLanguage is used when a template has no variant for the requested language.
+	DefaultLanguage = "en"
+	partialsFile    = "partials.tmpl"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("email template not found")
+)
+
+// Each template file defines a "subject", an "html" & a "text" block. Language variants are named '<name>.<lang>.tmpl', e.g. 'verify-account.es.tmpl'; '<name>.tmpl' is the English version.
+// The text & subject blocks are executed with text/template so that URLs and the like aren't HTML-escaped.
+type emailTemplate struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// Rendered is the output of an email template.
+type Rendered struct {
+	Subject  string `json:"subject"`
+	HTMLBody string `json:"html_body"`
+	TextBody string `json:"text_body"`
+}
+
+func templateKey(name string, lang string) string {
+	if lang == DefaultLanguage {
+		return name
+	}
+	return name + "." + lang
+}
+
+// parseTemplates parses all templates in the root of fsys along with the shared partials.
+func parseTemplates(fsys fs.FS) (map[string]*emailTemplate, error) {
+	files, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list email templates: %w", err)
+	}
+
+	templates := make(map[string]*emailTemplate, len(files))
+	for _, file := range files {
+		if file == partialsFile {
+			continue
+		}
+
+		html, err := htmltemplate.ParseFS(fsys, partialsFile, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse HTML email template %q: %w", file, err)
+		}
+		text, err := texttemplate.ParseFS(fsys, partialsFile, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse text email template %q: %w", file, err)
+		}
+
+		for _, block := range []string{"subject", "html", "text"} {
+			if text.Lookup(block) == nil {
+				return nil, fmt.Errorf("email template %q is missing the %q block", file, block)
+			}
+		}
+
+		templates[strings.TrimSuffix(file, path.Ext(file))] = &emailTemplate{html: html, text: text}
+	}
+
+	return templates, nil
+}
+
+// Render executes the template in the given language, falling back to English. The template gets the language of the variant as 'language', e.g. for the 'lang' attribute.
+func (ec *Client) Render(templateName string, lang string, data map[string]any) (*Rendered, error) {
+	tmpl, ok := ec.templates[templateKey(templateName, lang)]
+	if !ok {
+		tmpl, ok = ec.templates[templateName]
+		lang = DefaultLanguage
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrTemplateNotFound, templateName)
+	}
+
+	data = maps.Clone(data)
+	if data == nil {
+		data = map[string]any{}
+	}
+	data["language"] = lang
+
+	var subject, html, text bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		// '%q' prints in quotes
+		return nil, fmt.Errorf("failed to execute subject of template %q: %w", templateName, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return nil, fmt.Errorf("failed to execute HTML of template %q: %w", templateName, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return nil, fmt.Errorf("failed to execute text of template %q: %w", templateName, err)
+	}
+
+	return &Rendered{
+		Subject:  strings.TrimSpace(subject.String()),
+		HTMLBody: strings.TrimSpace(html.String()),
+		TextBody: strings.TrimSpace(text.String()),
+	}, nil
+}
+
+// Templates returns the template names mapped to the languages they have variants for, sorted.
+func (ec *Client) Templates() map[string][]string {
+	out := make(map[string][]string)
+	for key := range ec.templates {
+		name, lang, ok := strings.Cut(key, ".")
+		if !ok {
+			lang = DefaultLanguage
+		}
+		out[name] = append(out[name], lang)
+	}
+	for _, langs := range out {
+		slices.Sort(langs)
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package email
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/rohitxdev/go-api/assets"
+)
+
+func newTestClient(t *testing.T, transport Transport) *Client {
+	t.Helper()
+
+	templatesFS, err := fs.Sub(assets.FS, "templates/emails")
+	if err != nil {
+		t.Fatalf("failed to get email templates: %v", err)
+	}
+	ec, err := New(transport, &Sender{Address: "noreply@example.com", Name: "go-api"}, templatesFS)
+	if err != nil {
+		t.Fatalf("failed to create email client: %v", err)
+	}
+	return ec
+}
+
+func TestRenderSetsLanguageOfVariant(t *testing.T) {
+	ec := newTestClient(t, NewMemoryTransport(0))
+	data := map[string]any{"code": "ABC123", "validMinutes": 10, "year": 2026}
+
+	for name, langs := range ec.Templates() {
+		for _, lang := range langs {
+			rendered, err := ec.Render(name, lang, data)
+			if err != nil {
+				t.Fatalf("failed to render %s in %s: %v", name, lang, err)
+			}
+			if want := `<html lang="` + lang + `">`; !strings.HasPrefix(rendered.HTMLBody, want) {
+				t.Errorf("%s in %s: HTML starts with %.40q, want %q", name, lang, rendered.HTMLBody, want)
+			}
+		}
+	}
+
+	// Languages without a variant get the English one, which must not claim to be in the requested language.
+	rendered, err := ec.Render("auth-otp", "ja", data)
+	if err != nil {
+		t.Fatalf("failed to render fallback: %v", err)
+	}
+	if !strings.HasPrefix(rendered.HTMLBody, `<html lang="en">`) {
+		t.Errorf("fallback HTML starts with %.40q, want lang en", rendered.HTMLBody)
+	}
+	if _, ok := data["language"]; ok {
+		t.Error("Render modified the data of the caller")
+	}
+}
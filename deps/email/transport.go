@@ -0,0 +1,298 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+	"gopkg.in/gomail.v2"
+)
+
+const (
+	TransportSMTP   = "smtp"
+	TransportFile   = "file"
+	TransportMemory = "memory"
+)
+
+// Message is a fully resolved email, ready to be handed over to a transport.
+type Message struct {
+	FromAddress string
+	FromName    string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	// Extra headers like 'List-Unsubscribe'.
+	Headers  map[string][]string
+	TextBody string
+	// Sent as a 'multipart/alternative' part next to the text body when set.
+	HTMLBody    string
+	Attachments []Attachment
+}
+
+// compose builds the MIME message for the transports that need the wire format.
+func (m *Message) compose() *gomail.Message {
+	msg := gomail.NewMessage()
+
+	msg.SetHeaders(map[string][]string{
+		"From":    {msg.FormatAddress(m.FromAddress, m.FromName)},
+		"Subject": {m.Subject},
+		"To":      m.To,
+	})
+	if len(m.Cc) > 0 {
+		msg.SetHeader("Cc", m.Cc...)
+	}
+	if len(m.Bcc) > 0 {
+		msg.SetHeader("Bcc", m.Bcc...)
+	}
+	for k, v := range m.Headers {
+		msg.SetHeader(k, v...)
+	}
+
+	msg.SetBody("text/plain", m.TextBody)
+	if m.HTMLBody != "" {
+		msg.AddAlternative("text/html", m.HTMLBody)
+	}
+
+	for _, attachment := range m.Attachments {
+		msg.Attach(
+			attachment.Filename,
+			gomail.SetCopyFunc(func(w io.Writer) error {
+				_, err := w.Write(attachment.Data)
+				return err
+			}),
+			gomail.SetHeader(map[string][]string{
+				"Content-Type": {attachment.ContentType},
+			}),
+		)
+	}
+
+	return msg
+}
+
+// Transport delivers messages composed by the client.
+type Transport interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// defaultSMTPTimeout must be shorter than the lease of the outbox, so that a message isn't claimed by another worker while it is still being sent.
+const defaultSMTPTimeout = time.Second * 30
+
+// SMTPTransport delivers messages to an SMTP server, over a new connection for each message.
+type SMTPTransport struct {
+	creds       SMTPCredentials
+	implicitTLS bool
+}
+
+func NewSMTPTransport(sc *SMTPCredentials) (*SMTPTransport, error) {
+	t := SMTPTransport{creds: *sc}
+
+	switch sc.TLSMode {
+	case TLSModeStartTLS, "":
+		t.implicitTLS = false
+	case TLSModeTLS:
+		t.implicitTLS = true
+	default:
+		return nil, fmt.Errorf("invalid SMTP TLS mode %q", sc.TLSMode)
+	}
+	if t.creds.Timeout <= 0 {
+		t.creds.Timeout = defaultSMTPTimeout
+	}
+
+	return &t, nil
+}
+
+// Send delivers the message within the timeout, or before ctx is done if that is sooner. A stuck server fails the send instead of blocking it.
+func (t *SMTPTransport) Send(ctx context.Context, msg *Message) error {
+	deadline := time.Now().Add(t.creds.Timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(t.creds.Host, strconv.Itoa(t.creds.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	defer conn.Close()
+	// Bounds every read & write of the conversation.
+	if err = conn.SetDeadline(deadline); err != nil {
+		return fmt.Errorf("failed to set SMTP deadline: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	tlsConfig := &tls.Config{ServerName: t.creds.Host}
+	if t.implicitTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	client, err := smtp.NewClient(conn, t.creds.Host)
+	if err != nil {
+		return fmt.Errorf("failed to greet SMTP server: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && !t.implicitTLS {
+		if err = client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if auth := t.auth(client); auth != nil {
+		if err = client.Auth(auth); err != nil {
+			return fmt.Errorf("failed to authenticate to SMTP server: %w", err)
+		}
+	}
+
+	err = gomail.Send(gomail.SendFunc(func(from string, to []string, body io.WriterTo) error {
+		if err := client.Mail(from); err != nil {
+			return err
+		}
+		for _, addr := range to {
+			if err := client.Rcpt(addr); err != nil {
+				return err
+			}
+		}
+		w, err := client.Data()
+		if err != nil {
+			return err
+		}
+		if _, err = body.WriteTo(w); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}), msg.compose())
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// auth picks the strongest mechanism the server offers, like gomail. It returns nil if there are no credentials or the server doesn't support authentication.
+func (t *SMTPTransport) auth(client *smtp.Client) smtp.Auth {
+	if t.creds.Username == "" {
+		return nil
+	}
+	ok, mechanisms := client.Extension("AUTH")
+	if !ok {
+		return nil
+	}
+
+	switch {
+	case strings.Contains(mechanisms, "CRAM-MD5"):
+		return smtp.CRAMMD5Auth(t.creds.Username, t.creds.Password)
+	case strings.Contains(mechanisms, "LOGIN") && !strings.Contains(mechanisms, "PLAIN"):
+		return &loginAuth{username: t.creds.Username, password: t.creds.Password}
+	default:
+		return smtp.PlainAuth("", t.creds.Username, t.creds.Password, t.creds.Host)
+	}
+}
+
+// loginAuth implements the LOGIN mechanism, which 'net/smtp' lacks. Like 'smtp.PlainAuth', it is only used over TLS.
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:":
+		return []byte(a.username), nil
+	case "Password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected SMTP challenge %q", fromServer)
+	}
+}
+
+// FileTransport writes every message as an '.eml' file into a directory. Meant for local development.
+type FileTransport struct {
+	dir string
+}
+
+func NewFileTransport(dir string) (*FileTransport, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create email directory: %w", err)
+	}
+	return &FileTransport{dir: dir}, nil
+}
+
+func (t *FileTransport) Send(ctx context.Context, msg *Message) error {
+	var buf bytes.Buffer
+	if _, err := msg.compose().WriteTo(&buf); err != nil {
+		return fmt.Errorf("failed to compose email: %w", err)
+	}
+
+	// ULIDs sort lexically by time, so the directory listing reads as an inbox.
+	path := filepath.Join(t.dir, ulid.Make().String()+".eml")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write email file: %w", err)
+	}
+	return nil
+}
+
+// MemoryTransport captures messages in memory so they can be inspected, e.g. in tests.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []*Message
+	limit    int
+}
+
+// NewMemoryTransport creates a transport that keeps the latest 'limit' messages. A limit of 0 keeps all messages.
+func NewMemoryTransport(limit int) *MemoryTransport {
+	return &MemoryTransport{limit: limit}
+}
+
+func (t *MemoryTransport) Send(ctx context.Context, msg *Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = append(t.messages, msg)
+	if t.limit > 0 && len(t.messages) > t.limit {
+		t.messages = t.messages[len(t.messages)-t.limit:]
+	}
+	return nil
+}
+
+// Messages returns captured messages, oldest first.
+func (t *MemoryTransport) Messages() []*Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]*Message, len(t.messages))
+	copy(out, t.messages)
+	return out
+}
+
+// Reset discards all captured messages.
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.messages = nil
+}
@@ -0,0 +1,254 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTPServer accepts messages like an SMTP server without extensions & records them.
+type fakeSMTPServer struct {
+	host string
+	port int
+	// stall makes the server accept connections without ever answering, like a stuck server.
+	stall bool
+
+	mu       sync.Mutex
+	messages []fakeSMTPMessage
+}
+
+type fakeSMTPMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTPServer(t *testing.T, stall bool) *fakeSMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	addr := ln.Addr().(*net.TCPAddr)
+	s := &fakeSMTPServer{host: addr.IP.String(), port: addr.Port, stall: stall}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	if s.stall {
+		_, _ = io.Copy(io.Discard, r)
+		return
+	}
+	reply := func(line string) {
+		fmt.Fprintf(conn, "%s\r\n", line)
+	}
+
+	reply("220 fake ESMTP")
+	var msg fakeSMTPMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250 fake")
+		case "MAIL":
+			msg = fakeSMTPMessage{from: addressOf(arg)}
+			reply("250 OK")
+		case "RCPT":
+			msg.to = append(msg.to, addressOf(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				line, err = r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			msg.data = data.String()
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+// addressOf returns the address of a 'MAIL FROM:<...>' or 'RCPT TO:<...>' argument.
+func addressOf(arg string) string {
+	_, addr, _ := strings.Cut(arg, "<")
+	addr, _, _ = strings.Cut(addr, ">")
+	return addr
+}
+
+func (s *fakeSMTPServer) received() []fakeSMTPMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]fakeSMTPMessage(nil), s.messages...)
+}
+
+func testMessage() *Message {
+	return &Message{
+		FromAddress: "noreply@example.com",
+		FromName:    "go-api",
+		To:          []string{"to@example.com"},
+		Cc:          []string{"cc@example.com"},
+		Bcc:         []string{"bcc@example.com"},
+		Subject:     "Hello",
+		TextBody:    "Hello in text",
+		HTMLBody:    "<p>Hello in HTML</p>",
+	}
+}
+
+func TestSMTPTransportSends(t *testing.T) {
+	srv := newFakeSMTPServer(t, false)
+	transport, err := NewSMTPTransport(&SMTPCredentials{Host: srv.host, Port: srv.port, TLSMode: TLSModeStartTLS})
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+
+	if err = transport.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	received := srv.received()
+	if len(received) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(received))
+	}
+	msg := received[0]
+	if msg.from != "noreply@example.com" {
+		t.Errorf("from = %q, want %q", msg.from, "noreply@example.com")
+	}
+	if got := strings.Join(msg.to, ","); got != "to@example.com,cc@example.com,bcc@example.com" {
+		t.Errorf("recipients = %q, want to, cc & bcc", got)
+	}
+	for _, want := range []string{"Subject: Hello", "multipart/alternative", "Hello in text", "<p>Hello in HTML</p>"} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("message doesn't contain %q:\n%s", want, msg.data)
+		}
+	}
+	// Bcc recipients must not see each other.
+	if strings.Contains(msg.data, "bcc@example.com") {
+		t.Errorf("message reveals the Bcc recipient:\n%s", msg.data)
+	}
+}
+
+func TestNewSMTPTransportRejectsTLSMode(t *testing.T) {
+	if _, err := NewSMTPTransport(&SMTPCredentials{Host: "localhost", Port: 25, TLSMode: "ssl"}); err == nil {
+		t.Error("invalid TLS mode was accepted")
+	}
+}
+
+func TestFileTransportWritesEML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "emails")
+	transport, err := NewFileTransport(dir)
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+
+	for range 2 {
+		if err = transport.Send(context.Background(), testMessage()); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("found %d .eml files, want 2 (%v)", len(files), err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatalf("failed to read email file: %v", err)
+	}
+	for _, want := range []string{"Subject: Hello", "To: to@example.com", "Hello in text", "<p>Hello in HTML</p>"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("email file doesn't contain %q", want)
+		}
+	}
+}
+
+func TestMemoryTransportKeepsLatest(t *testing.T) {
+	transport := NewMemoryTransport(2)
+	for i := range 3 {
+		msg := testMessage()
+		msg.Subject = strconv.Itoa(i)
+		if err := transport.Send(context.Background(), msg); err != nil {
+			t.Fatalf("failed to send: %v", err)
+		}
+	}
+
+	messages := transport.Messages()
+	if len(messages) != 2 || messages[0].Subject != "1" || messages[1].Subject != "2" {
+		t.Fatalf("kept %d messages, want the latest 2 oldest first", len(messages))
+	}
+
+	transport.Reset()
+	if n := len(transport.Messages()); n != 0 {
+		t.Errorf("kept %d messages after reset, want 0", n)
+	}
+}
+
+func TestSMTPTransportTimesOut(t *testing.T) {
+	srv := newFakeSMTPServer(t, true)
+	transport, err := NewSMTPTransport(&SMTPCredentials{Host: srv.host, Port: srv.port, Timeout: time.Millisecond * 200})
+	if err != nil {
+		t.Fatalf("failed to create transport: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- transport.Send(context.Background(), testMessage())
+	}()
+	select {
+	case err = <-done:
+		if err == nil {
+			t.Error("send to a stuck server succeeded")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("send to a stuck server didn't time out")
+	}
+}
+
+func TestSMTPTimeoutIsShorterThanLease(t *testing.T) {
+	if defaultSMTPTimeout >= defaultOutboxOpts.Lease {
+		t.Errorf("SMTP timeout %s isn't shorter than the outbox lease %s", defaultSMTPTimeout, defaultOutboxOpts.Lease)
+	}
+}
@@ -0,0 +1,49 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/sync/singleflight"
+)
+
+// Providers discovers OpenID Connect providers by their issuer URL & caches them, so that their metadata is fetched once per process. Cached providers also cache the signing keys of the issuer, refetching them when they rotate.
+type Providers struct {
+	mu        sync.RWMutex
+	providers map[string]*oidc.Provider
+	sf        singleflight.Group
+}
+
+func NewProviders() *Providers {
+	return &Providers{
+		providers: map[string]*oidc.Provider{},
+	}
+}
+
+// Get returns the provider of the issuer URL, discovering it on first use. Failed discoveries aren't cached.
+func (p *Providers) Get(ctx context.Context, issuerURL string) (*oidc.Provider, error) {
+	p.mu.RLock()
+	provider, ok := p.providers[issuerURL]
+	p.mu.RUnlock()
+	if ok {
+		return provider, nil
+	}
+
+	v, err, _ := p.sf.Do(issuerURL, func() (any, error) {
+		provider, err := oidc.NewProvider(ctx, issuerURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+		}
+
+		p.mu.Lock()
+		p.providers[issuerURL] = provider
+		p.mu.Unlock()
+		return provider, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*oidc.Provider), nil
+}
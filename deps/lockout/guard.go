@@ -0,0 +1,115 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	cooldownKey = "lockout:cooldown:"
+	failuresKey = "lockout:failures:"
+	lockedKey   = "lockout:locked:"
+)
+
+type GuardOpts struct {
+	// Minimum time between two attempts to issue a credential for the same subject, e.g. sending an OTP.
+	Cooldown time.Duration
+	// Every this many consecutive failures lock the subject out.
+	MaxFailures int64
+	// The first lockout lasts this long, each one after it twice as long as the previous, up to the max.
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+	// Failures are forgotten after this long without another one.
+	FailureWindow time.Duration
+}
+
+var defaultGuardOpts = GuardOpts{
+	Cooldown:      time.Minute,
+	MaxFailures:   5,
+	BaseLockout:   time.Minute,
+	MaxLockout:    time.Hour * 24,
+	FailureWindow: time.Hour * 24,
+}
+
+// Guard protects credentials of a subject, e.g. an email address, from being guessed or spammed. Its state is kept in redis so that it is shared by all instances.
+type Guard struct {
+	rdb  *redis.Client
+	opts GuardOpts
+}
+
+func NewGuard(rdb *redis.Client, opts *GuardOpts) *Guard {
+	if opts == nil {
+		opts = &defaultGuardOpts
+	}
+
+	return &Guard{
+		rdb:  rdb,
+		opts: *opts,
+	}
+}
+
+// LockedFor returns how much longer the subject is locked out, or 0 if it isn't.
+func (g *Guard) LockedFor(ctx context.Context, subject string) (time.Duration, error) {
+	ttl, err := g.rdb.PTTL(ctx, lockedKey+subject).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get lockout: %w", err)
+	}
+	// Negative for missing keys.
+	return max(ttl, 0), nil
+}
+
+// StartCooldown starts the cooldown of the subject. If it is already cooling down, it returns how much longer that lasts instead.
+func (g *Guard) StartCooldown(ctx context.Context, subject string) (time.Duration, error) {
+	key := cooldownKey + subject
+
+	ok, err := g.rdb.SetNX(ctx, key, 1, g.opts.Cooldown).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start cooldown: %w", err)
+	}
+	if ok {
+		return 0, nil
+	}
+
+	ttl, err := g.rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get cooldown: %w", err)
+	}
+	return max(ttl, 0), nil
+}
+
+// RecordFailure counts a failed attempt of the subject & returns the lockout it triggered, or 0 if it didn't trigger one.
+func (g *Guard) RecordFailure(ctx context.Context, subject string) (time.Duration, error) {
+	pipe := g.rdb.TxPipeline()
+	failures := pipe.Incr(ctx, failuresKey+subject)
+	pipe.Expire(ctx, failuresKey+subject, g.opts.FailureWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to record failure: %w", err)
+	}
+
+	n := failures.Val()
+	if n%g.opts.MaxFailures != 0 {
+		return 0, nil
+	}
+
+	lockout := g.opts.MaxLockout
+	// Doublings past 30 would overflow, & exceed any sensible max anyway.
+	if doublings := n/g.opts.MaxFailures - 1; doublings < 30 {
+		lockout = min(g.opts.BaseLockout<<doublings, g.opts.MaxLockout)
+	}
+
+	if err := g.rdb.Set(ctx, lockedKey+subject, 1, lockout).Err(); err != nil {
+		return 0, fmt.Errorf("failed to lock out: %w", err)
+	}
+	return lockout, nil
+}
+
+// Reset forgets the failures of the subject after a successful attempt.
+func (g *Guard) Reset(ctx context.Context, subject string) error {
+	if err := g.rdb.Del(ctx, failuresKey+subject).Err(); err != nil {
+		return fmt.Errorf("failed to reset failures: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestGuard(t *testing.T, opts *GuardOpts) (*Guard, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return NewGuard(rdb, opts), mr
+}
+
+func TestRecordFailureLocksOutProgressively(t *testing.T) {
+	g, _ := newTestGuard(t, &GuardOpts{
+		MaxFailures:   2,
+		BaseLockout:   time.Minute,
+		MaxLockout:    time.Minute * 3,
+		FailureWindow: time.Hour,
+	})
+	ctx := context.Background()
+
+	// Every 2nd failure locks out twice as long as the previous lockout, up to 3m.
+	want := []time.Duration{0, time.Minute, 0, time.Minute * 2, 0, time.Minute * 3, 0, time.Minute * 3}
+	for i, wantLockout := range want {
+		lockout, err := g.RecordFailure(ctx, "jane@example.com")
+		if err != nil {
+			t.Fatalf("failure %d: failed to record: %v", i+1, err)
+		}
+		if lockout != wantLockout {
+			t.Errorf("failure %d: lockout = %s, want %s", i+1, lockout, wantLockout)
+		}
+	}
+
+	lockedFor, err := g.LockedFor(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatalf("failed to get lockout: %v", err)
+	}
+	if lockedFor != time.Minute*3 {
+		t.Errorf("locked for %s, want %s", lockedFor, time.Minute*3)
+	}
+	if lockedFor, _ = g.LockedFor(ctx, "john@example.com"); lockedFor != 0 {
+		t.Errorf("other subject locked for %s, want 0", lockedFor)
+	}
+
+	// Starts over from the base lockout.
+	if err = g.Reset(ctx, "jane@example.com"); err != nil {
+		t.Fatalf("failed to reset: %v", err)
+	}
+	g.RecordFailure(ctx, "jane@example.com")
+	if lockout, _ := g.RecordFailure(ctx, "jane@example.com"); lockout != time.Minute {
+		t.Errorf("lockout after reset = %s, want %s", lockout, time.Minute)
+	}
+}
+
+func TestRecordFailureForgetsOldFailures(t *testing.T) {
+	g, mr := newTestGuard(t, &GuardOpts{
+		MaxFailures:   2,
+		BaseLockout:   time.Minute,
+		MaxLockout:    time.Hour,
+		FailureWindow: time.Hour,
+	})
+	ctx := context.Background()
+
+	g.RecordFailure(ctx, "jane@example.com")
+	mr.FastForward(time.Hour)
+	if lockout, _ := g.RecordFailure(ctx, "jane@example.com"); lockout != 0 {
+		t.Errorf("lockout = %s, want none after the failure window", lockout)
+	}
+}
+
+func TestStartCooldown(t *testing.T) {
+	g, mr := newTestGuard(t, &GuardOpts{Cooldown: time.Minute})
+	ctx := context.Background()
+
+	if wait, err := g.StartCooldown(ctx, "jane@example.com"); err != nil || wait != 0 {
+		t.Fatalf("first: wait = %s, err = %v, want to start the cooldown", wait, err)
+	}
+	mr.FastForward(time.Second * 20)
+	if wait, _ := g.StartCooldown(ctx, "jane@example.com"); wait != time.Second*40 {
+		t.Errorf("during cooldown: wait = %s, want %s", wait, time.Second*40)
+	}
+	mr.FastForward(time.Second * 40)
+	if wait, _ := g.StartCooldown(ctx, "jane@example.com"); wait != 0 {
+		t.Errorf("after cooldown: wait = %s, want 0", wait)
+	}
+}
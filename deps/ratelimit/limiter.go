@@ -0,0 +1,172 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "ratelimit:"
+
+// Result is the outcome of a request against a limit.
+type Result struct {
+	Allowed bool
+	Limit   int
+	// Requests that can still be made right away.
+	Remaining int
+	// How long until the request would be allowed. Zero if it was allowed.
+	RetryAfter time.Duration
+	// How long until the limit has fully replenished.
+	ResetAfter time.Duration
+}
+
+// gcraScript applies the generic cell rate algorithm to the theoretical arrival time (TAT) stored in KEYS[1], using the redis clock so that every instance agrees on it.
+//
+// ARGV[1] is the emission interval & ARGV[2] the period, both in microseconds. It returns whether the request is allowed, the remaining requests, & the retry & reset durations in microseconds.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]))
+if tat == nil or tat < now then
+	tat = now
+end
+
+local newTat = tat + interval
+local allowAt = newTat - period
+if now < allowAt then
+	return {0, 0, allowAt - now, tat - now}
+end
+
+redis.call('SET', KEYS[1], newTat, 'PX', math.ceil((newTat - now) / 1000))
+return {1, math.floor((period - (newTat - now)) / interval), 0, newTat - now}
+`)
+
+// Limiter limits requests with GCRA, which spreads requests evenly over the period while allowing bursts of up to the whole limit. State is kept in redis so that limits are shared by all instances. While redis is unreachable, each instance limits requests on its own.
+type Limiter struct {
+	rdb      *redis.Client
+	logger   *slog.Logger
+	local    *localStore
+	degraded atomic.Bool
+}
+
+func New(rdb *redis.Client, logger *slog.Logger) *Limiter {
+	return &Limiter{
+		rdb:    rdb,
+		logger: logger,
+		local:  newLocalStore(maxLocalKeys),
+	}
+}
+
+// Allow counts a request against the limit of requests per period for the key.
+func (l *Limiter) Allow(ctx context.Context, key string, limit int, period time.Duration) (*Result, error) {
+	if limit <= 0 || period <= 0 {
+		return nil, fmt.Errorf("invalid rate limit %d/%s", limit, period)
+	}
+	interval := period / time.Duration(limit)
+
+	res, err := gcraScript.Run(ctx, l.rdb, []string{keyPrefix + key}, interval.Microseconds(), period.Microseconds()).Int64Slice()
+	if err != nil {
+		if l.degraded.CompareAndSwap(false, true) {
+			l.logger.Warn("rate limiting locally, redis is unreachable", slog.String("error", err.Error()))
+		}
+		return l.local.allow(key, limit, interval, period), nil
+	}
+	if l.degraded.CompareAndSwap(true, false) {
+		l.logger.Info("rate limiting with redis again")
+	}
+
+	return &Result{
+		Allowed:    res[0] == 1,
+		Limit:      limit,
+		Remaining:  int(res[1]),
+		RetryAfter: time.Duration(res[2]) * time.Microsecond,
+		ResetAfter: time.Duration(res[3]) * time.Microsecond,
+	}, nil
+}
+
+// maxLocalKeys bounds the memory of the fallback.
+const maxLocalKeys = 100_000
+
+// localStore is the in-process fallback of the redis script. It keeps the TATs of up to size keys & evicts the least recently used one beyond that, so that a flood of clients can't exhaust memory. Keys whose TAT has passed are dropped along the way, as they are limited the same as unseen keys.
+type localStore struct {
+	mu   sync.Mutex
+	size int
+	// Most recently used first.
+	lru  *list.List
+	keys map[string]*list.Element
+}
+
+type localEntry struct {
+	key string
+	tat time.Time
+}
+
+func newLocalStore(size int) *localStore {
+	return &localStore{
+		size: size,
+		lru:  list.New(),
+		keys: make(map[string]*list.Element),
+	}
+}
+
+func (s *localStore) allow(key string, limit int, interval time.Duration, period time.Duration) *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for back := s.lru.Back(); back != nil && back.Value.(*localEntry).tat.Before(now); back = s.lru.Back() {
+		s.remove(back)
+	}
+
+	tat := now
+	elem, ok := s.keys[key]
+	if ok && elem.Value.(*localEntry).tat.After(now) {
+		tat = elem.Value.(*localEntry).tat
+	}
+
+	newTat := tat.Add(interval)
+	allowAt := newTat.Add(-period)
+	if now.Before(allowAt) {
+		if ok {
+			s.lru.MoveToFront(elem)
+		}
+		return &Result{
+			Allowed:    false,
+			Limit:      limit,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}
+	}
+
+	if ok {
+		elem.Value.(*localEntry).tat = newTat
+		s.lru.MoveToFront(elem)
+	} else {
+		s.keys[key] = s.lru.PushFront(&localEntry{key: key, tat: newTat})
+		if s.lru.Len() > s.size {
+			s.remove(s.lru.Back())
+		}
+	}
+	return &Result{
+		Allowed:    true,
+		Limit:      limit,
+		Remaining:  int((period - newTat.Sub(now)) / interval),
+		ResetAfter: newTat.Sub(now),
+	}
+}
+
+func (s *localStore) remove(elem *list.Element) {
+	s.lru.Remove(elem)
+	delete(s.keys, elem.Value.(*localEntry).key)
+}
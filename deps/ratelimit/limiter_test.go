@@ -0,0 +1,117 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestLimiter(t *testing.T) (*Limiter, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return New(rdb, slog.New(slog.NewTextHandler(io.Discard, nil))), mr
+}
+
+func TestAllow(t *testing.T) {
+	limiter, mr := newTestLimiter(t)
+	now := time.Now()
+	mr.SetTime(now)
+
+	// 3 requests per 3s, so one replenishes every second.
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    Result
+	}{
+		{name: "first", want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: time.Second}},
+		{name: "burst", want: Result{Allowed: true, Limit: 3, Remaining: 1, ResetAfter: time.Second * 2}},
+		{name: "last of the burst", want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: time.Second * 3}},
+		{name: "used up", want: Result{Allowed: false, Limit: 3, Remaining: 0, RetryAfter: time.Second, ResetAfter: time.Second * 3}},
+		{name: "still used up", advance: time.Millisecond * 500, want: Result{Allowed: false, Limit: 3, Remaining: 0, RetryAfter: time.Millisecond * 500, ResetAfter: time.Millisecond * 2500}},
+		{name: "replenished", advance: time.Millisecond * 500, want: Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: time.Second * 3}},
+		{name: "fully replenished", advance: time.Second * 3, want: Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: time.Second}},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		mr.SetTime(now)
+
+		got, err := limiter.Allow(context.Background(), "client", 3, time.Second*3)
+		if err != nil {
+			t.Fatalf("%s: failed to allow: %v", step.name, err)
+		}
+		if *got != step.want {
+			t.Errorf("%s: got %+v, want %+v", step.name, *got, step.want)
+		}
+	}
+
+	// Keys are limited separately.
+	if got, err := limiter.Allow(context.Background(), "other", 3, time.Second*3); err != nil || !got.Allowed {
+		t.Errorf("other key: got %+v, %v, want it allowed", got, err)
+	}
+}
+
+func TestAllowFallsBackLocally(t *testing.T) {
+	mr := miniredis.RunT(t)
+	// Fails right away instead of retrying.
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	mr.Close()
+	t.Cleanup(func() { rdb.Close() })
+	limiter := New(rdb, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for i, wantAllowed := range []bool{true, true, false} {
+		got, err := limiter.Allow(context.Background(), "client", 2, time.Minute)
+		if err != nil {
+			t.Fatalf("request %d: failed to allow: %v", i+1, err)
+		}
+		if got.Allowed != wantAllowed {
+			t.Errorf("request %d: allowed = %t, want %t", i+1, got.Allowed, wantAllowed)
+		}
+	}
+	if !limiter.degraded.Load() {
+		t.Error("limiter isn't marked as degraded")
+	}
+}
+
+func TestLocalStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := newLocalStore(2)
+	allow := func(key string) bool {
+		return s.allow(key, 1, time.Minute, time.Minute).Allowed
+	}
+
+	allow("a")
+	allow("b")
+	// Used more recently than b, even though it was denied.
+	if allow("a") {
+		t.Fatal("a was allowed twice")
+	}
+	allow("c")
+
+	if len(s.keys) != 2 {
+		t.Errorf("%d keys, want 2", len(s.keys))
+	}
+	if allow("a") {
+		t.Error("a was evicted, want b evicted")
+	}
+	if !allow("b") {
+		t.Error("b wasn't evicted")
+	}
+}
+
+func TestLocalStoreDropsPassedKeys(t *testing.T) {
+	s := newLocalStore(100)
+	s.allow("a", 1, time.Millisecond, time.Millisecond)
+	time.Sleep(time.Millisecond * 2)
+	s.allow("b", 1, time.Minute, time.Minute)
+
+	if _, ok := s.keys["a"]; ok || len(s.keys) != 1 {
+		t.Errorf("keys = %v, want only b", s.keys)
+	}
+}
@@ -0,0 +1,75 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/oklog/ulid/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Merge decides how the entries of a failed flush are merged with the entries written to the buffer in the meantime.
+type Merge string
+
+const (
+	// MergeSum adds up the values, for buffers of counters.
+	MergeSum Merge = "sum"
+	// MergeMax keeps the larger value, for buffers of timestamps.
+	MergeMax Merge = "max"
+)
+
+// restoreScript merges the hash at KEYS[1] into the hash at KEYS[2] as ARGV[1] says & deletes it.
+var restoreScript = redis.NewScript(`
+local entries = redis.call('HGETALL', KEYS[1])
+for i = 1, #entries, 2 do
+	local field, value = entries[i], entries[i + 1]
+	if ARGV[1] == 'sum' then
+		redis.call('HINCRBY', KEYS[2], field, value)
+	else
+		local current = redis.call('HGET', KEYS[2], field)
+		if not current or tonumber(value) > tonumber(current) then
+			redis.call('HSET', KEYS[2], field, value)
+		end
+	end
+end
+redis.call('DEL', KEYS[1])
+return #entries / 2
+`)
+
+// DrainHash passes the entries of the hash at key to fn & deletes them once fn succeeds, which buffers are flushed with. The hash is renamed first, so that writes made in the meantime go to a new hash & other instances don't drain the same entries. If fn fails, the entries are merged back into the buffer with merge, so that the next flush retries them. fn isn't called if the hash doesn't exist.
+func DrainHash(ctx context.Context, rdb *redis.Client, key string, merge Merge, fn func(entries map[string]string) error) error {
+	drainKey := key + ":flush:" + ulid.Make().String()
+	if err := rdb.Rename(ctx, key, drainKey).Err(); err != nil {
+		if isNoSuchKey(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to claim buffer: %w", err)
+	}
+
+	entries, err := rdb.HGetAll(ctx, drainKey).Result()
+	if err != nil {
+		err = fmt.Errorf("failed to read buffer: %w", err)
+	} else {
+		err = fn(entries)
+	}
+	if err != nil {
+		// Restored even if ctx was cancelled, as the entries would be lost otherwise.
+		if restoreErr := restoreScript.Run(context.WithoutCancel(ctx), rdb, []string{drainKey, key}, string(merge)).Err(); restoreErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to restore buffer: %w", restoreErr))
+		}
+		return err
+	}
+
+	if err = rdb.Del(context.WithoutCancel(ctx), drainKey).Err(); err != nil {
+		return fmt.Errorf("failed to delete flushed buffer: %w", err)
+	}
+	return nil
+}
+
+// isNoSuchKey reports whether RENAME failed because the key doesn't exist.
+func isNoSuchKey(err error) bool {
+	var redisErr redis.Error
+	return errors.As(err, &redisErr) && strings.Contains(redisErr.Error(), "no such key")
+}
@@ -0,0 +1,105 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestClient(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb, mr
+}
+
+func TestDrainHash(t *testing.T) {
+	rdb, mr := newTestClient(t)
+	ctx := context.Background()
+	rdb.HSet(ctx, "buffer", "a", 1, "b", 2)
+
+	var got map[string]string
+	if err := DrainHash(ctx, rdb, "buffer", MergeSum, func(entries map[string]string) error {
+		got = entries
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to drain: %v", err)
+	}
+
+	if want := map[string]string{"a": "1", "b": "2"}; !maps.Equal(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+	if keys := mr.Keys(); len(keys) != 0 {
+		t.Errorf("keys left after the flush: %v", keys)
+	}
+}
+
+func TestDrainHashSkipsMissingHash(t *testing.T) {
+	rdb, _ := newTestClient(t)
+
+	if err := DrainHash(context.Background(), rdb, "buffer", MergeSum, func(entries map[string]string) error {
+		t.Error("fn was called without a buffer")
+		return nil
+	}); err != nil {
+		t.Fatalf("failed to drain: %v", err)
+	}
+}
+
+func TestDrainHashRestoresOnFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		merge Merge
+		want  map[string]string
+	}{
+		// Counters of the failed flush are added to those counted in the meantime.
+		{name: "sum", merge: MergeSum, want: map[string]string{"older": "150", "newer": "350", "drained": "100", "written": "7"}},
+		// The latest timestamp wins.
+		{name: "max", merge: MergeMax, want: map[string]string{"older": "100", "newer": "300", "drained": "100", "written": "7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rdb, mr := newTestClient(t)
+			ctx := context.Background()
+			rdb.HSet(ctx, "buffer", "older", 100, "newer", 50, "drained", 100)
+
+			errFlush := errors.New("postgres is down")
+			err := DrainHash(ctx, rdb, "buffer", tt.merge, func(entries map[string]string) error {
+				// Written while the flush is in progress.
+				rdb.HSet(ctx, "buffer", "older", 50, "newer", 300, "written", 7)
+				return errFlush
+			})
+			if !errors.Is(err, errFlush) {
+				t.Fatalf("err = %v, want %v", err, errFlush)
+			}
+
+			got, err := rdb.HGetAll(ctx, "buffer").Result()
+			if err != nil {
+				t.Fatalf("failed to read buffer: %v", err)
+			}
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("buffer = %v, want %v", got, tt.want)
+			}
+			if keys := mr.Keys(); len(keys) != 1 {
+				t.Errorf("keys = %v, want only the buffer", keys)
+			}
+
+			// Retried by the next flush.
+			var retried map[string]string
+			if err = DrainHash(ctx, rdb, "buffer", tt.merge, func(entries map[string]string) error {
+				retried = entries
+				return nil
+			}); err != nil {
+				t.Fatalf("failed to drain: %v", err)
+			}
+			if !maps.Equal(retried, tt.want) {
+				t.Errorf("retried entries = %v, want %v", retried, tt.want)
+			}
+		})
+	}
+}
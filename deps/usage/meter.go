@@ -0,0 +1,208 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/redis/go-redis/v9"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	redisutil "github.com/rohitxdev/go-api/deps/redis"
+)
+
+// Metered quantities, named after the plan limits that cap them.
+const (
+	MetricAPIRequests = config.LimitAPIRequests
+)
+
+const (
+	// Hash of '<account ID>:<metric>:<period start>' -> quantity added since the last flush, waiting to be flushed to postgres.
+	bufferKey  = "usage:pending"
+	counterKey = "usage:"
+	// Counters outlive their period by a few days, so that late requests of the previous period still find theirs.
+	counterTTL = time.Hour * 24 * 40
+)
+
+// Metrics returns the metered quantities.
+func Metrics() []string {
+	return []string{MetricAPIRequests}
+}
+
+// Period returns the bounds of the period that usage of the metric at t counts towards. Requests are counted per calendar month (UTC).
+func Period(metric string, t time.Time) (start time.Time, end time.Time) {
+	t = t.UTC()
+	start = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+type MeterOpts struct {
+	// How often buffered usage is written to postgres.
+	FlushInterval time.Duration
+}
+
+var defaultMeterOpts = MeterOpts{
+	FlushInterval: time.Minute,
+}
+
+// Meter counts usage per account. Counters live in redis so that metered requests don't write to postgres. Increments are also buffered & flushed to the 'usage_records' table in batches, which is what counters are restored from when redis loses them.
+type Meter struct {
+	rdb    *redis.Client
+	repo   repository.Querier
+	logger *slog.Logger
+	opts   MeterOpts
+}
+
+func NewMeter(rdb *redis.Client, repo repository.Querier, logger *slog.Logger, opts *MeterOpts) *Meter {
+	if opts == nil {
+		opts = &defaultMeterOpts
+	}
+
+	return &Meter{
+		rdb:    rdb,
+		repo:   repo,
+		logger: logger,
+		opts:   *opts,
+	}
+}
+
+func bufferField(accountID pgtype.UUID, metric string, periodStart time.Time) string {
+	return accountID.String() + ":" + metric + ":" + strconv.FormatInt(periodStart.Unix(), 10)
+}
+
+// Usage returns the usage of the metric by the account in the current period.
+func (m *Meter) Usage(ctx context.Context, accountID pgtype.UUID, metric string) (int64, error) {
+	start, _ := Period(metric, time.Now())
+	key := counterKey + bufferField(accountID, metric, start)
+
+	n, err := m.rdb.Get(ctx, key).Int64()
+	if err == nil {
+		return n, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		return 0, fmt.Errorf("failed to get usage counter: %w", err)
+	}
+
+	return m.restore(ctx, key, accountID, metric, start)
+}
+
+// Add adds n, which may be negative, to the usage of the metric by the account in the current period & returns the new usage.
+func (m *Meter) Add(ctx context.Context, accountID pgtype.UUID, metric string, n int64) (int64, error) {
+	start, _ := Period(metric, time.Now())
+	field := bufferField(accountID, metric, start)
+	key := counterKey + field
+
+	exists, err := m.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check usage counter: %w", err)
+	}
+	if exists == 0 {
+		if _, err = m.restore(ctx, key, accountID, metric, start); err != nil {
+			return 0, err
+		}
+	}
+
+	pipe := m.rdb.TxPipeline()
+	total := pipe.IncrBy(ctx, key, n)
+	pipe.Expire(ctx, key, counterTTL)
+	pipe.HIncrBy(ctx, bufferKey, field, n)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to increment usage counter: %w", err)
+	}
+
+	return total.Val(), nil
+}
+
+// restore sets the missing counter to the usage recorded in postgres. Increments that haven't been flushed yet are lost, which undercounts slightly rather than blocking the account.
+func (m *Meter) restore(ctx context.Context, key string, accountID pgtype.UUID, metric string, periodStart time.Time) (int64, error) {
+	var n int64
+	record, err := m.repo.GetUsageRecord(ctx, repository.GetUsageRecordParams{
+		AccountID:   accountID,
+		Metric:      metric,
+		PeriodStart: pgtype.Timestamptz{Time: periodStart, Valid: true},
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return 0, fmt.Errorf("failed to get usage record: %w", err)
+		}
+	} else {
+		n = record.Quantity
+	}
+
+	// Another request may have restored & incremented the counter in the meantime.
+	if err = m.rdb.SetNX(ctx, key, n, counterTTL).Err(); err != nil {
+		return 0, fmt.Errorf("failed to restore usage counter: %w", err)
+	}
+	n, err = m.rdb.Get(ctx, key).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get usage counter: %w", err)
+	}
+	return n, nil
+}
+
+// Run flushes buffered usage until ctx is cancelled, with a final flush before returning.
+func (m *Meter) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.flush(context.WithoutCancel(ctx)); err != nil {
+				m.logger.Error("failed to flush usage", slog.String("error", err.Error()))
+			}
+			return
+		case <-ticker.C:
+			if err := m.flush(ctx); err != nil {
+				m.logger.Error("failed to flush usage", slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+func (m *Meter) flush(ctx context.Context) error {
+	err := redisutil.DrainHash(ctx, m.rdb, bufferKey, redisutil.MergeSum, func(entries map[string]string) error {
+		var params repository.AddUsageRecordsParams
+		for field, quantity := range entries {
+			parts := strings.Split(field, ":")
+			if len(parts) != 3 {
+				continue
+			}
+			accountID, err := uuid.Parse(parts[0])
+			if err != nil {
+				continue
+			}
+			unix, err := strconv.ParseInt(parts[2], 10, 64)
+			if err != nil {
+				continue
+			}
+			n, err := strconv.ParseInt(quantity, 10, 64)
+			if err != nil || n == 0 {
+				continue
+			}
+			params.AccountIds = append(params.AccountIds, pgtype.UUID{Bytes: accountID, Valid: true})
+			params.Metrics = append(params.Metrics, parts[1])
+			params.PeriodStarts = append(params.PeriodStarts, pgtype.Timestamptz{Time: time.Unix(unix, 0), Valid: true})
+			params.Quantities = append(params.Quantities, n)
+		}
+		if len(params.AccountIds) == 0 {
+			return nil
+		}
+
+		if err := m.repo.AddUsageRecords(ctx, params); err != nil {
+			return fmt.Errorf("failed to add usage records: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drain usage buffer: %w", err)
+	}
+	return nil
+}
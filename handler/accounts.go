@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/usage"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+type userAccount struct {
+	ID        pgtype.UUID `json:"id"`
+	Name      *string     `json:"name"`
+	Role      string      `json:"role,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+func newUserAccount(a *repository.Account, role string) userAccount {
+	return userAccount{
+		ID:        a.ID,
+		Name:      a.Name,
+		Role:      role,
+		CreatedAt: a.CreatedAt.Time,
+		UpdatedAt: a.UpdatedAt.Time,
+	}
+}
+
+// membershipRole returns the user's role in the current account. It is empty for staff that aren't members.
+func membershipRole(c echo.Context) string {
+	if membership := handlerutil.CurrentMembership(c); membership != nil {
+		return membership.Role
+	}
+	return ""
+}
+
+// CreateAccount creates an account with the user as its owner.
+func (h *Handler) CreateAccount(c echo.Context) error {
+	var req struct {
+		Name string `json:"name" validate:"required,max=64"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	user := handlerutil.AuthenticatedUser(c)
+
+	var account *repository.Account
+	err := h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		var err error
+		account, err = repo.CreateAccount(c.Request().Context(), &req.Name)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create account").SetInternal(err)
+		}
+
+		if _, err = repo.CreateUserAccount(c.Request().Context(), repository.CreateUserAccountParams{
+			UserID:    user.ID,
+			AccountID: account.ID,
+			Role:      handlerutil.RoleOwner,
+		}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to add account owner").SetInternal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, APISuccessResponse{
+		Data: newUserAccount(account, handlerutil.RoleOwner),
+	})
+}
+
+func (h *Handler) ListMyAccounts(c echo.Context) error {
+	user := handlerutil.AuthenticatedUser(c)
+
+	accounts, err := h.Repo.ListUserAccounts(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list accounts").SetInternal(err)
+	}
+
+	out := make([]userAccount, 0, len(accounts))
+	for _, a := range accounts {
+		out = append(out, userAccount{
+			ID:        a.ID,
+			Name:      a.Name,
+			Role:      a.Role,
+			CreatedAt: a.CreatedAt.Time,
+			UpdatedAt: a.UpdatedAt.Time,
+		})
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
+
+func (h *Handler) GetAccount(c echo.Context) error {
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: newUserAccount(handlerutil.CurrentAccount(c), membershipRole(c)),
+	})
+}
+
+// SelectAccount makes the account the active one for subsequent requests without the 'X-Account-ID' header.
+func (h *Handler) SelectAccount(c echo.Context) error {
+	account := handlerutil.CurrentAccount(c)
+
+	if err := handlerutil.SelectAccount(c, account.ID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save session").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: newUserAccount(account, membershipRole(c)),
+	})
+}
+
+func (h *Handler) RenameAccount(c echo.Context) error {
+	var req struct {
+		Name string `json:"name" validate:"required,max=64"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	account, err := h.Repo.UpdateAccountName(c.Request().Context(), repository.UpdateAccountNameParams{
+		ID:   accountID,
+		Name: &req.Name,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, APIErrorResponse{
+				Error: "account not found",
+			})
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to rename account").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: newUserAccount(account, membershipRole(c)),
+	})
+}
+
+// DeleteAccount deletes the account along with its memberships. Rows of other tables that belong to the account are removed by their foreign keys.
+func (h *Handler) DeleteAccount(c echo.Context) error {
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	err := h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		if err := repo.DeleteAccountMembers(c.Request().Context(), accountID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete account members").SetInternal(err)
+		}
+
+		n, err := repo.DeleteAccount(c.Request().Context(), accountID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete account").SetInternal(err)
+		}
+		if n == 0 {
+			return echo.NewHTTPError(http.StatusNotFound, "account not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// TransferAccountOwnership makes another active member the owner of the account. The previous owner stays on as an admin.
+func (h *Handler) TransferAccountOwnership(c echo.Context) error {
+	var req struct {
+		UserID string `json:"user_id" validate:"required,uuid"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	newOwnerID, err := parseUUID(req.UserID)
+	if err != nil {
+		return err
+	}
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	err = h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		newOwner, err := repo.GetUserAccount(c.Request().Context(), repository.GetUserAccountParams{
+			UserID:    newOwnerID,
+			AccountID: accountID,
+		})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, "new owner must be an active member of the account")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get account member").SetInternal(err)
+		}
+		if newOwner.Role == handlerutil.RoleOwner {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "user is already the owner of the account")
+		}
+
+		if err = repo.DemoteAccountOwners(c.Request().Context(), accountID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to demote account owner").SetInternal(err)
+		}
+		if _, err = repo.UpdateUserAccountRole(c.Request().Context(), repository.UpdateUserAccountRoleParams{
+			Role:      handlerutil.RoleOwner,
+			UserID:    newOwnerID,
+			AccountID: accountID,
+		}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to promote new account owner").SetInternal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// GetAccountEntitlements returns the plan of the account & what it allows.
+func (h *Handler) GetAccountEntitlements(c echo.Context) error {
+	e, err := handlerutil.ResolveEntitlements(c, h.Repo, h.Config.Get())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve entitlements").SetInternal(err)
+	}
+
+	var expiresAt *time.Time
+	if e.Subscription != nil {
+		expiresAt = &e.Subscription.EndsAt.Time
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: echo.Map{
+			"plan_id":    e.PlanID,
+			"plan_name":  e.Plan.Name,
+			"features":   e.Plan.Features,
+			"limits":     e.Plan.Limits,
+			"expires_at": expiresAt,
+			"expired":    e.Expired(),
+		},
+	})
+}
+
+type metricUsage struct {
+	Metric string `json:"metric"`
+	Used   int64  `json:"used"`
+	// 'config.Unlimited' if the plan doesn't limit the metric.
+	Limit       int64     `json:"limit"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+}
+
+// GetAccountUsage returns the usage of each metered quantity in the current period & the plan's limit of it.
+func (h *Handler) GetAccountUsage(c echo.Context) error {
+	e, err := handlerutil.ResolveEntitlements(c, h.Repo, h.Config.Get())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve entitlements").SetInternal(err)
+	}
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	now := time.Now()
+	out := make([]metricUsage, 0, len(usage.Metrics()))
+	for _, metric := range usage.Metrics() {
+		used, err := h.Usage.Usage(c.Request().Context(), accountID, metric)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get usage").SetInternal(err)
+		}
+
+		start, end := usage.Period(metric, now)
+
+		out = append(out, metricUsage{
+			Metric:      metric,
+			Used:        used,
+			Limit:       e.Limit(metric),
+			PeriodStart: start,
+			PeriodEnd:   end,
+		})
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
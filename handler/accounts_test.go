@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/billing"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/handler/middleware"
+)
+
+type accountResponse struct {
+	Data struct {
+		ID   pgtype.UUID `json:"id"`
+		Name *string     `json:"name"`
+		Role string      `json:"role"`
+	} `json:"data"`
+}
+
+func TestAccountsCRUD(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	_, sessionID := repo.addSession("jane@example.com")
+	client := newSignedInClient(t, srv, cfg, sessionID)
+	_, otherSessionID := repo.addSession("john@example.com")
+	other := newSignedInClient(t, srv, cfg, otherSessionID)
+
+	var created accountResponse
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/accounts", map[string]string{"name": "Acme"}, &created); status != http.StatusCreated {
+		t.Fatalf("create: status = %d, want %d", status, http.StatusCreated)
+	}
+	if created.Data.Name == nil || *created.Data.Name != "Acme" || created.Data.Role != handlerutil.RoleOwner {
+		t.Fatalf("created account = %+v, want Acme owned by the user", created.Data)
+	}
+	accountURL := srv.URL + "/accounts/" + created.Data.ID.String()
+
+	var listed struct {
+		Data []struct {
+			ID pgtype.UUID `json:"id"`
+		} `json:"data"`
+	}
+	if status := doJSON(t, client, http.MethodGet, srv.URL+"/accounts", nil, &listed); status != http.StatusOK {
+		t.Fatalf("list: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(listed.Data) != 1 || listed.Data[0].ID != created.Data.ID {
+		t.Errorf("listed accounts = %+v, want the created one", listed.Data)
+	}
+
+	if status := doJSON(t, client, http.MethodGet, accountURL, nil, nil); status != http.StatusOK {
+		t.Errorf("get: status = %d, want %d", status, http.StatusOK)
+	}
+	// Non-members can't tell the account exists.
+	if status := doJSON(t, other, http.MethodGet, accountURL, nil, nil); status != http.StatusNotFound {
+		t.Errorf("get by non-member: status = %d, want %d", status, http.StatusNotFound)
+	}
+
+	var renamed accountResponse
+	if status := doJSON(t, client, http.MethodPatch, accountURL, map[string]string{"name": "Acme Inc."}, &renamed); status != http.StatusOK {
+		t.Fatalf("rename: status = %d, want %d", status, http.StatusOK)
+	}
+	if renamed.Data.Name == nil || *renamed.Data.Name != "Acme Inc." {
+		t.Errorf("renamed account = %+v, want Acme Inc.", renamed.Data)
+	}
+
+	if status := doJSON(t, client, http.MethodDelete, accountURL, nil, nil); status != http.StatusOK {
+		t.Fatalf("delete: status = %d, want %d", status, http.StatusOK)
+	}
+	if status := doJSON(t, client, http.MethodGet, accountURL, nil, nil); status != http.StatusNotFound {
+		t.Errorf("get after delete: status = %d, want %d", status, http.StatusNotFound)
+	}
+	if len(repo.accounts) != 0 || len(repo.userAccounts) != 0 {
+		t.Errorf("%d accounts & %d memberships left after delete, want 0", len(repo.accounts), len(repo.userAccounts))
+	}
+}
+
+func TestCreateAccountRollsBack(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	_, sessionID := repo.addSession("jane@example.com")
+	client := newSignedInClient(t, srv, cfg, sessionID)
+
+	// The account is created, but adding its owner fails.
+	repo.failQuery = "CreateUserAccount"
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/accounts", map[string]string{"name": "Acme"}, nil); status != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", status, http.StatusInternalServerError)
+	}
+	if len(repo.accounts) != 0 {
+		t.Errorf("%d accounts left without an owner, want 0", len(repo.accounts))
+	}
+}
+
+func TestActiveAccountFromHeaderOrSession(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	user, sessionID := repo.addSession("jane@example.com")
+	selected := repo.addAccount(user.ID)
+	other := repo.addAccount(user.ID)
+	john, _ := repo.addSession("john@example.com")
+	notMine := repo.addAccount(john.ID)
+	client := newSignedInClient(t, srv, cfg, sessionID)
+
+	getActive := func(header string) (int, pgtype.UUID) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/users/me/account", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		if header != "" {
+			req.Header.Set(middleware.HeaderXAccountID, header)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		defer res.Body.Close()
+
+		var out accountResponse
+		_ = json.NewDecoder(res.Body).Decode(&out)
+		return res.StatusCode, out.Data.ID
+	}
+
+	if status, _ := getActive(""); status != http.StatusBadRequest {
+		t.Errorf("nothing selected: status = %d, want %d", status, http.StatusBadRequest)
+	}
+
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/accounts/"+selected.String()+"/select", nil, nil); status != http.StatusOK {
+		t.Fatalf("select: status = %d, want %d", status, http.StatusOK)
+	}
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantID     pgtype.UUID
+	}{
+		{name: "session", wantStatus: http.StatusOK, wantID: selected},
+		{name: "header over session", header: other.String(), wantStatus: http.StatusOK, wantID: other},
+		{name: "header of another user's account", header: notMine.String(), wantStatus: http.StatusNotFound},
+		{name: "invalid header", header: "invalid", wantStatus: http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, id := getActive(tt.header)
+			if status != tt.wantStatus || id != tt.wantID {
+				t.Errorf("got %d for %s, want %d for %s", status, id, tt.wantStatus, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestAccountRoutesRequireSubscription(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	user, sessionID := repo.addSession("jane@example.com")
+	accountID := repo.addAccount(user.ID)
+	client := newSignedInClient(t, srv, cfg, sessionID)
+	accountURL := srv.URL + "/accounts/" + accountID.String()
+
+	if status := doJSON(t, client, http.MethodGet, accountURL, nil, nil); status != http.StatusOK {
+		t.Fatalf("default plan: status = %d, want %d", status, http.StatusOK)
+	}
+
+	if _, err := repo.UpsertSubscription(context.Background(), repository.UpsertSubscriptionParams{
+		AccountID:   accountID,
+		PlanID:      "pro",
+		Status:      billing.StatusPastDue,
+		EndsAt:      pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		LastEventAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}); err != nil {
+		t.Fatalf("failed to upsert subscription: %v", err)
+	}
+
+	if status := doJSON(t, client, http.MethodGet, accountURL, nil, nil); status != http.StatusPaymentRequired {
+		t.Errorf("past due: status = %d, want %d", status, http.StatusPaymentRequired)
+	}
+	// Still reachable, so that the account can see why.
+	if status := doJSON(t, client, http.MethodGet, accountURL+"/entitlements", nil, nil); status != http.StatusOK {
+		t.Errorf("entitlements: status = %d, want %d", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/util"
+)
+
+// Every user can have this many active API keys.
+const maxAPIKeys = 25
+
+type apiKey struct {
+	ID         pgtype.UUID `json:"id"`
+	Name       string      `json:"name"`
+	Prefix     string      `json:"prefix"`
+	Scopes     []string    `json:"scopes"`
+	LastUsedAt *time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func newAPIKey(key *repository.ApiKey) apiKey {
+	var lastUsedAt *time.Time
+	if key.LastUsedAt.Valid {
+		lastUsedAt = &key.LastUsedAt.Time
+	}
+	return apiKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		LastUsedAt: lastUsedAt,
+		CreatedAt:  key.CreatedAt.Time,
+	}
+}
+
+// CreateMyAPIKey creates an API key with the scopes, which are permissions the key can use on accounts the user is a member of. The key is only returned in plaintext here.
+func (h *Handler) CreateMyAPIKey(c echo.Context) error {
+	var req struct {
+		Name   string   `json:"name" validate:"required,max=64"`
+		Scopes []string `json:"scopes" validate:"required,min=1,unique"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	permissions := handlerutil.Permissions()
+	for _, scope := range req.Scopes {
+		if !slices.Contains(permissions, scope) {
+			return c.JSON(http.StatusUnprocessableEntity, APIErrorResponse{
+				Error: "unknown scope " + scope,
+			})
+		}
+	}
+
+	user := handlerutil.AuthenticatedUser(c)
+
+	keys, err := h.Repo.ListApiKeys(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list API keys").SetInternal(err)
+	}
+	if len(keys) >= maxAPIKeys {
+		return c.JSON(http.StatusConflict, APIErrorResponse{
+			Error: "too many API keys, revoke one first",
+		})
+	}
+
+	plaintext, prefix, secret, err := handlerutil.NewAPIKey()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate API key").SetInternal(err)
+	}
+	hash, err := util.GenerateSecureHash([]byte(secret))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to hash API key").SetInternal(err)
+	}
+
+	key, err := h.Repo.CreateApiKey(c.Request().Context(), repository.CreateApiKeyParams{
+		UserID:  user.ID,
+		Name:    req.Name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  req.Scopes,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create API key").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusCreated, APISuccessResponse{
+		Data: echo.Map{
+			"api_key": newAPIKey(key),
+			"key":     plaintext,
+		},
+	})
+}
+
+func (h *Handler) ListMyAPIKeys(c echo.Context) error {
+	keys, err := h.Repo.ListApiKeys(c.Request().Context(), handlerutil.AuthenticatedUser(c).ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list API keys").SetInternal(err)
+	}
+
+	out := make([]apiKey, 0, len(keys))
+	for _, key := range keys {
+		out = append(out, newAPIKey(key))
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
+
+func (h *Handler) RevokeMyAPIKey(c echo.Context) error {
+	var req struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	keyID, err := parseUUID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	n, err := h.Repo.RevokeApiKey(c.Request().Context(), repository.RevokeApiKeyParams{
+		ID:     keyID,
+		UserID: handlerutil.AuthenticatedUser(c).ID,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke API key").SetInternal(err)
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, APIErrorResponse{
+			Error: "API key not found",
+		})
+	}
+
+	return c.NoContent(http.StatusOK)
+}
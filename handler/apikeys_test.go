@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/billing"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+type createAPIKeyResponse struct {
+	Data struct {
+		APIKey struct {
+			ID pgtype.UUID `json:"id"`
+		} `json:"api_key"`
+		Key string `json:"key"`
+	} `json:"data"`
+}
+
+// newAPIKeyOwner returns a client of a user whose selected account is on a plan with API keys.
+func newAPIKeyOwner(t *testing.T, srv *httptest.Server, cfg *config.Config, repo *fakeRepo) *http.Client {
+	t.Helper()
+
+	user, sessionID := repo.addSession("jane@example.com")
+	accountID := repo.addAccount(user.ID)
+	if _, err := repo.UpsertSubscription(context.Background(), repository.UpsertSubscriptionParams{
+		AccountID:   accountID,
+		PlanID:      "pro",
+		Status:      billing.StatusActive,
+		EndsAt:      pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		LastEventAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}); err != nil {
+		t.Fatalf("failed to upsert subscription: %v", err)
+	}
+
+	client := newSignedInClient(t, srv, cfg, sessionID)
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/accounts/"+accountID.String()+"/select", nil, nil); status != http.StatusOK {
+		t.Fatalf("select account: status = %d, want %d", status, http.StatusOK)
+	}
+	return client
+}
+
+func createAPIKey(t *testing.T, client *http.Client, url string, scopes ...string) createAPIKeyResponse {
+	t.Helper()
+
+	var res createAPIKeyResponse
+	if status := doJSON(t, client, http.MethodPost, url+"/users/me/api-keys", map[string]any{"name": "CI", "scopes": scopes}, &res); status != http.StatusCreated {
+		t.Fatalf("create API key: status = %d, want %d", status, http.StatusCreated)
+	}
+	return res
+}
+
+// doWithKey sends a request authenticated with the key & returns the status.
+func doWithKey(t *testing.T, method string, url string, key string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+	owner := newAPIKeyOwner(t, srv, cfg, repo)
+
+	created := createAPIKey(t, owner, srv.URL, handlerutil.PermAccountsRead)
+	key := created.Data.Key
+	prefix, _, _ := handlerutil.ParseAPIKey(key)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		key        string
+		wantStatus int
+	}{
+		{name: "valid", method: http.MethodGet, path: "/users/me", key: key, wantStatus: http.StatusOK},
+		{name: "valid with scope", method: http.MethodGet, path: "/accounts", key: key, wantStatus: http.StatusOK},
+		{name: "wrong secret", method: http.MethodGet, path: "/users/me", key: "ak_" + prefix + "_" + strings.Repeat("x", 43), wantStatus: http.StatusUnauthorized},
+		{name: "unknown prefix", method: http.MethodGet, path: "/users/me", key: "ak_" + strings.Repeat("0", 16) + "_secret", wantStatus: http.StatusUnauthorized},
+		{name: "missing scope", method: http.MethodPost, path: "/accounts", key: key, wantStatus: http.StatusForbidden},
+		{name: "sessions route", method: http.MethodGet, path: "/users/me/sessions", key: key, wantStatus: http.StatusForbidden},
+		{name: "API keys route", method: http.MethodGet, path: "/users/me/api-keys", key: key, wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := doWithKey(t, tt.method, srv.URL+tt.path, tt.key); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+
+	if !repo.apiKeys[created.Data.APIKey.ID].LastUsedAt.Valid {
+		t.Error("last use of the key wasn't tracked")
+	}
+}
+
+func TestRevokedAPIKeyIsRejected(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+	owner := newAPIKeyOwner(t, srv, cfg, repo)
+	created := createAPIKey(t, owner, srv.URL, handlerutil.PermAccountsRead)
+
+	if status := doJSON(t, owner, http.MethodDelete, srv.URL+"/users/me/api-keys/"+created.Data.APIKey.ID.String(), nil, nil); status != http.StatusOK {
+		t.Fatalf("revoke: status = %d, want %d", status, http.StatusOK)
+	}
+	if status := doWithKey(t, http.MethodGet, srv.URL+"/users/me", created.Data.Key); status != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestAPIKeyRoutesRequirePlanFeature(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	// On the default plan, which doesn't include API keys.
+	user, sessionID := repo.addSession("jane@example.com")
+	accountID := repo.addAccount(user.ID)
+	client := newSignedInClient(t, srv, cfg, sessionID)
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/accounts/"+accountID.String()+"/select", nil, nil); status != http.StatusOK {
+		t.Fatalf("select account: status = %d, want %d", status, http.StatusOK)
+	}
+
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/users/me/api-keys", map[string]any{"name": "CI", "scopes": []string{handlerutil.PermAccountsRead}}, nil); status != http.StatusPaymentRequired {
+		t.Errorf("create: status = %d, want %d", status, http.StatusPaymentRequired)
+	}
+	if status := doJSON(t, client, http.MethodGet, srv.URL+"/users/me/api-keys", nil, nil); status != http.StatusPaymentRequired {
+		t.Errorf("list: status = %d, want %d", status, http.StatusPaymentRequired)
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"log/slog"
 	"net/http"
 	"net/netip"
 	"time"
@@ -9,9 +10,29 @@ import (
 	"github.com/labstack/echo-contrib/session"
 	"github.com/labstack/echo/v4"
 	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/email"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
 	"github.com/rohitxdev/go-api/util"
 )
 
+const (
+	otpValidity = time.Minute * 10
+	// Attempts per code. Failures across codes are limited by 'lockout.Guard'.
+	otpMaxAttempts = 5
+)
+
+// checkOTPLockout rejects requests for an email that is locked out after too many invalid codes.
+func (h *Handler) checkOTPLockout(c echo.Context, email string) error {
+	lockedFor, err := h.OTPGuard.LockedFor(c.Request().Context(), email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check OTP lockout").SetInternal(err)
+	}
+	if lockedFor > 0 {
+		return retryLater(c, lockedFor, "too many invalid OTP codes, try again later")
+	}
+	return nil
+}
+
 func (h *Handler) SendAuthOTP(c echo.Context) error {
 	var req struct {
 		Email string `json:"email" validate:"required,email"`
@@ -21,6 +42,18 @@ func (h *Handler) SendAuthOTP(c echo.Context) error {
 	}
 
 	req.Email = canonicalizeEmail(req.Email)
+	if err := h.checkOTPLockout(c, req.Email); err != nil {
+		return err
+	}
+
+	wait, err := h.OTPGuard.StartCooldown(c.Request().Context(), req.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start OTP cooldown").SetInternal(err)
+	}
+	if wait > 0 {
+		return retryLater(c, wait, "OTP was sent recently, try again later")
+	}
+
 	code, err := util.GenerateAlphaNumCode(6)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, APIErrorResponse{
@@ -37,22 +70,57 @@ func (h *Handler) SendAuthOTP(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to hash verification code", err)
 	}
 
-	if err = h.Repo.CreateOtp(c.Request().Context(), repository.CreateOtpParams{
-		UserID:   user.ID,
-		CodeHash: codeHash,
-		ExpiresAt: pgtype.Timestamptz{
-			Time:  time.Now().Add(time.Minute * 10),
-			Valid: true,
-		},
+	// Only the latest code works, so that codes in earlier emails can't be guessed in parallel.
+	if err = h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		if err := repo.ExpireOtps(c.Request().Context(), user.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to invalidate previous OTPs").SetInternal(err)
+		}
+		if err := repo.CreateOtp(c.Request().Context(), repository.CreateOtpParams{
+			UserID:   user.ID,
+			CodeHash: codeHash,
+			ExpiresAt: pgtype.Timestamptz{
+				Time:  time.Now().Add(otpValidity),
+				Valid: true,
+			},
+		}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create OTP").SetInternal(err)
+		}
+		return nil
 	}); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to create OTP").SetInternal(err)
+		return err
 	}
 
-	return c.JSON(http.StatusOK, APISuccessResponse{
-		Data: echo.Map{
-			"userId": user.ID,
-			"code":   code,
+	cfg := h.Config.Get()
+	data := echo.Map{
+		"userId": user.ID,
+	}
+	if cfg.CanEchoOTP() {
+		data["code"] = code
+	}
+
+	if err = h.Email.SendHTML(
+		c.Request().Context(),
+		&email.BaseOpts{
+			ToAddresses: []string{user.Email},
+			Language:    handlerutil.Language(c),
+			NoStack:     true,
 		},
+		"auth-otp",
+		map[string]any{
+			"code":         code,
+			"validMinutes": int(otpValidity.Minutes()),
+			"year":         time.Now().Year(),
+		},
+	); err != nil {
+		// Echoed codes let local development proceed without a working mail server.
+		if !cfg.CanEchoOTP() {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to send OTP email").SetInternal(err)
+		}
+		h.Logger.Warn("failed to send OTP email", slog.String("error", err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: data,
 	})
 }
 
@@ -65,47 +133,99 @@ func (h *Handler) VerifyAuthOTP(c echo.Context) error {
 		return err
 	}
 
-	otp, err := h.Repo.GetOtpByUserId(c.Request().Context(), req.UserID)
+	user, err := h.Repo.GetUserByID(c.Request().Context(), req.UserID)
 	if err != nil {
 		return c.JSON(http.StatusBadRequest, APIErrorResponse{
 			Error: "otp not found or invalid",
 		})
 	}
-	if otp.Attempts > 3 {
-		if err = h.Repo.DeleteOtp(c.Request().Context(), otp.ID); err != nil {
-			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete OTP").SetInternal(err)
-		}
-		return c.JSON(http.StatusForbidden, APIErrorResponse{
-			Error: "max attempts exceeded",
+	if err = h.checkOTPLockout(c, user.Email); err != nil {
+		return err
+	}
+
+	otp, err := h.Repo.GetOtpByUserId(c.Request().Context(), req.UserID)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, APIErrorResponse{
+			Error: "otp not found or invalid",
 		})
 	}
 
-	if err = h.Repo.IncrementOtpAttempts(c.Request().Context(), req.UserID); err != nil {
+	// Counted before checking the code, so that concurrent guesses can't exceed the max.
+	attempts, err := h.Repo.IncrementOtpAttempts(c.Request().Context(), otp.ID)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to increment OTP attempts").SetInternal(err)
 	}
+	if attempts > otpMaxAttempts {
+		return c.JSON(http.StatusForbidden, APIErrorResponse{
+			Error: "max attempts exceeded",
+		})
+	}
 
 	if !util.VerifySecureHash([]byte(req.Code), otp.CodeHash) {
+		lockout, err := h.OTPGuard.RecordFailure(c.Request().Context(), user.Email)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record OTP failure").SetInternal(err)
+		}
+		if lockout > 0 {
+			return retryLater(c, lockout, "too many invalid OTP codes, try again later")
+		}
 		return c.JSON(http.StatusBadRequest, APIErrorResponse{
 			Error: "invalid OTP code",
 		})
 	}
 
-	if err = h.Repo.DeleteOtp(c.Request().Context(), otp.ID); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete OTP").SetInternal(err)
+	n, err := h.Repo.ConsumeOtp(c.Request().Context(), otp.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to consume OTP").SetInternal(err)
+	}
+	// Used by a concurrent request, or replaced by a newer code in the meantime.
+	if n == 0 {
+		return c.JSON(http.StatusBadRequest, APIErrorResponse{
+			Error: "otp not found or invalid",
+		})
 	}
 
-	if _, err = h.Repo.UpdateUser(
-		c.Request().Context(),
-		repository.UpdateUserParams{
-			ID: otp.UserID,
-			VerifiedAt: pgtype.Timestamptz{
-				Time:  time.Now(),
-				Valid: true,
+	if err = h.OTPGuard.Reset(c.Request().Context(), user.Email); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to reset OTP failures").SetInternal(err)
+	}
+
+	totpRequired, err := h.authenticate(c, otp.UserID, true)
+	if err != nil {
+		return err
+	}
+	if totpRequired {
+		return c.JSON(http.StatusOK, APISuccessResponse{
+			Data: echo.Map{
+				"totp_required": true,
 			},
-		}); err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update user").SetInternal(err)
+		})
 	}
 
+	return c.NoContent(http.StatusOK)
+}
+
+// signIn starts a session. If the user signed in with a code or link sent to their email, i.e. verifiesEmail is set, it also marks the email as verified.
+func (h *Handler) signIn(c echo.Context, userID pgtype.UUID, verifiesEmail bool) error {
+	if verifiesEmail {
+		if _, err := h.Repo.UpdateUser(
+			c.Request().Context(),
+			repository.UpdateUserParams{
+				ID: userID,
+				VerifiedAt: pgtype.Timestamptz{
+					Time:  time.Now(),
+					Valid: true,
+				},
+			}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to update user").SetInternal(err)
+		}
+
+		// Invitations accepted before the email was verified take effect now.
+		if err := h.Repo.ActivateUserAccounts(c.Request().Context(), userID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to activate account memberships").SetInternal(err)
+		}
+	}
+
+	cfg := h.Config.Get()
 	ipAddress, err := netip.ParseAddr(c.RealIP())
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to parse client IP address").SetInternal(err)
@@ -114,11 +234,13 @@ func (h *Handler) VerifyAuthOTP(c echo.Context) error {
 	sessionId, err := h.Repo.CreateSession(
 		c.Request().Context(),
 		repository.CreateSessionParams{
-			UserID: otp.UserID,
+			UserID: userID,
+			// Slid forward on activity, see 'activity.Tracker'.
 			ExpiresAt: pgtype.Timestamptz{
-				Time:  time.Now().Add(time.Hour * 24 * 30),
+				Time:  time.Now().Add(min(cfg.SessionIdleTimeout, cfg.SessionMaxLifetime)),
 				Valid: true,
 			},
+			UserAgent: userAgent(c),
 			IpAddress: ipAddress,
 		})
 	if err != nil {
@@ -135,7 +257,7 @@ func (h *Handler) VerifyAuthOTP(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save session").SetInternal(err)
 	}
 
-	return c.NoContent(http.StatusOK)
+	return nil
 }
 
 func (h *Handler) SignOut(c echo.Context) error {
@@ -146,6 +268,13 @@ func (h *Handler) SignOut(c echo.Context) error {
 		})
 	}
 
+	// Revoke the session server-side too, so that a copied cookie stops working.
+	if sessionID, ok := handlerutil.CurrentSessionID(c); ok {
+		if err = h.Repo.RevokeSession(c.Request().Context(), sessionID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session").SetInternal(err)
+		}
+	}
+
 	sess.Options.MaxAge = -1
 
 	if err = sess.Save(c.Request(), c.Response()); err != nil {
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/deps/lockout"
+)
+
+type otpSendResponse struct {
+	Data struct {
+		UserID string `json:"userId"`
+		Code   string `json:"code"`
+	} `json:"data"`
+}
+
+func TestSendAuthOTPEmailsCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         config.Environment
+		echoEnabled bool
+		wantEcho    bool
+	}{
+		{name: "echo in testing", env: config.EnvTest, echoEnabled: true, wantEcho: true},
+		{name: "echo disabled", env: config.EnvTest, echoEnabled: false, wantEcho: false},
+		{name: "echo in staging", env: config.EnvStaging, echoEnabled: true, wantEcho: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.AppEnv = tt.env
+			cfg.OTPEchoEnabled = tt.echoEnabled
+			srv, h := newTestServer(t, cfg, newFakeRepo())
+
+			var res otpSendResponse
+			if status := doJSON(t, newTestClient(t), http.MethodPost, srv.URL+"/auth/otp/send", map[string]string{"email": "User@Example.com"}, &res); status != http.StatusOK {
+				t.Fatalf("status = %d, want %d", status, http.StatusOK)
+			}
+			if tt.wantEcho != (res.Data.Code != "") {
+				t.Errorf("code in response = %q, want echoed %t", res.Data.Code, tt.wantEcho)
+			}
+
+			sent := h.Email.Recent(1)
+			if len(sent) != 1 || len(sent[0].To) != 1 || sent[0].To[0] != "user@example.com" {
+				t.Fatalf("sent %+v, want an email to user@example.com", sent)
+			}
+			if sent[0].Subject == "" || sent[0].TextBody == "" || sent[0].HTMLBody == "" {
+				t.Errorf("email has no subject or body: %+v", sent[0])
+			}
+			if tt.wantEcho && (!strings.Contains(sent[0].TextBody, res.Data.Code) || !strings.Contains(sent[0].HTMLBody, res.Data.Code)) {
+				t.Errorf("email doesn't contain the code %q", res.Data.Code)
+			}
+		})
+	}
+}
+
+// sendOTP sends an OTP to the email & returns the echoed code & user ID.
+func sendOTP(t *testing.T, client *http.Client, url string, email string) otpSendResponse {
+	t.Helper()
+
+	var res otpSendResponse
+	if status := doJSON(t, client, http.MethodPost, url+"/auth/otp/send", map[string]string{"email": email}, &res); status != http.StatusOK {
+		t.Fatalf("send OTP: status = %d, want %d", status, http.StatusOK)
+	}
+	return res
+}
+
+func TestSendAuthOTPCooldown(t *testing.T) {
+	cfg := testConfig(t)
+	srv, _ := newTestServer(t, cfg, newFakeRepo())
+	client := newTestClient(t)
+
+	sendOTP(t, client, srv.URL, "jane@example.com")
+
+	res, err := client.Post(srv.URL+"/auth/otp/send", echo.MIMEApplicationJSON, strings.NewReader(`{"email": "jane@example.com"}`))
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("resend: status = %d, want %d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	// The default cooldown is a minute.
+	if got := res.Header.Get(echo.HeaderRetryAfter); got != "60" {
+		t.Errorf("%s = %q, want %q", echo.HeaderRetryAfter, got, "60")
+	}
+
+	// Other emails aren't held back.
+	sendOTP(t, client, srv.URL, "john@example.com")
+}
+
+func TestSendAuthOTPExpiresPreviousCodes(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, h := newTestServer(t, cfg, repo)
+	h.OTPGuard = lockout.NewGuard(h.Redis, &lockout.GuardOpts{
+		Cooldown:      time.Millisecond,
+		MaxFailures:   5,
+		BaseLockout:   time.Minute,
+		MaxLockout:    time.Hour,
+		FailureWindow: time.Hour,
+	})
+	client := newTestClient(t)
+
+	first := sendOTP(t, client, srv.URL, "jane@example.com")
+	time.Sleep(time.Millisecond * 2)
+	second := sendOTP(t, client, srv.URL, "jane@example.com")
+
+	valid := 0
+	for _, otp := range repo.otps {
+		if otpValid(otp) {
+			valid++
+		}
+	}
+	if valid != 1 {
+		t.Errorf("%d valid OTPs after resending, want 1", valid)
+	}
+
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/auth/otp/verify", map[string]string{"user_id": first.Data.UserID, "code": first.Data.Code}, nil); status != http.StatusBadRequest {
+		t.Errorf("first code: status = %d, want %d", status, http.StatusBadRequest)
+	}
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/auth/otp/verify", map[string]string{"user_id": second.Data.UserID, "code": second.Data.Code}, nil); status != http.StatusOK {
+		t.Errorf("second code: status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestVerifyAuthOTPLimitsAttemptsPerCode(t *testing.T) {
+	cfg := testConfig(t)
+	srv, h := newTestServer(t, cfg, newFakeRepo())
+	// Doesn't lock out, so that only the attempts of the code are limited.
+	h.OTPGuard = lockout.NewGuard(h.Redis, &lockout.GuardOpts{
+		Cooldown:      time.Minute,
+		MaxFailures:   100,
+		BaseLockout:   time.Minute,
+		MaxLockout:    time.Hour,
+		FailureWindow: time.Hour,
+	})
+	client := newTestClient(t)
+
+	sent := sendOTP(t, client, srv.URL, "jane@example.com")
+	wrong := "000000"
+	if sent.Data.Code == wrong {
+		wrong = "111111"
+	}
+
+	for i := range otpMaxAttempts {
+		if status := doJSON(t, client, http.MethodPost, srv.URL+"/auth/otp/verify", map[string]string{"user_id": sent.Data.UserID, "code": wrong}, nil); status != http.StatusBadRequest {
+			t.Fatalf("attempt %d: status = %d, want %d", i+1, status, http.StatusBadRequest)
+		}
+	}
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/auth/otp/verify", map[string]string{"user_id": sent.Data.UserID, "code": sent.Data.Code}, nil); status != http.StatusForbidden {
+		t.Errorf("valid code after max attempts: status = %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestVerifyAuthOTPVerifiesEmail(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+	client := newTestClient(t)
+
+	sent := sendOTP(t, client, srv.URL, "jane@example.com")
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/auth/otp/verify", map[string]string{"user_id": sent.Data.UserID, "code": sent.Data.Code}, nil); status != http.StatusOK {
+		t.Fatalf("verify: status = %d, want %d", status, http.StatusOK)
+	}
+
+	var userID pgtype.UUID
+	if err := userID.Scan(sent.Data.UserID); err != nil {
+		t.Fatalf("failed to parse user ID: %v", err)
+	}
+	if !repo.users[userID].VerifiedAt.Valid {
+		t.Error("email wasn't verified")
+	}
+}
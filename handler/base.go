@@ -16,15 +16,8 @@ func (h *Handler) Home(c echo.Context) error {
 }
 
 func (h *Handler) GetMe(c echo.Context) error {
-	user := handlerutil.CurrentUser(c, h.Repo)
-	if user == nil {
-		return c.JSON(http.StatusUnauthorized, APIErrorResponse{
-			Error: "user not authenticated",
-		})
-	}
-
 	return c.JSON(http.StatusOK, APISuccessResponse{
-		Data: user,
+		Data: handlerutil.AuthenticatedUser(c),
 	})
 }
 
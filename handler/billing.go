@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/billing"
+	"github.com/rohitxdev/go-api/util"
+)
+
+// BillingWebhook applies subscription changes sent by the billing provider. Every event is stored, and applied in the same transaction, so that redeliveries are acknowledged without being applied twice. Events older than the last one applied to the subscription are stored but not applied.
+func (h *Handler) BillingWebhook(c echo.Context) error {
+	cfg := h.Config.Get()
+	if cfg.BillingWebhookSecret == "" {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "billing webhooks are not configured")
+	}
+
+	payload, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "failed to read request body").SetInternal(err)
+	}
+
+	if err = billing.VerifySignature(payload, c.Request().Header.Get(billing.HeaderSignature), cfg.BillingWebhookSecret, cfg.BillingWebhookTolerance); err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, err.Error()).SetInternal(err)
+	}
+
+	var event billing.Event
+	if err = json.Unmarshal(payload, &event); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event payload").SetInternal(err)
+	}
+	if err = util.Validate.Struct(&event); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "invalid event").SetInternal(err)
+	}
+
+	duplicate := false
+	err = h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		n, err := repo.CreateBillingEvent(c.Request().Context(), repository.CreateBillingEventParams{
+			ID:      event.ID,
+			Type:    event.Type,
+			Payload: payload,
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to store billing event").SetInternal(err)
+		}
+		if n == 0 {
+			duplicate = true
+			return nil
+		}
+
+		return h.applyBillingEvent(c.Request().Context(), repo, &event)
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: echo.Map{
+			"duplicate": duplicate,
+		},
+	})
+}
+
+// applyBillingEvent updates the subscription of the event's account. Event types that don't affect subscriptions are only stored.
+func (h *Handler) applyBillingEvent(ctx context.Context, repo repository.Querier, event *billing.Event) error {
+	switch event.Type {
+	case billing.EventSubscriptionCreated, billing.EventSubscriptionUpdated, billing.EventSubscriptionCancelled:
+	default:
+		return nil
+	}
+
+	var data billing.SubscriptionData
+	if err := json.Unmarshal(event.Data, &data); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid subscription data").SetInternal(err)
+	}
+	if err := util.Validate.Struct(&data); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "invalid subscription data").SetInternal(err)
+	}
+	// Rejected so that the provider retries once the plan has been added to the catalogue.
+	if _, err := h.Config.Get().Plan(data.PlanID); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "unknown plan").SetInternal(err)
+	}
+
+	accountID := pgtype.UUID{Bytes: uuid.MustParse(data.AccountID), Valid: true}
+	if _, err := repo.GetAccount(ctx, accountID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// The account may have been deleted, retrying won't help.
+			h.Logger.Warn("billing event for unknown account", slog.String("event_id", event.ID), slog.String("account_id", data.AccountID))
+			return nil
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get account").SetInternal(err)
+	}
+
+	// Cancellations are upserted as well, so that they are recorded even if they arrive before the subscription was created. Cancelled subscriptions lose access right away, see 'AccountEntitlements.Expired'.
+	status := data.Status
+	if event.Type == billing.EventSubscriptionCancelled {
+		status = billing.StatusCancelled
+	}
+
+	n, err := repo.UpsertSubscription(ctx, repository.UpsertSubscriptionParams{
+		AccountID:   accountID,
+		PlanID:      data.PlanID,
+		Status:      status,
+		StartsAt:    pgtype.Timestamptz{Time: data.StartsAt, Valid: true},
+		EndsAt:      pgtype.Timestamptz{Time: data.EndsAt, Valid: true},
+		LastEventAt: pgtype.Timestamptz{Time: event.CreatedAt, Valid: true},
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update subscription").SetInternal(err)
+	}
+	if n == 0 {
+		h.Logger.Info("ignored billing event older than the last applied one", slog.String("event_id", event.ID), slog.String("account_id", data.AccountID))
+	}
+
+	return nil
+}
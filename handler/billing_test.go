@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/billing"
+)
+
+const testBillingSecret = "whsec_test"
+
+// The account of the fixtures in 'cmd/billingsign/fixtures'.
+var fixtureAccountID = pgtype.UUID{Valid: true}
+
+func readBillingFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	payload, err := os.ReadFile(filepath.Join("..", "cmd", "billingsign", "fixtures", name+".json"))
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	return payload
+}
+
+// withEventFields returns the payload with the top-level fields replaced.
+func withEventFields(t *testing.T, payload []byte, fields map[string]any) []byte {
+	t.Helper()
+
+	var event map[string]any
+	if err := json.Unmarshal(payload, &event); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	for k, v := range fields {
+		event[k] = v
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("failed to marshal payload: %v", err)
+	}
+	return payload
+}
+
+// postBillingEvent sends the payload signed with secret & returns the status & whether the event was a duplicate.
+func postBillingEvent(t *testing.T, url string, payload []byte, secret string) (int, bool) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url+"/webhooks/billing", bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set(billing.HeaderSignature, billing.Sign(payload, secret, time.Now()))
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Data struct {
+			Duplicate bool `json:"duplicate"`
+		} `json:"data"`
+	}
+	if res.StatusCode == http.StatusOK {
+		if err = json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+	}
+	return res.StatusCode, body.Data.Duplicate
+}
+
+func newBillingTestServer(t *testing.T) (string, *fakeRepo) {
+	t.Helper()
+
+	cfg := testConfig(t)
+	cfg.BillingWebhookSecret = testBillingSecret
+	repo := newFakeRepo()
+	repo.accounts[fixtureAccountID] = &repository.Account{ID: fixtureAccountID}
+	srv, _ := newTestServer(t, cfg, repo)
+	return srv.URL, repo
+}
+
+func assertSubscription(t *testing.T, repo *fakeRepo, wantPlanID, wantStatus string) {
+	t.Helper()
+
+	subscription, ok := repo.subscriptions[fixtureAccountID]
+	if !ok {
+		t.Fatal("subscription wasn't created")
+	}
+	if subscription.PlanID != wantPlanID || subscription.Status != wantStatus {
+		t.Errorf("subscription = %s/%s, want %s/%s", subscription.PlanID, subscription.Status, wantPlanID, wantStatus)
+	}
+}
+
+func TestBillingWebhookReplaysFixtures(t *testing.T) {
+	url, repo := newBillingTestServer(t)
+
+	steps := []struct {
+		fixture       string
+		wantDuplicate bool
+		wantPlanID    string
+		wantStatus    string
+	}{
+		{"subscription.created", false, "pro", "active"},
+		// Redelivered, e.g. because the response was lost.
+		{"subscription.created", true, "pro", "active"},
+		{"subscription.updated", false, "enterprise", "active"},
+		{"subscription.cancelled", false, "enterprise", "cancelled"},
+		{"subscription.updated", true, "enterprise", "cancelled"},
+	}
+
+	for _, step := range steps {
+		status, duplicate := postBillingEvent(t, url, readBillingFixture(t, step.fixture), testBillingSecret)
+		if status != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", step.fixture, status, http.StatusOK)
+		}
+		if duplicate != step.wantDuplicate {
+			t.Errorf("%s: duplicate = %v, want %v", step.fixture, duplicate, step.wantDuplicate)
+		}
+		assertSubscription(t, repo, step.wantPlanID, step.wantStatus)
+	}
+
+	if len(repo.billingEvents) != 3 {
+		t.Errorf("stored %d events, want 3", len(repo.billingEvents))
+	}
+}
+
+func TestBillingWebhookIgnoresOlderEvents(t *testing.T) {
+	url, repo := newBillingTestServer(t)
+
+	for _, fixture := range []string{"subscription.cancelled", "subscription.created", "subscription.updated"} {
+		if status, _ := postBillingEvent(t, url, readBillingFixture(t, fixture), testBillingSecret); status != http.StatusOK {
+			t.Fatalf("%s: status = %d, want %d", fixture, status, http.StatusOK)
+		}
+	}
+
+	// The cancellation is the latest event, so the events delivered after it are stored but not applied.
+	assertSubscription(t, repo, "enterprise", "cancelled")
+	if len(repo.billingEvents) != 3 {
+		t.Errorf("stored %d events, want 3", len(repo.billingEvents))
+	}
+}
+
+func TestBillingWebhookRejectsUnknownPlan(t *testing.T) {
+	url, repo := newBillingTestServer(t)
+
+	var event struct {
+		Data map[string]any `json:"data"`
+	}
+	payload := readBillingFixture(t, "subscription.created")
+	if err := json.Unmarshal(payload, &event); err != nil {
+		t.Fatalf("failed to unmarshal fixture: %v", err)
+	}
+	event.Data["plan_id"] = "unknown"
+	payload = withEventFields(t, payload, map[string]any{"data": event.Data})
+
+	if status, _ := postBillingEvent(t, url, payload, testBillingSecret); status != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+	// Rolled back, so that the retry isn't taken for a duplicate once the plan exists.
+	if _, ok := repo.billingEvents["evt_0001"]; ok {
+		t.Error("rejected event was stored")
+	}
+	if _, ok := repo.subscriptions[fixtureAccountID]; ok {
+		t.Error("rejected event was applied")
+	}
+}
+
+func TestBillingWebhookAuthentication(t *testing.T) {
+	payload := readBillingFixture(t, "subscription.created")
+
+	t.Run("wrong secret", func(t *testing.T) {
+		url, repo := newBillingTestServer(t)
+		if status, _ := postBillingEvent(t, url, payload, "whsec_other"); status != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", status, http.StatusUnauthorized)
+		}
+		if len(repo.billingEvents) != 0 {
+			t.Error("unauthenticated event was stored")
+		}
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		url, _ := newBillingTestServer(t)
+
+		req, err := http.NewRequest(http.MethodPost, url+"/webhooks/billing", bytes.NewReader(withEventFields(t, payload, map[string]any{"id": "evt_9999"})))
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set(billing.HeaderSignature, billing.Sign(payload, testBillingSecret, time.Now()))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+		}
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		srv, _ := newTestServer(t, testConfig(t), newFakeRepo())
+		if status, _ := postBillingEvent(t, srv.URL, payload, ""); status != http.StatusServiceUnavailable {
+			t.Errorf("status = %d, want %d", status, http.StatusServiceUnavailable)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/deps/email"
+	"github.com/rohitxdev/go-api/handler/middleware"
+)
+
+// sampleEmailData holds placeholder values for every variable used across the email templates.
+func sampleEmailData() map[string]any {
+	return map[string]any{
+		"code":         "A2B3C4",
+		"callbackURL":  "https://example.com/callback?token=sample-token",
+		"validMinutes": 10,
+		"validDays":    7,
+		"accountName":  "Acme Inc.",
+		"inviterEmail": "jane@example.com",
+		"year":         time.Now().Year(),
+	}
+}
+
+func (h *Handler) ListEmailTemplates(c echo.Context) error {
+	type emailTemplate struct {
+		Name      string   `json:"name"`
+		Languages []string `json:"languages"`
+	}
+
+	templates := h.Email.Templates()
+	out := make([]emailTemplate, 0, len(templates))
+	for name, langs := range templates {
+		out = append(out, emailTemplate{Name: name, Languages: langs})
+	}
+	slices.SortFunc(out, func(a, b emailTemplate) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+		Meta: echo.Map{
+			"supported_languages": middleware.SupportedLanguages(),
+		},
+	})
+}
+
+// PreviewEmailTemplate renders a template with sample data in every supported language.
+func (h *Handler) PreviewEmailTemplate(c echo.Context) error {
+	var req struct {
+		Name string `param:"name" validate:"required"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	out := make(map[string]*email.Rendered)
+	for _, lang := range middleware.SupportedLanguages() {
+		rendered, err := h.Email.Render(req.Name, lang, sampleEmailData())
+		if err != nil {
+			return emailRenderError(err)
+		}
+		out[lang] = rendered
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
+
+// ViewEmailTemplate renders a template with sample data as a page so that it can be viewed in the browser.
+func (h *Handler) ViewEmailTemplate(c echo.Context) error {
+	var req struct {
+		Name     string `param:"name" validate:"required"`
+		Language string `param:"lang" validate:"required"`
+		Format   string `query:"format" validate:"omitempty,oneof=html text"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	rendered, err := h.Email.Render(req.Name, req.Language, sampleEmailData())
+	if err != nil {
+		return emailRenderError(err)
+	}
+
+	if req.Format == "text" {
+		return c.String(http.StatusOK, rendered.TextBody)
+	}
+	return c.HTML(http.StatusOK, rendered.HTMLBody)
+}
+
+// ListSentEmails returns the latest messages sent by the email client.
+func (h *Handler) ListSentEmails(c echo.Context) error {
+	var req struct {
+		Limit int `query:"limit" validate:"omitempty,min=1,max=100"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+
+	type attachment struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"content_type"`
+		SizeInBytes int    `json:"size_in_bytes"`
+	}
+	type sentEmail struct {
+		From        string              `json:"from"`
+		To          []string            `json:"to"`
+		Cc          []string            `json:"cc"`
+		Bcc         []string            `json:"bcc"`
+		Subject     string              `json:"subject"`
+		Headers     map[string][]string `json:"headers"`
+		TextBody    string              `json:"text_body"`
+		HTMLBody    string              `json:"html_body"`
+		Attachments []attachment        `json:"attachments"`
+	}
+
+	messages := h.Email.Recent(req.Limit)
+	out := make([]sentEmail, 0, len(messages))
+	for _, msg := range messages {
+		attachments := make([]attachment, 0, len(msg.Attachments))
+		for _, a := range msg.Attachments {
+			attachments = append(attachments, attachment{
+				Filename:    a.Filename,
+				ContentType: a.ContentType,
+				SizeInBytes: len(a.Data),
+			})
+		}
+		out = append(out, sentEmail{
+			From:        msg.FromAddress,
+			To:          msg.To,
+			Cc:          msg.Cc,
+			Bcc:         msg.Bcc,
+			Subject:     msg.Subject,
+			Headers:     msg.Headers,
+			TextBody:    msg.TextBody,
+			HTMLBody:    msg.HTMLBody,
+			Attachments: attachments,
+		})
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
+
+func emailRenderError(err error) error {
+	if errors.Is(err, email.ErrTemplateNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, "email template not found")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "failed to render email template").SetInternal(err)
+}
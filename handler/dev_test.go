@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/rohitxdev/go-api/deps/config"
+)
+
+func TestListSentEmailsRequiresSignIn(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.AppEnv = config.EnvDevelopment
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	if status := doJSON(t, newTestClient(t), http.MethodPost, srv.URL+"/auth/otp/send", map[string]string{"email": "jane@example.com"}, nil); status != http.StatusOK {
+		t.Fatalf("send OTP: status = %d, want %d", status, http.StatusOK)
+	}
+
+	if status := doJSON(t, newTestClient(t), http.MethodGet, srv.URL+"/dev/emails/sent", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("signed out: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// Any signed-in user can see them, since the routes only exist in development.
+	_, sessionID := repo.addSession("john@example.com")
+	client := newSignedInClient(t, srv, cfg, sessionID)
+	var res struct {
+		Data []struct {
+			To []string `json:"to"`
+		} `json:"data"`
+	}
+	if status := doJSON(t, client, http.MethodGet, srv.URL+"/dev/emails/sent", nil, &res); status != http.StatusOK {
+		t.Fatalf("signed in: status = %d, want %d", status, http.StatusOK)
+	}
+	if len(res.Data) != 1 || len(res.Data[0].To) != 1 || res.Data[0].To[0] != "jane@example.com" {
+		t.Errorf("sent emails = %+v, want the OTP email to jane@example.com", res.Data)
+	}
+}
+
+func TestDevRoutesOnlyInDevelopment(t *testing.T) {
+	cfg := testConfig(t)
+	srv, _ := newTestServer(t, cfg, newFakeRepo())
+
+	if status := doJSON(t, newTestClient(t), http.MethodGet, srv.URL+"/dev/emails/templates", nil, nil); status != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", status, http.StatusNotFound)
+	}
+}
@@ -19,10 +19,16 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/rohitxdev/go-api/assets"
 	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/activity"
 	"github.com/rohitxdev/go-api/deps/blobstore"
 	"github.com/rohitxdev/go-api/deps/cache"
 	"github.com/rohitxdev/go-api/deps/config"
 	"github.com/rohitxdev/go-api/deps/email"
+	"github.com/rohitxdev/go-api/deps/identity"
+	"github.com/rohitxdev/go-api/deps/lockout"
+	"github.com/rohitxdev/go-api/deps/ratelimit"
+	"github.com/rohitxdev/go-api/deps/usage"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
 	"github.com/rohitxdev/go-api/handler/middleware"
 	"github.com/rohitxdev/go-api/util"
 )
@@ -33,13 +39,18 @@ const (
 )
 
 type Dependencies struct {
-	BlobStore *blobstore.BlobStore
-	Config    *config.Store
-	Cache     *cache.Cache[string]
-	Email     *email.Client
-	Logger    *slog.Logger
-	Redis     *redis.Client
-	Repo      *repository.Queries
+	BlobStore      *blobstore.BlobStore
+	Config         *config.Store
+	Cache          *cache.Cache[string]
+	Email          *email.Client
+	Logger         *slog.Logger
+	OIDCProviders  *identity.Providers
+	OTPGuard       *lockout.Guard
+	RateLimiter    *ratelimit.Limiter
+	Redis          *redis.Client
+	Repo           repository.Store
+	SessionTracker *activity.Tracker
+	Usage          *usage.Meter
 }
 
 type Handler struct {
@@ -59,16 +70,117 @@ func registerRoutes(e *echo.Echo, h *Handler) {
 		views.GET("/home", h.Home)
 	}
 
-	auth := e.Group("/auth")
+	requireAuth := middleware.RequireAuth(h.Repo, h.SessionTracker, h.Config, h.Logger)
+	// Sign-in methods & sessions can't be managed with API keys, so that a leaked key can't be turned into more access.
+	requireSession := middleware.RequireSession()
+
+	auth := e.Group("/auth", middleware.RateLimit(h.RateLimiter, h.Config, middleware.RateLimitOpts{
+		Name:  "auth",
+		Limit: func(cfg *config.Config) config.RateLimit { return cfg.RateLimitAuth },
+	}))
 	{
-		auth.POST("/otp/send", h.SendAuthOTP)
-		auth.POST("/otp/verify", h.VerifyAuthOTP)
+		auth.POST("/otp/send", h.SendAuthOTP, middleware.RateLimit(h.RateLimiter, h.Config, middleware.RateLimitOpts{
+			Name:  "otp_send",
+			Limit: func(cfg *config.Config) config.RateLimit { return cfg.RateLimitOTPSend },
+		}))
+		auth.POST("/otp/verify", h.VerifyAuthOTP, middleware.RateLimit(h.RateLimiter, h.Config, middleware.RateLimitOpts{
+			Name:  "otp_verify",
+			Limit: func(cfg *config.Config) config.RateLimit { return cfg.RateLimitOTPVerify },
+		}))
+		auth.POST("/magic-link/send", h.SendMagicLink, middleware.RateLimit(h.RateLimiter, h.Config, middleware.RateLimitOpts{
+			Name:  "magic_link_send",
+			Limit: func(cfg *config.Config) config.RateLimit { return cfg.RateLimitMagicLinkSend },
+		}))
+		auth.GET("/magic-link/verify", h.ConfirmMagicLink)
+		auth.POST("/magic-link/verify", h.VerifyMagicLink)
+		auth.POST("/totp/verify", h.VerifyTOTPChallenge, middleware.RateLimit(h.RateLimiter, h.Config, middleware.RateLimitOpts{
+			Name:  "totp_verify",
+			Limit: func(cfg *config.Config) config.RateLimit { return cfg.RateLimitOTPVerify },
+		}))
 		auth.POST("/sign-out", h.SignOut)
+		auth.POST("/token", h.IssueToken)
+		auth.POST("/token/revoke", h.RevokeToken)
+
+		auth.POST("/passkeys/login/begin", h.BeginPasskeyLogin)
+		auth.POST("/passkeys/login/finish", h.FinishPasskeyLogin)
+		auth.GET("/passkeys", h.ListMyPasskeys, requireAuth, requireSession)
+		auth.POST("/passkeys/register/begin", h.BeginPasskeyRegistration, requireAuth, requireSession)
+		auth.POST("/passkeys/register/finish", h.FinishPasskeyRegistration, requireAuth, requireSession)
+		auth.DELETE("/passkeys/:id", h.DeleteMyPasskey, requireAuth, requireSession)
+
+		auth.GET("/oidc/providers", h.ListOIDCProviders)
+		auth.GET("/oidc/:provider/start", h.StartOIDCSignIn)
+		auth.GET("/oidc/:provider/callback", h.OIDCCallback)
 	}
 
-	users := e.Group("/users")
+	requireAccount := middleware.RequireAccount(h.Repo)
+
+	users := e.Group("/users", requireAuth)
 	{
 		users.GET("/me", h.GetMe)
+		users.GET("/me/sessions", h.ListMySessions, requireSession)
+		users.DELETE("/me/sessions/:id", h.RevokeMySession, requireSession)
+		users.POST("/me/sessions/revoke-others", h.RevokeMyOtherSessions, requireSession)
+		users.GET("/me/account", h.GetAccount, requireAccount, middleware.RequireScope(handlerutil.PermAccountsRead))
+		users.POST("/me/totp", h.EnrollTOTP, requireSession)
+		users.POST("/me/totp/confirm", h.ConfirmTOTP, requireSession)
+		users.DELETE("/me/totp", h.DisableTOTP, requireSession)
+		// Keys are managed through the active account, whose plan must include them.
+		requireAPIKeys := middleware.RequireSubscription(h.Repo, h.Config, config.FeatureAPIKeys)
+		users.GET("/me/api-keys", h.ListMyAPIKeys, requireSession, requireAPIKeys)
+		users.POST("/me/api-keys", h.CreateMyAPIKey, requireSession, requireAPIKeys)
+		users.DELETE("/me/api-keys/:id", h.RevokeMyAPIKey, requireSession, requireAPIKeys)
+	}
+
+	accounts := e.Group("/accounts", requireAuth, middleware.RateLimit(h.RateLimiter, h.Config, middleware.RateLimitOpts{
+		Name:  "accounts",
+		Limit: func(cfg *config.Config) config.RateLimit { return cfg.RateLimitAccounts },
+		Key:   middleware.RateLimitByUser,
+	}))
+	{
+		accounts.POST("", h.CreateAccount, middleware.RequireScope(handlerutil.PermAccountsWrite))
+		accounts.GET("", h.ListMyAccounts, middleware.RequireScope(handlerutil.PermAccountsRead))
+		// Not metered, so that accounts over their quota can still check it.
+		accounts.GET("/:account_id/entitlements", h.GetAccountEntitlements, middleware.RequirePermission(h.Repo, handlerutil.PermAccountsRead))
+		accounts.GET("/:account_id/usage", h.GetAccountUsage, middleware.RequirePermission(h.Repo, handlerutil.PermAccountsRead))
+	}
+
+	// Requests of accounts whose subscription has expired are rejected before they are metered.
+	account := accounts.Group("/:account_id", middleware.RequireSubscription(h.Repo, h.Config), middleware.MeterRequests(h.Repo, h.Config, h.Usage, h.Logger))
+	{
+		account.GET("", h.GetAccount, middleware.RequirePermission(h.Repo, handlerutil.PermAccountsRead))
+		account.POST("/select", h.SelectAccount, requireSession, requireAccount)
+		account.PATCH("", h.RenameAccount, middleware.RequirePermission(h.Repo, handlerutil.PermAccountsWrite))
+		account.DELETE("", h.DeleteAccount, middleware.RequirePermission(h.Repo, handlerutil.PermAccountsDelete))
+		account.POST("/transfer-ownership", h.TransferAccountOwnership, middleware.RequirePermission(h.Repo, handlerutil.PermAccountsTransfer))
+		account.GET("/invitations", h.ListAccountInvitations, middleware.RequirePermission(h.Repo, handlerutil.PermMembersRead))
+		account.POST("/invitations", h.InviteAccountMember, middleware.RequirePermission(h.Repo, handlerutil.PermMembersWrite))
+		account.POST("/invitations/:id/resend", h.ResendAccountInvitation, middleware.RequirePermission(h.Repo, handlerutil.PermMembersWrite))
+		account.DELETE("/invitations/:id", h.RevokeAccountInvitation, middleware.RequirePermission(h.Repo, handlerutil.PermMembersWrite))
+	}
+
+	// Anyone with the token from the invitation email can respond to it.
+	invitations := e.Group("/invitations")
+	{
+		invitations.POST("/accept", h.AcceptAccountInvitation)
+		invitations.POST("/decline", h.DeclineAccountInvitation)
+	}
+
+	// Authenticated by their signature instead of a session.
+	webhooks := e.Group("/webhooks")
+	{
+		webhooks.POST("/billing", h.BillingWebhook)
+	}
+
+	// Development helpers, only exposed in development. Sent emails contain OTPs & sign-in links, so they need a signed-in user.
+	if h.Config.Get().AppEnv == config.EnvDevelopment {
+		devEmails := e.Group("/dev/emails")
+		{
+			devEmails.GET("/templates", h.ListEmailTemplates)
+			devEmails.GET("/templates/:name", h.PreviewEmailTemplate)
+			devEmails.GET("/templates/:name/:lang", h.ViewEmailTemplate)
+			devEmails.GET("/sent", h.ListSentEmails, requireAuth)
+		}
 	}
 }
 
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/assets"
+	"github.com/rohitxdev/go-api/deps/activity"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/deps/email"
+	"github.com/rohitxdev/go-api/deps/identity"
+	"github.com/rohitxdev/go-api/deps/lockout"
+	"github.com/rohitxdev/go-api/deps/ratelimit"
+	"github.com/rohitxdev/go-api/deps/usage"
+	"github.com/rohitxdev/go-api/util"
+)
+
+// testConfig returns a valid config for the 'testing' environment, which the tests adjust before the server is created.
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+
+	return &config.Config{
+		Build: config.Build{
+			AppName:        "go-api",
+			AppVersion:     "test",
+			BuildType:      "debug",
+			BuildTimestamp: time.Now(),
+		},
+		Runtime: config.Runtime{
+			AppEnv:             config.EnvTest,
+			TmpDir:             t.TempDir(),
+			HTTPHost:           "localhost",
+			HTTPPort:           "8443",
+			AllowedOrigins:     []string{"https://localhost:8443"},
+			PublicURL:          "https://localhost:8443",
+			SessionIdleTimeout: time.Hour,
+			SessionMaxLifetime: time.Hour * 24,
+		},
+		Secrets: config.Secrets{
+			PostgresURL:   "postgres://localhost:5432/test",
+			RedisURL:      "redis://localhost:6379",
+			SessionSecret: strings.Repeat("s", 64),
+		},
+		SMTP: config.SMTP{
+			EmailTransport: "memory",
+			SMTPTLSMode:    "starttls",
+		},
+		Features: config.Features{
+			OTPEchoEnabled: true,
+		},
+		Billing: config.Billing{
+			DefaultPlanID:           "free",
+			BillingWebhookTolerance: time.Minute * 5,
+		},
+		Tokens: config.Tokens{
+			AccessTokenTTL:      time.Minute * 15,
+			AccessTokenAudience: "api",
+			RefreshTokenTTL:     time.Hour * 24,
+		},
+	}
+}
+
+// newTestServer serves the routes of a handler backed by repo & an in-memory redis. It mirrors 'New' without the middleware that can only be registered once per process, e.g. metrics.
+func newTestServer(t *testing.T, cfg *config.Config, repo *fakeRepo) (*httptest.Server, *Handler) {
+	t.Helper()
+
+	configStore, err := config.NewStoreFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("failed to create config store: %v", err)
+	}
+
+	templatesFS, err := fs.Sub(assets.FS, "templates/emails")
+	if err != nil {
+		t.Fatalf("failed to get email templates: %v", err)
+	}
+	ec, err := email.New(email.NewMemoryTransport(100), &email.Sender{Address: "noreply@example.com", Name: "go-api"}, templatesFS)
+	if err != nil {
+		t.Fatalf("failed to create email client: %v", err)
+	}
+	// Tests read the sent emails with 'Recent'.
+	ec.CaptureRecent(100)
+
+	rdb := newFakeRedis()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := &Handler{
+		Dependencies: &Dependencies{
+			Config:         configStore,
+			Email:          ec,
+			Logger:         logger,
+			OIDCProviders:  identity.NewProviders(),
+			OTPGuard:       lockout.NewGuard(rdb, nil),
+			RateLimiter:    ratelimit.New(rdb, logger),
+			Redis:          rdb,
+			Repo:           repo,
+			SessionTracker: activity.NewTracker(rdb, repo, configStore, logger, nil),
+			Usage:          usage.NewMeter(rdb, repo, logger, nil),
+		},
+	}
+
+	e := echo.New()
+	e.JSONSerializer = JSONSerializer{}
+	e.Validator = requestValidator{
+		validator: util.Validate,
+	}
+	pageTemplates, err := template.ParseFS(assets.FS, "templates/pages/*.tmpl")
+	if err != nil {
+		t.Fatalf("failed to parse templates: %v", err)
+	}
+	e.Renderer = viewRenderer{
+		templates: pageTemplates,
+	}
+	e.Use(session.Middleware(sessions.NewCookieStore([]byte(cfg.SessionSecret))))
+	registerRoutes(e, h)
+
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+
+	return srv, h
+}
+
+// newTestClient keeps cookies between requests & doesn't follow redirects, so that tests can inspect them.
+func newTestClient(t *testing.T) *http.Client {
+	t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("failed to create cookie jar: %v", err)
+	}
+	return &http.Client{
+		Jar: jar,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// newSignedInClient returns a client with the cookie of the session, like after signing in.
+func newSignedInClient(t *testing.T, srv *httptest.Server, cfg *config.Config, sessionID pgtype.UUID) *http.Client {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	sess, err := sessions.NewCookieStore([]byte(cfg.SessionSecret)).Get(req, "session")
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	sess.Values["sessionID"] = sessionID.String()
+	if err = sess.Save(req, rec); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	client := newTestClient(t)
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server URL: %v", err)
+	}
+	client.Jar.SetCookies(srvURL, rec.Result().Cookies())
+	return client
+}
+
+// doJSON sends body as JSON, decodes the response into out if it isn't nil & returns the status.
+func doJSON(t *testing.T, client *http.Client, method string, url string, body any, out any) int {
+	t.Helper()
+
+	var reqBody io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("failed to marshal request body: %v", err)
+		}
+		reqBody = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if body != nil {
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	defer res.Body.Close()
+
+	if out != nil {
+		if err = json.NewDecoder(res.Body).Decode(out); err != nil {
+			t.Fatalf("failed to decode response of %s %s (%d): %v", method, url, res.StatusCode, err)
+		}
+	}
+	return res.StatusCode
+}
@@ -0,0 +1,53 @@
+package handlerutil
+
+import (
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+)
+
+// SelectedAccountID returns the ID of the account stored in the session cookie by 'SelectAccount'. The user may no longer be a member of it.
+func SelectedAccountID(c echo.Context) (pgtype.UUID, bool) {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return pgtype.UUID{}, false
+	}
+
+	accountIDStr, ok := sess.Values["accountID"].(string)
+	if !ok {
+		return pgtype.UUID{}, false
+	}
+
+	accountID, err := uuid.Parse(accountIDStr)
+	if err != nil {
+		return pgtype.UUID{}, false
+	}
+
+	return pgtype.UUID{Bytes: accountID, Valid: true}, true
+}
+
+// SelectAccount stores the account in the session cookie, so that subsequent requests target it without the 'X-Account-ID' header.
+func SelectAccount(c echo.Context, accountID pgtype.UUID) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return err
+	}
+
+	sess.Values["accountID"] = accountID.String()
+
+	return sess.Save(c.Request(), c.Response())
+}
+
+// CurrentAccount returns the account resolved by 'middleware.RequireAccount' or 'middleware.RequirePermission', or nil if the route isn't behind either.
+func CurrentAccount(c echo.Context) *repository.Account {
+	account, _ := c.Get("account").(*repository.Account)
+	return account
+}
+
+// CurrentMembership returns the user's membership in the account returned by 'CurrentAccount'. It is nil for staff that aren't members of the account.
+func CurrentMembership(c echo.Context) *repository.UserAccount {
+	membership, _ := c.Get("membership").(*repository.UserAccount)
+	return membership
+}
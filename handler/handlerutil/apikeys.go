@@ -0,0 +1,45 @@
+package handlerutil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/rohitxdev/go-api/util"
+)
+
+// API keys look like 'ak_<prefix>_<secret>'. The prefix is stored in plaintext to look the key up, & the secret is stored hashed.
+const (
+	apiKeyMarker    = "ak_"
+	apiKeyPrefixLen = 8
+	apiKeySecretLen = 32
+)
+
+// NewAPIKey generates an API key & returns it along with its prefix & secret.
+func NewAPIKey() (key string, prefix string, secret string, err error) {
+	buf := make([]byte, apiKeyPrefixLen)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(buf)
+
+	secret, err = util.GenerateToken(apiKeySecretLen)
+	if err != nil {
+		return "", "", "", err
+	}
+	return apiKeyMarker + prefix + "_" + secret, prefix, secret, nil
+}
+
+// ParseAPIKey splits an API key generated by 'NewAPIKey' into its prefix & secret.
+func ParseAPIKey(key string) (prefix string, secret string, ok bool) {
+	rest, ok := strings.CutPrefix(key, apiKeyMarker)
+	if !ok {
+		return "", "", false
+	}
+	// Secrets are base64url encoded & may contain '_', prefixes are hex encoded & can't.
+	prefix, secret, ok = strings.Cut(rest, "_")
+	if !ok || len(prefix) != hex.EncodedLen(apiKeyPrefixLen) || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
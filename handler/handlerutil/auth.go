@@ -1,6 +1,10 @@
 package handlerutil
 
 import (
+	"context"
+	"log/slog"
+	"slices"
+
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo-contrib/session"
@@ -8,33 +12,78 @@ import (
 	"github.com/rohitxdev/go-api/database/repository"
 )
 
-func CurrentUser(c echo.Context, repo repository.Querier) *repository.User {
-	user, ok := c.Get("user").(*repository.User)
-	if ok {
-		return user
-	}
-
+// CurrentSessionID returns the ID of the session stored in the session cookie. The session may be expired or revoked.
+func CurrentSessionID(c echo.Context) (pgtype.UUID, bool) {
 	sess, err := session.Get("session", c)
 	if err != nil {
-		return nil
+		return pgtype.UUID{}, false
 	}
 
 	sesssionIDStr, ok := sess.Values["sessionID"].(string)
 	if !ok {
-		return nil
+		return pgtype.UUID{}, false
 	}
 
 	sessionID, err := uuid.Parse(sesssionIDStr)
 	if err != nil {
+		return pgtype.UUID{}, false
+	}
+
+	return pgtype.UUID{Bytes: sessionID, Valid: true}, true
+}
+
+// SessionTracker records session activity, see 'activity.Tracker'.
+type SessionTracker interface {
+	Touch(ctx context.Context, sessionID pgtype.UUID) error
+}
+
+// CurrentUser resolves the user of a valid session & records the session's activity with tracker, if not nil. Tracking failures are logged with logger.
+func CurrentUser(c echo.Context, repo repository.Querier, tracker SessionTracker, logger *slog.Logger) *repository.User {
+	user, ok := c.Get("user").(*repository.User)
+	if ok {
+		return user
+	}
+
+	sessionID, ok := CurrentSessionID(c)
+	if !ok {
 		return nil
 	}
 
-	user, err = repo.GetUserBySessionId(c.Request().Context(), pgtype.UUID{Bytes: sessionID, Valid: true})
+	user, err := repo.GetUserBySessionId(c.Request().Context(), sessionID)
 	if err != nil {
 		return nil
 	}
 
+	if tracker != nil {
+		// Activity tracking is best-effort & must not fail the request.
+		if err := tracker.Touch(c.Request().Context(), sessionID); err != nil {
+			logger.Warn("failed to track session activity", slog.String("error", err.Error()))
+		}
+	}
+
 	c.Set("user", user)
 
 	return user
 }
+
+// AuthenticatedUser returns the user resolved by 'middleware.RequireAuth'. It panics if the route isn't behind that middleware, as that is a programming error.
+func AuthenticatedUser(c echo.Context) *repository.User {
+	user, ok := c.Get("user").(*repository.User)
+	if !ok || user == nil {
+		panic("handlerutil: AuthenticatedUser called on a route without middleware.RequireAuth")
+	}
+
+	return user
+}
+
+// CurrentScopes returns the scopes of the API key or access token the request was authenticated with by 'middleware.RequireAuth'. It returns false for requests authenticated with a session, which have every scope.
+func CurrentScopes(c echo.Context) ([]string, bool) {
+	scopes, ok := c.Get("scopes").([]string)
+	return scopes, ok
+}
+
+// HasScope reports whether the request may act with the scope.
+func HasScope(c echo.Context, scope string) bool {
+	scopes, ok := CurrentScopes(c)
+	return !ok || slices.Contains(scopes, scope)
+}
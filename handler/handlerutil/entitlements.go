@@ -0,0 +1,92 @@
+package handlerutil
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/billing"
+	"github.com/rohitxdev/go-api/deps/config"
+)
+
+// AccountEntitlements is what the plan of an account allows.
+type AccountEntitlements struct {
+	PlanID string
+	Plan   *config.Plan
+	// Nil for accounts on the default plan, which never expires.
+	Subscription *repository.Subscription
+}
+
+// Expired reports whether the subscription has ended, or was cancelled or isn't paid for.
+func (e *AccountEntitlements) Expired() bool {
+	if e.Subscription == nil {
+		return false
+	}
+	switch e.Subscription.Status {
+	case billing.StatusCancelled, billing.StatusPastDue:
+		return true
+	}
+	return !e.Subscription.EndsAt.Time.After(time.Now())
+}
+
+func (e *AccountEntitlements) HasFeature(feature string) bool {
+	return slices.Contains(e.Plan.Features, feature)
+}
+
+// Limit returns the plan's limit, which is 'config.Unlimited' if the plan lifts it & 0 if the plan doesn't set it.
+func (e *AccountEntitlements) Limit(name string) int64 {
+	return e.Plan.Limits[name]
+}
+
+// WithinLimit reports whether a usage of n stays within the plan's limit.
+func (e *AccountEntitlements) WithinLimit(name string, n int64) bool {
+	limit := e.Limit(name)
+	return limit == config.Unlimited || n <= limit
+}
+
+// ResolveEntitlements resolves the entitlements of the account returned by 'CurrentAccount' once per request.
+func ResolveEntitlements(c echo.Context, repo repository.Querier, cfg *config.Config) (*AccountEntitlements, error) {
+	if e := Entitlements(c); e != nil {
+		return e, nil
+	}
+
+	account := CurrentAccount(c)
+	if account == nil {
+		return nil, errors.New("no account resolved for the request")
+	}
+
+	planID := cfg.DefaultPlanID
+	subscription, err := repo.GetSubscriptionByAccountID(c.Request().Context(), account.ID)
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("failed to get subscription: %w", err)
+		}
+		subscription = nil
+	} else {
+		planID = subscription.PlanID
+	}
+
+	plan, err := cfg.Plan(planID)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &AccountEntitlements{
+		PlanID:       planID,
+		Plan:         plan,
+		Subscription: subscription,
+	}
+	c.Set("entitlements", e)
+
+	return e, nil
+}
+
+// Entitlements returns the entitlements of the current account resolved by 'ResolveEntitlements', or nil if they haven't been resolved.
+func Entitlements(c echo.Context) *AccountEntitlements {
+	e, _ := c.Get("entitlements").(*AccountEntitlements)
+	return e
+}
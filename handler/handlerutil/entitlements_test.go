@@ -0,0 +1,35 @@
+package handlerutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/billing"
+)
+
+func TestEntitlementsExpired(t *testing.T) {
+	future := pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true}
+	past := pgtype.Timestamptz{Time: time.Now().Add(-time.Hour), Valid: true}
+
+	tests := []struct {
+		name         string
+		subscription *repository.Subscription
+		want         bool
+	}{
+		{name: "default plan", want: false},
+		{name: "active", subscription: &repository.Subscription{Status: billing.StatusActive, EndsAt: future}, want: false},
+		{name: "ended", subscription: &repository.Subscription{Status: billing.StatusActive, EndsAt: past}, want: true},
+		{name: "cancelled", subscription: &repository.Subscription{Status: billing.StatusCancelled, EndsAt: future}, want: true},
+		{name: "past due", subscription: &repository.Subscription{Status: billing.StatusPastDue, EndsAt: future}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &AccountEntitlements{Subscription: tt.subscription}
+			if got := e.Expired(); got != tt.want {
+				t.Errorf("Expired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
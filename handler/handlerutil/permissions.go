@@ -0,0 +1,74 @@
+package handlerutil
+
+import (
+	"slices"
+
+	"github.com/rohitxdev/go-api/database/repository"
+)
+
+// Global user roles, stored in 'users.role'.
+const (
+	UserRoleUser  = "user"
+	UserRoleStaff = "staff"
+)
+
+// Account membership roles, stored in 'user_accounts.role'.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// Permissions are named '<resource>:<action>'.
+const (
+	PermAccountsRead     = "accounts:read"
+	PermAccountsWrite    = "accounts:write"
+	PermAccountsDelete   = "accounts:delete"
+	PermAccountsTransfer = "accounts:transfer"
+	PermMembersRead      = "members:read"
+	PermMembersWrite     = "members:write"
+	PermBillingRead      = "billing:read"
+	PermBillingWrite     = "billing:write"
+)
+
+// rolePermissions is the permission matrix of account roles. Every role has a subset of the permissions of the role above it.
+var rolePermissions = map[string][]string{
+	RoleOwner: {
+		PermAccountsRead, PermAccountsWrite, PermAccountsDelete, PermAccountsTransfer,
+		PermMembersRead, PermMembersWrite,
+		PermBillingRead, PermBillingWrite,
+	},
+	RoleAdmin: {
+		PermAccountsRead, PermAccountsWrite,
+		PermMembersRead, PermMembersWrite,
+		PermBillingRead, PermBillingWrite,
+	},
+	RoleMember: {
+		PermAccountsRead,
+		PermMembersRead,
+	},
+	RoleViewer: {
+		PermAccountsRead,
+	},
+}
+
+// Roles returns the account roles, highest first.
+func Roles() []string {
+	return []string{RoleOwner, RoleAdmin, RoleMember, RoleViewer}
+}
+
+// HasPermission reports whether the account role grants the permission. Unknown roles & permissions are denied.
+func HasPermission(role string, permission string) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// IsStaff reports whether the user has the global staff role. Staff have every permission on every account.
+func IsStaff(user *repository.User) bool {
+	return user != nil && user.Role == UserRoleStaff
+}
+
+// Permissions returns every permission. They are also the scopes that API keys can be given.
+func Permissions() []string {
+	return slices.Clone(rolePermissions[RoleOwner])
+}
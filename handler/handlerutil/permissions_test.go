@@ -0,0 +1,51 @@
+package handlerutil
+
+import (
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	// Spelled out rather than derived from 'rolePermissions', so that changes to the matrix must be made on purpose.
+	granted := map[string]map[string]bool{
+		RoleOwner: {
+			PermAccountsRead: true, PermAccountsWrite: true, PermAccountsDelete: true, PermAccountsTransfer: true,
+			PermMembersRead: true, PermMembersWrite: true,
+			PermBillingRead: true, PermBillingWrite: true,
+		},
+		RoleAdmin: {
+			PermAccountsRead: true, PermAccountsWrite: true,
+			PermMembersRead: true, PermMembersWrite: true,
+			PermBillingRead: true, PermBillingWrite: true,
+		},
+		RoleMember: {
+			PermAccountsRead: true,
+			PermMembersRead:  true,
+		},
+		RoleViewer: {
+			PermAccountsRead: true,
+		},
+		"unknown": {},
+	}
+
+	permissions := append(Permissions(), "unknown:read")
+	for role, perms := range granted {
+		for _, perm := range permissions {
+			t.Run(role+"/"+perm, func(t *testing.T) {
+				if got, want := HasPermission(role, perm), perms[perm]; got != want {
+					t.Errorf("HasPermission(%q, %q) = %v, want %v", role, perm, got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestRolesAreOrderedByPermissions(t *testing.T) {
+	roles := Roles()
+	for i := 1; i < len(roles); i++ {
+		for _, perm := range rolePermissions[roles[i]] {
+			if !HasPermission(roles[i-1], perm) {
+				t.Errorf("%s has %s but %s, the role above it, doesn't", roles[i], perm, roles[i-1])
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handlerutil
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/util"
+)
+
+// AccessTokenClaims are the claims of the access tokens issued by '/auth/token', see RFC 9068.
+type AccessTokenClaims struct {
+	jwt.RegisteredClaims
+	// ID of the session the token was issued for. Revoking the session revokes the token.
+	SessionID string `json:"sid"`
+	// Space-separated scopes, see 'Permissions'.
+	Scope string `json:"scope"`
+}
+
+// SignAccessToken issues an access token to the user for the session, which expires after the configured TTL.
+func SignAccessToken(cfg *config.Config, userID pgtype.UUID, sessionID pgtype.UUID, scopes []string) (string, error) {
+	key, err := cfg.JWTKey()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return util.SignJWT(&AccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    cfg.AppName,
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{cfg.AccessTokenAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(cfg.AccessTokenTTL)),
+		},
+		SessionID: sessionID.String(),
+		Scope:     strings.Join(scopes, " "),
+	}, key)
+}
+
+// VerifyAccessToken returns the claims of an access token issued by 'SignAccessToken', if it is valid. It doesn't check the session.
+func VerifyAccessToken(cfg *config.Config, token string) (*AccessTokenClaims, error) {
+	key, err := cfg.JWTKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var claims AccessTokenClaims
+	if err = util.VerifyJWT(token, &claims, key.Public().(ed25519.PublicKey), cfg.AppName, cfg.AccessTokenAudience); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
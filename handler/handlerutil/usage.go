@@ -0,0 +1,13 @@
+package handlerutil
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// UsageMeter counts usage per account, see 'usage.Meter'.
+type UsageMeter interface {
+	Usage(ctx context.Context, accountID pgtype.UUID, metric string) (int64, error)
+	Add(ctx context.Context, accountID pgtype.UUID, metric string, n int64) (int64, error)
+}
@@ -1,17 +1,27 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/bytedance/sonic/decoder"
 	"github.com/go-playground/validator"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
 )
 
 type APISuccessResponse struct {
@@ -113,3 +123,82 @@ func canonicalizeEmail(email string) string {
 	username = strings.ReplaceAll(username, ".", "")
 	return username + "@" + domain
 }
+
+// userAgent returns the request's user agent trimmed to fit the 'sessions.user_agent' column, or nil if it's too short to be meaningful.
+func userAgent(c echo.Context) *string {
+	ua := []rune(strings.TrimSpace(c.Request().UserAgent()))
+	if len(ua) < 4 {
+		return nil
+	}
+	if len(ua) > 256 {
+		ua = ua[:256]
+	}
+
+	s := string(ua)
+	return &s
+}
+
+// parseUUID parses the string form of a UUID, e.g. from a path param, into its postgres type.
+func parseUUID(s string) (pgtype.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return pgtype.UUID{}, echo.NewHTTPError(http.StatusUnprocessableEntity, "invalid UUID").SetInternal(err)
+	}
+	return pgtype.UUID{Bytes: id, Valid: true}, nil
+}
+
+// inTx runs fn in a transaction that is committed if fn returns nil & rolled back otherwise. HTTP errors returned by fn are passed through, other errors become a 500.
+//
+// Handlers that run a single statement, e.g. 'RenameAccount', don't need it, as Postgres already runs every statement atomically in its own transaction.
+func (h *Handler) inTx(ctx context.Context, fn func(repo repository.Querier) error) error {
+	err := h.Repo.InTx(ctx, fn)
+	if err == nil {
+		return nil
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "failed to run database transaction").SetInternal(err)
+}
+
+// publicURL returns the URL of path on this server. It is never derived from the request, so that a forged Host header can't point links elsewhere.
+func (h *Handler) publicURL(path string, params url.Values) string {
+	u := strings.TrimSuffix(h.Config.Get().PublicURL, "/") + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	return u
+}
+
+// callbackURL sets params on rawURL, which must point to one of the allowed origins. Links sent to users must never lead elsewhere, so the CORS wildcard isn't honoured.
+func (h *Handler) callbackURL(rawURL string, params url.Values) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", echo.NewHTTPError(http.StatusUnprocessableEntity, "invalid callback URL")
+	}
+	if !slices.Contains(h.Config.Get().AllowedOrigins, u.Scheme+"://"+u.Host) {
+		return "", echo.NewHTTPError(http.StatusUnprocessableEntity, "callback URL is not an allowed origin")
+	}
+
+	query := u.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// isUniqueViolation reports whether err is caused by a unique constraint of postgres.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// retryLater rejects the request with 429, telling the client how long to wait.
+func retryLater(c echo.Context, wait time.Duration, msg string) error {
+	c.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return echo.NewHTTPError(http.StatusTooManyRequests, msg)
+}
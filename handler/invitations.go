@@ -0,0 +1,389 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/deps/email"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/util"
+)
+
+const invitationValidity = time.Hour * 24 * 7
+
+// Invitation statuses, stored in 'account_invitations.status'.
+const (
+	invitationAccepted = "accepted"
+	invitationDeclined = "declined"
+)
+
+type accountInvitation struct {
+	ID        pgtype.UUID `json:"id"`
+	Email     string      `json:"email"`
+	Role      string      `json:"role"`
+	Status    string      `json:"status"`
+	InvitedBy pgtype.UUID `json:"invited_by"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func newAccountInvitation(inv *repository.AccountInvitation) accountInvitation {
+	return accountInvitation{
+		ID:        inv.ID,
+		Email:     inv.Email,
+		Role:      inv.Role,
+		Status:    inv.Status,
+		InvitedBy: inv.InvitedBy,
+		ExpiresAt: inv.ExpiresAt.Time,
+		CreatedAt: inv.CreatedAt.Time,
+	}
+}
+
+// newInvitationToken returns a token & the hash that is stored in its place.
+func newInvitationToken() (string, string, error) {
+	token, err := util.GenerateToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, util.HashToken(token), nil
+}
+
+// sendInvitationEmail emails the invitation link. The token is only ever sent to the invitee, never returned by the API.
+func (h *Handler) sendInvitationEmail(c echo.Context, inv *repository.AccountInvitation, token string, callbackURL string) error {
+	link, err := h.callbackURL(callbackURL, url.Values{"token": {token}})
+	if err != nil {
+		return err
+	}
+
+	account := handlerutil.CurrentAccount(c)
+	accountName := ""
+	if account.Name != nil {
+		accountName = *account.Name
+	}
+
+	if err = h.Email.SendHTML(
+		c.Request().Context(),
+		&email.BaseOpts{
+			ToAddresses: []string{inv.Email},
+			Language:    handlerutil.Language(c),
+			NoStack:     true,
+		},
+		"account-invitation",
+		map[string]any{
+			"accountName":  accountName,
+			"inviterEmail": handlerutil.AuthenticatedUser(c).Email,
+			"callbackURL":  link,
+			"validDays":    int(invitationValidity.Hours() / 24),
+			"year":         time.Now().Year(),
+		},
+	); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to send invitation email").SetInternal(err)
+	}
+
+	return nil
+}
+
+func (h *Handler) InviteAccountMember(c echo.Context) error {
+	var req struct {
+		Email       string `json:"email" validate:"required,email"`
+		Role        string `json:"role" validate:"required,oneof=admin member viewer"`
+		CallbackURL string `json:"callback_url" validate:"required,url"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	// Rejected before anything is checked or created.
+	if _, err := h.callbackURL(req.CallbackURL, nil); err != nil {
+		return err
+	}
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	req.Email = canonicalizeEmail(req.Email)
+	isMember, err := h.Repo.AccountHasMemberWithEmail(c.Request().Context(), repository.AccountHasMemberWithEmailParams{
+		AccountID: accountID,
+		Email:     req.Email,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check account membership").SetInternal(err)
+	}
+	if isMember {
+		return c.JSON(http.StatusConflict, APIErrorResponse{
+			Error: "user is already a member of the account",
+		})
+	}
+
+	token, tokenHash, err := newInvitationToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate invitation token").SetInternal(err)
+	}
+
+	var inv *repository.AccountInvitation
+	err = h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		// Concurrent invitations to the account wait for this one, so that they can't all pass the seat check.
+		if _, err := repo.LockAccount(c.Request().Context(), accountID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusNotFound, "account not found")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to lock account").SetInternal(err)
+		}
+
+		// Pending invitations hold a seat until they expire, so that accepting them can't exceed the plan.
+		members, err := repo.ListAccountMembers(c.Request().Context(), accountID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to list account members").SetInternal(err)
+		}
+		pending, err := repo.CountPendingAccountInvitations(c.Request().Context(), accountID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to count invitations").SetInternal(err)
+		}
+		seats := pending + 1
+		for _, m := range members {
+			if m.Status != "inactive" {
+				seats++
+			}
+		}
+		if !handlerutil.Entitlements(c).WithinLimit(config.LimitSeats, seats) {
+			return echo.NewHTTPError(http.StatusPaymentRequired, "plan seat limit reached")
+		}
+
+		inv, err = repo.CreateAccountInvitation(c.Request().Context(), repository.CreateAccountInvitationParams{
+			AccountID: accountID,
+			Email:     req.Email,
+			Role:      req.Role,
+			TokenHash: tokenHash,
+			InvitedBy: handlerutil.AuthenticatedUser(c).ID,
+			ExpiresAt: pgtype.Timestamptz{
+				Time:  time.Now().Add(invitationValidity),
+				Valid: true,
+			},
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				return echo.NewHTTPError(http.StatusConflict, "user already has a pending invitation")
+			}
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create invitation").SetInternal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Sent after the commit, so that the account isn't locked while the email is sent. An invitation whose email can't be sent is revoked, so that it doesn't hold a seat & retries don't conflict with it.
+	if err = h.sendInvitationEmail(c, inv, token, req.CallbackURL); err != nil {
+		if _, revokeErr := h.Repo.RevokeAccountInvitation(context.WithoutCancel(c.Request().Context()), repository.RevokeAccountInvitationParams{
+			ID:        inv.ID,
+			AccountID: accountID,
+		}); revokeErr != nil {
+			h.Logger.Error("failed to revoke invitation whose email wasn't sent", slog.String("error", revokeErr.Error()))
+		}
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, APISuccessResponse{
+		Data: newAccountInvitation(inv),
+	})
+}
+
+func (h *Handler) ListAccountInvitations(c echo.Context) error {
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	invitations, err := h.Repo.ListAccountInvitations(c.Request().Context(), accountID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list invitations").SetInternal(err)
+	}
+
+	out := make([]accountInvitation, 0, len(invitations))
+	for _, inv := range invitations {
+		out = append(out, newAccountInvitation(inv))
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
+
+// ResendAccountInvitation emails a new link & extends the expiry. Links in previous emails stop working.
+func (h *Handler) ResendAccountInvitation(c echo.Context) error {
+	var req struct {
+		ID          string `param:"id" validate:"required,uuid"`
+		CallbackURL string `json:"callback_url" validate:"required,url"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	invitationID, err := parseUUID(req.ID)
+	if err != nil {
+		return err
+	}
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	token, tokenHash, err := newInvitationToken()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate invitation token").SetInternal(err)
+	}
+
+	inv, err := h.Repo.RenewAccountInvitation(c.Request().Context(), repository.RenewAccountInvitationParams{
+		TokenHash: tokenHash,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  time.Now().Add(invitationValidity),
+			Valid: true,
+		},
+		ID:        invitationID,
+		AccountID: accountID,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, APIErrorResponse{
+				Error: "invitation not found",
+			})
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to renew invitation").SetInternal(err)
+	}
+
+	if err = h.sendInvitationEmail(c, inv, token, req.CallbackURL); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: newAccountInvitation(inv),
+	})
+}
+
+func (h *Handler) RevokeAccountInvitation(c echo.Context) error {
+	var req struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	invitationID, err := parseUUID(req.ID)
+	if err != nil {
+		return err
+	}
+	accountID := handlerutil.CurrentAccount(c).ID
+
+	n, err := h.Repo.RevokeAccountInvitation(c.Request().Context(), repository.RevokeAccountInvitationParams{
+		ID:        invitationID,
+		AccountID: accountID,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke invitation").SetInternal(err)
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, APIErrorResponse{
+			Error: "invitation not found",
+		})
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// respondToInvitation marks the pending invitation of the token as accepted or declined & returns it.
+func respondToInvitation(ctx context.Context, repo repository.Querier, token string, status string) (*repository.AccountInvitation, error) {
+	inv, err := repo.GetPendingAccountInvitationByTokenHash(ctx, util.HashToken(token))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "invitation not found or expired")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get invitation").SetInternal(err)
+	}
+
+	n, err := repo.RespondToAccountInvitation(ctx, repository.RespondToAccountInvitationParams{
+		Status: status,
+		ID:     inv.ID,
+	})
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to update invitation").SetInternal(err)
+	}
+	// Used concurrently, only the first response counts.
+	if n == 0 {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "invitation not found or expired")
+	}
+
+	return inv, nil
+}
+
+// AcceptAccountInvitation adds the invitee to the account, creating the user if needed. The membership stays pending until the user has verified their email.
+func (h *Handler) AcceptAccountInvitation(c echo.Context) error {
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	var membership *repository.UserAccount
+	err := h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		inv, err := respondToInvitation(c.Request().Context(), repo, req.Token, invitationAccepted)
+		if err != nil {
+			return err
+		}
+
+		user, err := repo.UpsertUser(c.Request().Context(), inv.Email)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to upsert user").SetInternal(err)
+		}
+
+		status := "pending"
+		if user.VerifiedAt.Valid {
+			status = "active"
+		}
+
+		membership, err = repo.UpsertUserAccount(c.Request().Context(), repository.UpsertUserAccountParams{
+			UserID:    user.ID,
+			AccountID: inv.AccountID,
+			Role:      inv.Role,
+			Status:    status,
+		})
+		if err != nil {
+			if !errors.Is(err, pgx.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to add account member").SetInternal(err)
+			}
+			// Already an active member, e.g. through another invitation.
+			membership, err = repo.GetUserAccount(c.Request().Context(), repository.GetUserAccountParams{
+				UserID:    user.ID,
+				AccountID: inv.AccountID,
+			})
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to get account membership").SetInternal(err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: echo.Map{
+			"account_id": membership.AccountID,
+			"role":       membership.Role,
+			"status":     membership.Status,
+		},
+	})
+}
+
+func (h *Handler) DeclineAccountInvitation(c echo.Context) error {
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	if _, err := respondToInvitation(c.Request().Context(), h.Repo, req.Token, invitationDeclined); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
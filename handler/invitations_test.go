@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"testing"
+
+	"github.com/rohitxdev/go-api/assets"
+	"github.com/rohitxdev/go-api/deps/email"
+)
+
+// failingTransport fails every send, like an unreachable mail server.
+type failingTransport struct{}
+
+func (failingTransport) Send(ctx context.Context, msg *email.Message) error {
+	return errors.New("connection refused")
+}
+
+func inviteURL(srvURL string, accountID string) string {
+	return srvURL + "/accounts/" + accountID + "/invitations"
+}
+
+func inviteBody(email string) map[string]string {
+	return map[string]string{"email": email, "role": "member", "callback_url": "https://localhost:8443/invitations"}
+}
+
+func TestInviteAccountMemberSeatLimit(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, h := newTestServer(t, cfg, repo)
+
+	owner, sessionID := repo.addSession("jane@example.com")
+	accountID := repo.addAccount(owner.ID).String()
+	client := newSignedInClient(t, srv, cfg, sessionID)
+
+	// The free plan has 3 seats, one of which is the owner's.
+	for _, invitee := range []string{"a@example.com", "b@example.com"} {
+		if status := doJSON(t, client, http.MethodPost, inviteURL(srv.URL, accountID), inviteBody(invitee), nil); status != http.StatusCreated {
+			t.Fatalf("invite %s: status = %d, want %d", invitee, status, http.StatusCreated)
+		}
+	}
+	if status := doJSON(t, client, http.MethodPost, inviteURL(srv.URL, accountID), inviteBody("c@example.com"), nil); status != http.StatusPaymentRequired {
+		t.Errorf("invite over the limit: status = %d, want %d", status, http.StatusPaymentRequired)
+	}
+
+	if n := len(repo.accountInvitations); n != 2 {
+		t.Errorf("created %d invitations, want 2", n)
+	}
+	if n := len(h.Email.Recent(10)); n != 2 {
+		t.Errorf("sent %d invitation emails, want 2", n)
+	}
+}
+
+func TestInviteAccountMemberRevokesUnsentInvitation(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, h := newTestServer(t, cfg, repo)
+
+	templatesFS, err := fs.Sub(assets.FS, "templates/emails")
+	if err != nil {
+		t.Fatalf("failed to get email templates: %v", err)
+	}
+	h.Email, err = email.New(failingTransport{}, &email.Sender{Address: "noreply@example.com", Name: "go-api"}, templatesFS)
+	if err != nil {
+		t.Fatalf("failed to create email client: %v", err)
+	}
+
+	owner, sessionID := repo.addSession("jane@example.com")
+	accountID := repo.addAccount(owner.ID).String()
+	client := newSignedInClient(t, srv, cfg, sessionID)
+
+	if status := doJSON(t, client, http.MethodPost, inviteURL(srv.URL, accountID), inviteBody("a@example.com"), nil); status != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", status, http.StatusInternalServerError)
+	}
+
+	// Doesn't hold a seat or conflict with a retry.
+	if n := len(repo.accountInvitations); n != 1 {
+		t.Fatalf("created %d invitations, want 1", n)
+	}
+	for _, inv := range repo.accountInvitations {
+		if inv.Status != "revoked" {
+			t.Errorf("invitation is %s, want revoked", inv.Status)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/deps/email"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/util"
+)
+
+const (
+	magicLinkValidity = time.Minute * 15
+	// Nonces of used links, kept until the links expire.
+	magicLinkUsedKey = "magic_links:used:"
+)
+
+// magicLinkClaims are signed with a key derived from the session secret, so that links can't be forged or pointed elsewhere. Only their use is stored.
+type magicLinkClaims struct {
+	UserID      pgtype.UUID `json:"user_id"`
+	RedirectURL string      `json:"redirect_url"`
+	Nonce       string      `json:"nonce"`
+	ExpiresAt   int64       `json:"expires_at"`
+}
+
+// magicLinkKey derives the signing key of magic links from the session secret, so that nothing else signed with the secret passes as a link.
+func (h *Handler) magicLinkKey() []byte {
+	return []byte(util.SignMessage([]byte("magic-link"), []byte(h.Config.Get().SessionSecret)))
+}
+
+func (h *Handler) signMagicLink(claims *magicLinkClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + util.SignMessage([]byte(encoded), h.magicLinkKey()), nil
+}
+
+// parseMagicLink returns the claims of a token signed by 'signMagicLink'. It doesn't check the expiry.
+func (h *Handler) parseMagicLink(token string) (*magicLinkClaims, bool) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !util.VerifyMessage([]byte(encoded), signature, h.magicLinkKey()) {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	var claims magicLinkClaims
+	if err = json.Unmarshal(payload, &claims); err != nil || !claims.UserID.Valid || claims.Nonce == "" {
+		return nil, false
+	}
+	return &claims, true
+}
+
+// SendMagicLink emails a link that, once confirmed, signs the user in & then redirects to the redirect URL.
+func (h *Handler) SendMagicLink(c echo.Context) error {
+	var req struct {
+		Email       string `json:"email" validate:"required,email"`
+		RedirectURL string `json:"redirect_url" validate:"required,url"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	// Rejected now rather than after the user has opened the link.
+	if _, err := h.callbackURL(req.RedirectURL, nil); err != nil {
+		return err
+	}
+
+	req.Email = canonicalizeEmail(req.Email)
+	wait, err := h.OTPGuard.StartCooldown(c.Request().Context(), "magic-link:"+req.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to start magic link cooldown").SetInternal(err)
+	}
+	if wait > 0 {
+		return retryLater(c, wait, "magic link was sent recently, try again later")
+	}
+
+	user, err := h.Repo.UpsertUser(c.Request().Context(), req.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to upsert user").SetInternal(err)
+	}
+
+	nonce, err := util.GenerateToken(16)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate magic link").SetInternal(err)
+	}
+	token, err := h.signMagicLink(&magicLinkClaims{
+		UserID:      user.ID,
+		RedirectURL: req.RedirectURL,
+		Nonce:       nonce,
+		ExpiresAt:   time.Now().Add(magicLinkValidity).Unix(),
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to sign magic link").SetInternal(err)
+	}
+	link := h.publicURL("/auth/magic-link/verify", url.Values{"token": {token}})
+
+	cfg := h.Config.Get()
+	data := echo.Map{}
+	if cfg.CanEchoOTP() {
+		data["link"] = link
+	}
+
+	if err = h.Email.SendHTML(
+		c.Request().Context(),
+		&email.BaseOpts{
+			ToAddresses: []string{user.Email},
+			Language:    handlerutil.Language(c),
+			NoStack:     true,
+		},
+		"verify-account",
+		map[string]any{
+			"callbackURL":  link,
+			"validMinutes": int(magicLinkValidity.Minutes()),
+			"year":         time.Now().Year(),
+		},
+	); err != nil {
+		// Echoed links let local development proceed without a working mail server.
+		if !cfg.CanEchoOTP() {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to send magic link email").SetInternal(err)
+		}
+		h.Logger.Warn("failed to send magic link email", slog.String("error", err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: data,
+	})
+}
+
+// redirectMagicLink redirects to the redirect URL of the link, with params added.
+func (h *Handler) redirectMagicLink(c echo.Context, claims *magicLinkClaims, params url.Values) error {
+	u, err := h.callbackURL(claims.RedirectURL, params)
+	if err != nil {
+		return err
+	}
+	return c.Redirect(http.StatusSeeOther, u)
+}
+
+// ConfirmMagicLink shows a page that signs the user in with a magic link once they confirm it. Opening the link doesn't use it, so that email scanners that follow links can't use it up. Once the link is known to be genuine, links that can't be used anymore are redirected with an 'error' query param.
+func (h *Handler) ConfirmMagicLink(c echo.Context) error {
+	var req struct {
+		Token string `query:"token" validate:"required"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	claims, ok := h.parseMagicLink(req.Token)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, APIErrorResponse{
+			Error: "invalid magic link",
+		})
+	}
+	if !time.Unix(claims.ExpiresAt, 0).After(time.Now()) {
+		return h.redirectMagicLink(c, claims, url.Values{"error": {"link_expired"}})
+	}
+
+	used, err := h.Redis.Exists(c.Request().Context(), magicLinkUsedKey+claims.Nonce).Result()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check magic link").SetInternal(err)
+	}
+	if used > 0 {
+		return h.redirectMagicLink(c, claims, url.Values{"error": {"link_used"}})
+	}
+
+	// The token only contains base64url characters & a dot, as its signature was verified.
+	return c.Render(http.StatusOK, "magic-link", echo.Map{
+		"appName": h.Config.Get().AppName,
+		"token":   req.Token,
+	})
+}
+
+// VerifyMagicLink signs the user in with a magic link confirmed on the page of 'ConfirmMagicLink' & redirects to the redirect URL it was sent with. Once the link is known to be genuine, failures are redirected too, with an 'error' query param.
+func (h *Handler) VerifyMagicLink(c echo.Context) error {
+	var req struct {
+		Token string `form:"token" validate:"required"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	claims, ok := h.parseMagicLink(req.Token)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, APIErrorResponse{
+			Error: "invalid magic link",
+		})
+	}
+
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if !expiresAt.After(time.Now()) {
+		return h.redirectMagicLink(c, claims, url.Values{"error": {"link_expired"}})
+	}
+
+	ok, err := h.Redis.SetNX(c.Request().Context(), magicLinkUsedKey+claims.Nonce, 1, time.Until(expiresAt)).Result()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to consume magic link").SetInternal(err)
+	}
+	if !ok {
+		return h.redirectMagicLink(c, claims, url.Values{"error": {"link_used"}})
+	}
+
+	totpRequired, err := h.authenticate(c, claims.UserID, true)
+	if err != nil {
+		return err
+	}
+	if totpRequired {
+		return h.redirectMagicLink(c, claims, url.Values{"totp_required": {"true"}})
+	}
+
+	return h.redirectMagicLink(c, claims, nil)
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rohitxdev/go-api/util"
+)
+
+func TestSendMagicLinkIgnoresHost(t *testing.T) {
+	cfg := testConfig(t)
+	srv, _ := newTestServer(t, cfg, newFakeRepo())
+
+	body, _ := json.Marshal(map[string]string{
+		"email":        "user@example.com",
+		"redirect_url": cfg.AllowedOrigins[0] + "/signed-in",
+	})
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/auth/magic-link/send", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Host = "attacker.example"
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	var resBody struct {
+		Data struct {
+			Link string `json:"link"`
+		} `json:"data"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&resBody); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if want := cfg.PublicURL + "/auth/magic-link/verify?token="; !strings.HasPrefix(resBody.Data.Link, want) {
+		t.Errorf("link = %q, want prefix %q", resBody.Data.Link, want)
+	}
+}
+
+// sendMagicLink sends a magic link to the email & returns the token of the echoed link.
+func sendMagicLink(t *testing.T, url string, email string, redirectURL string) string {
+	t.Helper()
+
+	var res struct {
+		Data struct {
+			Link string `json:"link"`
+		} `json:"data"`
+	}
+	if status := doJSON(t, newTestClient(t), http.MethodPost, url+"/auth/magic-link/send", map[string]string{"email": email, "redirect_url": redirectURL}, &res); status != http.StatusOK {
+		t.Fatalf("send: status = %d, want %d", status, http.StatusOK)
+	}
+	_, token, _ := strings.Cut(res.Data.Link, "?token=")
+	return token
+}
+
+func TestVerifyMagicLinkAfterConfirming(t *testing.T) {
+	cfg := testConfig(t)
+	srv, _ := newTestServer(t, cfg, newFakeRepo())
+	redirectURL := cfg.AllowedOrigins[0] + "/signed-in"
+	token := sendMagicLink(t, srv.URL, "jane@example.com", redirectURL)
+	client := newTestClient(t)
+
+	confirm := func() *http.Response {
+		t.Helper()
+
+		res, err := client.Get(srv.URL + "/auth/magic-link/verify?token=" + token)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	verify := func() *http.Response {
+		t.Helper()
+
+		res, err := client.PostForm(srv.URL+"/auth/magic-link/verify", url.Values{"token": {token}})
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		res.Body.Close()
+		return res
+	}
+
+	// Opening the link, e.g. by an email scanner, doesn't use it.
+	for i := range 2 {
+		res := confirm()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("confirm %d: status = %d, want %d", i+1, res.StatusCode, http.StatusOK)
+		}
+		page, _ := io.ReadAll(res.Body)
+		if !strings.Contains(string(page), `action="/auth/magic-link/verify"`) || !strings.Contains(string(page), token) {
+			t.Errorf("confirm %d: page doesn't post the token", i+1)
+		}
+	}
+	if status := doJSON(t, client, http.MethodGet, srv.URL+"/users/me", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("signed in by opening the link: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	if res := verify(); res.StatusCode != http.StatusSeeOther || res.Header.Get("Location") != redirectURL {
+		t.Fatalf("verify: got %d to %q, want %d to %q", res.StatusCode, res.Header.Get("Location"), http.StatusSeeOther, redirectURL)
+	}
+	if status := doJSON(t, client, http.MethodGet, srv.URL+"/users/me", nil, nil); status != http.StatusOK {
+		t.Errorf("after verify: status = %d, want %d", status, http.StatusOK)
+	}
+
+	wantUsed := redirectURL + "?error=link_used"
+	if res := verify(); res.Header.Get("Location") != wantUsed {
+		t.Errorf("verify again: redirected to %q, want %q", res.Header.Get("Location"), wantUsed)
+	}
+	if res := confirm(); res.Header.Get("Location") != wantUsed {
+		t.Errorf("confirm after use: redirected to %q, want %q", res.Header.Get("Location"), wantUsed)
+	}
+}
+
+func TestVerifyMagicLinkRejectsSessionSecretSignature(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+	user, _ := repo.addSession("jane@example.com")
+
+	payload, _ := json.Marshal(magicLinkClaims{
+		UserID:      user.ID,
+		RedirectURL: cfg.AllowedOrigins[0],
+		Nonce:       "nonce",
+		ExpiresAt:   time.Now().Add(time.Minute).Unix(),
+	})
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	// Signed with the secret itself, like other values signed with it.
+	token := encoded + "." + util.SignMessage([]byte(encoded), []byte(cfg.SessionSecret))
+
+	res, err := newTestClient(t).PostForm(srv.URL+"/auth/magic-link/verify", url.Values{"token": {token}})
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+const (
+	HeaderXAccountID = "X-Account-ID"
+	// AccountIDParam is the route param of routes that target a specific account, e.g. '/accounts/:account_id'.
+	AccountIDParam = "account_id"
+)
+
+// RequireAccount rejects requests that don't target an account the user is an active member of. It must come after 'RequireAuth'. Handlers behind it can use 'handlerutil.CurrentAccount' & 'handlerutil.CurrentMembership'.
+//
+// The account is taken from the route param, the 'X-Account-ID' header or the account selected in the session, in that order.
+func RequireAccount(repo repository.Querier) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := resolveAccount(c, repo); err != nil {
+				return err
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// selectedAccountID returns the ID of the account the request targets, if any.
+func selectedAccountID(c echo.Context) string {
+	if id := c.Param(AccountIDParam); id != "" {
+		return id
+	}
+	if id := c.Request().Header.Get(HeaderXAccountID); id != "" {
+		return id
+	}
+	if id, ok := handlerutil.SelectedAccountID(c); ok {
+		return id.String()
+	}
+	return ""
+}
+
+// resolveAccount loads the targeted account & the user's membership in it once per request. Staff can access accounts they aren't members of.
+func resolveAccount(c echo.Context, repo repository.Querier) error {
+	if handlerutil.CurrentAccount(c) != nil {
+		return nil
+	}
+
+	user := handlerutil.AuthenticatedUser(c)
+
+	rawID := selectedAccountID(c)
+	if rawID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "no account selected, set the "+HeaderXAccountID+" header")
+	}
+	id, err := uuid.Parse(rawID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "invalid account ID").SetInternal(err)
+	}
+	accountID := pgtype.UUID{Bytes: id, Valid: true}
+
+	membership, err := repo.GetUserAccount(c.Request().Context(), repository.GetUserAccountParams{
+		UserID:    user.ID,
+		AccountID: accountID,
+	})
+	if err != nil {
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get account membership").SetInternal(err)
+		}
+		if !handlerutil.IsStaff(user) {
+			// Same as a missing account, so that account IDs can't be probed.
+			return echo.NewHTTPError(http.StatusNotFound, "account not found")
+		}
+		membership = nil
+	}
+
+	account, err := repo.GetAccount(c.Request().Context(), accountID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusNotFound, "account not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get account").SetInternal(err)
+	}
+
+	c.Set("account", account)
+	if membership != nil {
+		c.Set("membership", membership)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/util"
+)
+
+// RequireAuth rejects requests without a valid session, or API key or access token in the 'Authorization: Bearer' header. Handlers behind it can use 'handlerutil.AuthenticatedUser', & 'handlerutil.CurrentScopes' for requests authenticated with a bearer token.
+func RequireAuth(repo repository.Querier, tracker handlerutil.SessionTracker, configStore *config.Store, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ok, err := authenticateBearer(c, repo, tracker, configStore.Get(), logger)
+			if err != nil {
+				return err
+			}
+			if !ok && handlerutil.CurrentUser(c, repo, tracker, logger) == nil {
+				return echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireSession rejects requests authenticated with a bearer token, for routes that manage the user's sign-in methods or rely on the session cookie. It must come after 'RequireAuth'.
+func RequireSession() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := handlerutil.CurrentScopes(c); ok {
+				return echo.NewHTTPError(http.StatusForbidden, "route requires a session")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects requests authenticated with a bearer token that lacks the scope, for routes that aren't behind 'RequirePermission'. It must come after 'RequireAuth'.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !handlerutil.HasScope(c, scope) {
+				return echo.NewHTTPError(http.StatusForbidden, "missing scope "+scope)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// authenticateBearer resolves the user of the API key or access token in the 'Authorization: Bearer' header. It returns false if the request has no such header, so that it can be authenticated with a session instead.
+func authenticateBearer(c echo.Context, repo repository.Querier, tracker handlerutil.SessionTracker, cfg *config.Config, logger *slog.Logger) (bool, error) {
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return false, nil
+	}
+	// Already resolved by an earlier middleware.
+	if _, ok = handlerutil.CurrentScopes(c); ok {
+		return true, nil
+	}
+
+	if prefix, secret, ok := handlerutil.ParseAPIKey(token); ok {
+		return true, authenticateAPIKey(c, repo, logger, prefix, secret)
+	}
+	return true, authenticateAccessToken(c, repo, tracker, logger, cfg, token)
+}
+
+func authenticateAPIKey(c echo.Context, repo repository.Querier, logger *slog.Logger, prefix string, secret string) error {
+	key, err := repo.GetApiKeyByPrefix(c.Request().Context(), prefix)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get API key").SetInternal(err)
+	}
+	if !util.VerifySecureHash([]byte(secret), key.KeyHash) {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid API key")
+	}
+
+	user, err := repo.GetUserByApiKeyId(c.Request().Context(), key.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user").SetInternal(err)
+	}
+
+	// Usage tracking is best-effort & must not fail the request.
+	if err = repo.TouchApiKey(c.Request().Context(), key.ID); err != nil {
+		logger.Warn("failed to track API key usage", slog.String("error", err.Error()))
+	}
+
+	c.Set("user", user)
+	c.Set("scopes", key.Scopes)
+
+	return nil
+}
+
+// authenticateAccessToken resolves the user of an access token issued by '/auth/token'. The token's session is checked too, so that signing out or revoking the session revokes its tokens.
+func authenticateAccessToken(c echo.Context, repo repository.Querier, tracker handlerutil.SessionTracker, logger *slog.Logger, cfg *config.Config, token string) error {
+	if cfg.JWTSigningKey == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid bearer token")
+	}
+	claims, err := handlerutil.VerifyAccessToken(cfg, token)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid access token").SetInternal(err)
+	}
+	id, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid access token").SetInternal(err)
+	}
+	sessionID := pgtype.UUID{Bytes: id, Valid: true}
+
+	user, err := repo.GetUserBySessionId(c.Request().Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return echo.NewHTTPError(http.StatusUnauthorized, "session has expired or been revoked")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get user").SetInternal(err)
+	}
+	if user.ID.String() != claims.Subject {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid access token")
+	}
+
+	if tracker != nil {
+		// Activity tracking is best-effort & must not fail the request.
+		if err = tracker.Touch(c.Request().Context(), sessionID); err != nil {
+			logger.Warn("failed to track session activity", slog.String("error", err.Error()))
+		}
+	}
+
+	c.Set("user", user)
+	c.Set("scopes", strings.Fields(claims.Scope))
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+// RequireSubscription rejects requests with 402 when the subscription of the targeted account, resolved like 'RequireAccount', has expired or its plan lacks any of the features. It must come after 'RequireAuth'. Handlers behind it can use 'handlerutil.Entitlements'.
+func RequireSubscription(repo repository.Querier, configStore *config.Store, features ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := resolveAccount(c, repo); err != nil {
+				return err
+			}
+
+			e, err := handlerutil.ResolveEntitlements(c, repo, configStore.Get())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve entitlements").SetInternal(err)
+			}
+
+			if e.Expired() {
+				return echo.NewHTTPError(http.StatusPaymentRequired, "subscription has expired")
+			}
+			for _, feature := range features {
+				if !e.HasFeature(feature) {
+					return echo.NewHTTPError(http.StatusPaymentRequired, "plan does not include "+feature)
+				}
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -34,3 +34,13 @@ func ResolveLanguage() echo.MiddlewareFunc {
 		}
 	}
 }
+
+// SupportedLanguages returns the base language codes that requests can resolve to, e.g. "en".
+func SupportedLanguages() []string {
+	out := make([]string, 0, len(supportedLanguages))
+	for _, tag := range supportedLanguages {
+		base, _ := tag.Base()
+		out = append(out, base.String())
+	}
+	return out
+}
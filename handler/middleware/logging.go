@@ -95,6 +95,10 @@ func LogRequest(logger *slog.Logger) echo.MiddlewareFunc {
 				attrs = append(attrs, slog.String("user_id", user.ID.String()))
 			}
 
+			if account, ok := c.Get("account").(*repository.Account); ok && (account != nil) {
+				attrs = append(attrs, slog.String("account_id", account.ID.String()))
+			}
+
 			logger.Info("HTTP Request", attrs...)
 
 			return nil
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+// RequirePermission rejects requests whose user doesn't have the permission in the targeted account, resolved like 'RequireAccount', or whose API key lacks it as a scope. It must come after 'RequireAuth'.
+func RequirePermission(repo repository.Querier, permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := resolveAccount(c, repo); err != nil {
+				return err
+			}
+
+			// API keys are limited to their scopes even for staff.
+			if !handlerutil.HasScope(c, permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "missing scope "+permission)
+			}
+			if handlerutil.IsStaff(handlerutil.AuthenticatedUser(c)) {
+				return next(c)
+			}
+			if !handlerutil.HasPermission(handlerutil.CurrentMembership(c).Role, permission) {
+				return echo.NewHTTPError(http.StatusForbidden, "missing permission "+permission)
+			}
+
+			return next(c)
+		}
+	}
+}
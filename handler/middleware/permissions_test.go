@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+// fakeAccounts serves the queries of 'resolveAccount' from memory. Other queries panic.
+type fakeAccounts struct {
+	repository.Querier
+	accounts    map[pgtype.UUID]*repository.Account
+	memberships map[[2]pgtype.UUID]*repository.UserAccount
+}
+
+func (f *fakeAccounts) GetAccount(_ context.Context, id pgtype.UUID) (*repository.Account, error) {
+	account, ok := f.accounts[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return account, nil
+}
+
+func (f *fakeAccounts) GetUserAccount(_ context.Context, arg repository.GetUserAccountParams) (*repository.UserAccount, error) {
+	membership, ok := f.memberships[[2]pgtype.UUID{arg.UserID, arg.AccountID}]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return membership, nil
+}
+
+func newUUID() pgtype.UUID {
+	return pgtype.UUID{Bytes: uuid.New(), Valid: true}
+}
+
+func TestRequirePermission(t *testing.T) {
+	accountID := newUUID()
+	member := &repository.User{ID: newUUID(), Role: handlerutil.UserRoleUser}
+	stranger := &repository.User{ID: newUUID(), Role: handlerutil.UserRoleUser}
+	staff := &repository.User{ID: newUUID(), Role: handlerutil.UserRoleStaff}
+
+	repo := &fakeAccounts{
+		accounts: map[pgtype.UUID]*repository.Account{
+			accountID: {ID: accountID},
+		},
+		memberships: map[[2]pgtype.UUID]*repository.UserAccount{
+			{member.ID, accountID}: {UserID: member.ID, AccountID: accountID, Role: handlerutil.RoleViewer, Status: "active"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		user       *repository.User
+		accountID  pgtype.UUID
+		scopes     []string
+		permission string
+		wantStatus int
+	}{
+		{"member with permission", member, accountID, nil, handlerutil.PermAccountsRead, http.StatusOK},
+		{"member without permission", member, accountID, nil, handlerutil.PermAccountsWrite, http.StatusForbidden},
+		{"non-member", stranger, accountID, nil, handlerutil.PermAccountsRead, http.StatusNotFound},
+		{"member of missing account", member, newUUID(), nil, handlerutil.PermAccountsRead, http.StatusNotFound},
+		{"staff non-member", staff, accountID, nil, handlerutil.PermAccountsDelete, http.StatusOK},
+		{"staff on missing account", staff, newUUID(), nil, handlerutil.PermAccountsRead, http.StatusNotFound},
+		{"member with scope", member, accountID, []string{handlerutil.PermAccountsRead}, handlerutil.PermAccountsRead, http.StatusOK},
+		{"staff without scope", staff, accountID, []string{handlerutil.PermAccountsRead}, handlerutil.PermAccountsDelete, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/accounts/"+tt.accountID.String(), nil)
+			c := e.NewContext(req, httptest.NewRecorder())
+			c.SetParamNames(AccountIDParam)
+			c.SetParamValues(tt.accountID.String())
+			c.Set("user", tt.user)
+			if tt.scopes != nil {
+				c.Set("scopes", tt.scopes)
+			}
+
+			err := RequirePermission(repo, tt.permission)(func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			status := c.Response().Status
+			if err != nil {
+				var httpErr *echo.HTTPError
+				if !errors.As(err, &httpErr) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				status = httpErr.Code
+			}
+			if status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/deps/ratelimit"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	// HeaderRateLimitReset is the number of seconds until the limit has fully replenished.
+	HeaderRateLimitReset = "RateLimit-Reset"
+)
+
+// RateLimiter counts requests against a limit, see 'ratelimit.Limiter'.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string, limit int, period time.Duration) (*ratelimit.Result, error)
+}
+
+// RateLimitKeyFunc returns the client a request is counted for.
+type RateLimitKeyFunc func(c echo.Context) string
+
+// RateLimitByIP counts requests per client IP.
+func RateLimitByIP(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// RateLimitByUser counts requests per authenticated user, & requests without one per client IP. It must come after 'RequireAuth'.
+func RateLimitByUser(c echo.Context) string {
+	if user, ok := c.Get("user").(*repository.User); ok && user != nil {
+		return "user:" + user.ID.String()
+	}
+	return RateLimitByIP(c)
+}
+
+// RateLimitByClientID counts requests per 'X-Client-ID' header, & requests without it per client IP.
+func RateLimitByClientID(c echo.Context) string {
+	if clientID := c.Request().Header.Get(HeaderXClientID); clientID != "" {
+		return "client:" + clientID
+	}
+	return RateLimitByIP(c)
+}
+
+type RateLimitOpts struct {
+	// Name separates the counters of different limits on the same client.
+	Name string
+	// Limit selects the limit from the config, so that it can be changed at runtime.
+	Limit func(cfg *config.Config) config.RateLimit
+	Key   RateLimitKeyFunc
+}
+
+// RateLimit rejects requests with 429 once the client has used up its limit, setting 'Retry-After'. Every response carries the 'RateLimit-*' headers of the limit.
+func RateLimit(limiter RateLimiter, configStore *config.Store, opts RateLimitOpts) echo.MiddlewareFunc {
+	if opts.Key == nil {
+		opts.Key = RateLimitByIP
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			limit := opts.Limit(configStore.Get())
+			if !limit.Enabled() {
+				return next(c)
+			}
+
+			res, err := limiter.Allow(c.Request().Context(), opts.Name+":"+opts.Key(c), limit.Requests, limit.Period)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to check rate limit").SetInternal(err)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+			header.Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+			header.Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(res.ResetAfter)))
+
+			if !res.Allowed {
+				header.Set(echo.HeaderRetryAfter, strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "too many requests, try again later")
+			}
+
+			return next(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
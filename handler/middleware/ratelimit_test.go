@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/deps/ratelimit"
+)
+
+func TestRateLimitHeaders(t *testing.T) {
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Now())
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	limiter := ratelimit.New(rdb, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	}, RateLimit(limiter, new(config.Store), RateLimitOpts{
+		Name: "test",
+		// The config isn't read, so the store can be empty.
+		Limit: func(cfg *config.Config) config.RateLimit { return config.RateLimit{Requests: 2, Period: time.Minute} },
+	}))
+
+	tests := []struct {
+		name          string
+		wantStatus    int
+		wantRemaining string
+		wantReset     string
+		// Empty if the header must not be set.
+		wantRetryAfter string
+	}{
+		{name: "first", wantStatus: http.StatusOK, wantRemaining: "1", wantReset: "30"},
+		{name: "last", wantStatus: http.StatusOK, wantRemaining: "0", wantReset: "60"},
+		{name: "limited", wantStatus: http.StatusTooManyRequests, wantRemaining: "0", wantReset: "60", wantRetryAfter: "30"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		header := rec.Header()
+		if got := header.Get(HeaderRateLimitLimit); got != "2" {
+			t.Errorf("%s: %s = %q, want %q", tt.name, HeaderRateLimitLimit, got, "2")
+		}
+		if got := header.Get(HeaderRateLimitRemaining); got != tt.wantRemaining {
+			t.Errorf("%s: %s = %q, want %q", tt.name, HeaderRateLimitRemaining, got, tt.wantRemaining)
+		}
+		if got := header.Get(HeaderRateLimitReset); got != tt.wantReset {
+			t.Errorf("%s: %s = %q, want %q", tt.name, HeaderRateLimitReset, got, tt.wantReset)
+		}
+		if got := header.Get(echo.HeaderRetryAfter); got != tt.wantRetryAfter {
+			t.Errorf("%s: %s = %q, want %q", tt.name, echo.HeaderRetryAfter, got, tt.wantRetryAfter)
+		}
+	}
+}
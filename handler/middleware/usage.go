@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/deps/usage"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+const (
+	HeaderXQuotaLimit     = "X-Quota-Limit"
+	HeaderXQuotaRemaining = "X-Quota-Remaining"
+	// HeaderXQuotaReset is the unix timestamp of when the quota resets.
+	HeaderXQuotaReset = "X-Quota-Reset"
+)
+
+// MeterRequests counts requests towards the API request quota of the targeted account, resolved like 'RequireAccount'. Requests over the plan's limit are rejected with 429 until the quota resets. Requests that can't be counted are let through & logged with logger, so that a redis outage doesn't take the API down. It must come after 'RequireAuth'. Handlers behind it can use 'handlerutil.Entitlements'.
+func MeterRequests(repo repository.Querier, configStore *config.Store, meter handlerutil.UsageMeter, logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := resolveAccount(c, repo); err != nil {
+				return err
+			}
+
+			e, err := handlerutil.ResolveEntitlements(c, repo, configStore.Get())
+			if err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to resolve entitlements").SetInternal(err)
+			}
+
+			accountID := handlerutil.CurrentAccount(c).ID
+			limit := e.Limit(config.LimitAPIRequests)
+
+			// Counted before checking the limit, so that concurrent requests can't all pass a check made before any of them were counted.
+			used, err := meter.Add(c.Request().Context(), accountID, usage.MetricAPIRequests, 1)
+			if err != nil {
+				logger.Error("failed to record usage", slog.String("account_id", accountID.String()), slog.String("error", err.Error()))
+				return next(c)
+			}
+			if limit == config.Unlimited {
+				return next(c)
+			}
+
+			_, resetAt := usage.Period(usage.MetricAPIRequests, time.Now())
+			header := c.Response().Header()
+			header.Set(HeaderXQuotaLimit, strconv.FormatInt(limit, 10))
+			header.Set(HeaderXQuotaReset, strconv.FormatInt(resetAt.Unix(), 10))
+
+			if used > limit {
+				// Rejected requests don't count.
+				if _, err = meter.Add(c.Request().Context(), accountID, usage.MetricAPIRequests, -1); err != nil {
+					logger.Error("failed to roll back usage", slog.String("account_id", accountID.String()), slog.String("error", err.Error()))
+				}
+				header.Set(HeaderXQuotaRemaining, "0")
+				header.Set(echo.HeaderRetryAfter, strconv.Itoa(int(time.Until(resetAt).Seconds())+1))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "plan API request limit reached")
+			}
+			header.Set(HeaderXQuotaRemaining, strconv.FormatInt(limit-used, 10))
+
+			return next(c)
+		}
+	}
+}
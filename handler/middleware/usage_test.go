@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+// fakeMeter counts usage in memory, atomically like the redis counters of 'usage.Meter'. Adding fails with err while it is set.
+type fakeMeter struct {
+	mu    sync.Mutex
+	usage map[string]int64
+	err   error
+}
+
+func (m *fakeMeter) Usage(_ context.Context, accountID pgtype.UUID, metric string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage[accountID.String()+":"+metric], nil
+}
+
+func (m *fakeMeter) Add(_ context.Context, accountID pgtype.UUID, metric string, n int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return 0, m.err
+	}
+	m.usage[accountID.String()+":"+metric] += n
+	return m.usage[accountID.String()+":"+metric], nil
+}
+
+func TestMeterRequestsConcurrently(t *testing.T) {
+	const (
+		limit    = 5
+		requests = 50
+	)
+	account := &repository.Account{ID: newUUID()}
+	entitlements := &handlerutil.AccountEntitlements{
+		PlanID: "test",
+		Plan:   &config.Plan{Name: "Test", Limits: map[string]int64{config.LimitAPIRequests: limit}},
+	}
+	meter := &fakeMeter{usage: map[string]int64{}}
+	// Entitlements are resolved up front, so the middleware doesn't read the repo or the config.
+	mw := MeterRequests(nil, new(config.Store), meter, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		passed int
+	)
+	for range requests {
+		wg.Go(func() {
+			c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+			c.Set("account", account)
+			c.Set("entitlements", entitlements)
+
+			err := mw(func(c echo.Context) error {
+				mu.Lock()
+				passed++
+				mu.Unlock()
+				return nil
+			})(c)
+			if httpErr, ok := err.(*echo.HTTPError); err != nil && (!ok || httpErr.Code != http.StatusTooManyRequests) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if passed != limit {
+		t.Errorf("%d requests passed, want %d", passed, limit)
+	}
+	// Rejected requests are rolled back.
+	if used, _ := meter.Usage(context.Background(), account.ID, config.LimitAPIRequests); used != limit {
+		t.Errorf("usage = %d, want %d", used, limit)
+	}
+}
+
+func TestMeterRequestsFailsOpen(t *testing.T) {
+	entitlements := &handlerutil.AccountEntitlements{
+		PlanID: "test",
+		Plan:   &config.Plan{Name: "Test", Limits: map[string]int64{config.LimitAPIRequests: 1}},
+	}
+	meter := &fakeMeter{usage: map[string]int64{}, err: errors.New("redis is down")}
+	mw := MeterRequests(nil, new(config.Store), meter, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for range 2 {
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+		c.Set("account", &repository.Account{ID: newUUID()})
+		c.Set("entitlements", entitlements)
+
+		passed := false
+		if err := mw(func(c echo.Context) error {
+			passed = true
+			return nil
+		})(c); err != nil || !passed {
+			t.Errorf("request wasn't let through without usage metering (err: %v)", err)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gorilla/sessions"
+	"github.com/jackc/pgx/v5"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/util"
+	"golang.org/x/oauth2"
+)
+
+// The user has this long to sign in at the provider.
+const oidcFlowValidity = time.Minute * 10
+
+// oidcFlow is a sign-in in progress, kept in the session cookie between the redirect to the provider & the callback.
+type oidcFlow struct {
+	provider    string
+	state       string
+	nonce       string
+	verifier    string
+	redirectURL string
+	expiresAt   int64
+}
+
+// takeOIDCFlow removes the sign-in in progress from the session & returns it, or nil if there is none. The removal takes effect once the session is saved, so that the callback can't be replayed.
+func takeOIDCFlow(sess *sessions.Session) *oidcFlow {
+	flow := &oidcFlow{}
+	flow.provider, _ = sess.Values["oidcProvider"].(string)
+	flow.state, _ = sess.Values["oidcState"].(string)
+	flow.nonce, _ = sess.Values["oidcNonce"].(string)
+	flow.verifier, _ = sess.Values["oidcVerifier"].(string)
+	flow.redirectURL, _ = sess.Values["oidcRedirectURL"].(string)
+	flow.expiresAt, _ = sess.Values["oidcExpiresAt"].(int64)
+	if flow.state == "" {
+		return nil
+	}
+
+	for _, key := range []string{"oidcProvider", "oidcState", "oidcNonce", "oidcVerifier", "oidcRedirectURL", "oidcExpiresAt"} {
+		delete(sess.Values, key)
+	}
+	return flow
+}
+
+// oidcClient returns the provider with the ID from the config along with its OAuth2 client, or 404 if it isn't configured.
+func (h *Handler) oidcClient(c echo.Context, providerID string) (*oidc.Provider, *oauth2.Config, error) {
+	cfg, ok := h.Config.Get().OIDCProviders[providerID]
+	if !ok || cfg == nil {
+		return nil, nil, echo.NewHTTPError(http.StatusNotFound, "unknown identity provider")
+	}
+
+	provider, err := h.OIDCProviders.Get(c.Request().Context(), cfg.IssuerURL)
+	if err != nil {
+		return nil, nil, echo.NewHTTPError(http.StatusBadGateway, "failed to reach identity provider").SetInternal(err)
+	}
+
+	return provider, &oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		// Must be registered with the provider.
+		RedirectURL: h.publicURL("/auth/oidc/"+url.PathEscape(providerID)+"/callback", nil),
+		Scopes:      append([]string{oidc.ScopeOpenID, "email", "profile"}, cfg.Scopes...),
+	}, nil
+}
+
+// ListOIDCProviders returns the identity providers that users can sign in with.
+func (h *Handler) ListOIDCProviders(c echo.Context) error {
+	type oidcProvider struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	providers := h.Config.Get().OIDCProviders
+	data := make([]oidcProvider, 0, len(providers))
+	for id, p := range providers {
+		data = append(data, oidcProvider{ID: id, Name: p.Name})
+	}
+	slices.SortFunc(data, func(a, b oidcProvider) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: data,
+	})
+}
+
+// StartOIDCSignIn redirects to the sign-in page of the provider, using the authorization code flow with PKCE. The provider redirects back to 'OIDCCallback'.
+func (h *Handler) StartOIDCSignIn(c echo.Context) error {
+	var req struct {
+		Provider    string `param:"provider" validate:"required"`
+		RedirectURL string `query:"redirect_url" validate:"required,url"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	// Rejected now rather than after the user has signed in at the provider.
+	if _, err := h.callbackURL(req.RedirectURL, nil); err != nil {
+		return err
+	}
+
+	_, client, err := h.oidcClient(c, req.Provider)
+	if err != nil {
+		return err
+	}
+
+	state, err := util.GenerateToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate state").SetInternal(err)
+	}
+	nonce, err := util.GenerateToken(32)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate nonce").SetInternal(err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get session").SetInternal(err)
+	}
+	sess.Values["oidcProvider"] = req.Provider
+	sess.Values["oidcState"] = state
+	sess.Values["oidcNonce"] = nonce
+	sess.Values["oidcVerifier"] = verifier
+	sess.Values["oidcRedirectURL"] = req.RedirectURL
+	sess.Values["oidcExpiresAt"] = time.Now().Add(oidcFlowValidity).Unix()
+	if err = sess.Save(c.Request(), c.Response()); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save session").SetInternal(err)
+	}
+
+	return c.Redirect(http.StatusSeeOther, client.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)))
+}
+
+// OIDCCallback signs the user in with the authorization code from the provider & redirects to the redirect URL the sign-in was started with. Users are linked by the provider's subject, or by their email on first sign-in if the provider has verified it. Once the state is known to be genuine, failures are redirected too, with an 'error' query param.
+func (h *Handler) OIDCCallback(c echo.Context) error {
+	var req struct {
+		Provider string `param:"provider" validate:"required"`
+		State    string `query:"state" validate:"required"`
+		Code     string `query:"code"`
+		Error    string `query:"error"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get session").SetInternal(err)
+	}
+	flow := takeOIDCFlow(sess)
+	if flow == nil || flow.provider != req.Provider || subtle.ConstantTimeCompare([]byte(flow.state), []byte(req.State)) != 1 {
+		return c.JSON(http.StatusBadRequest, APIErrorResponse{
+			Error: "sign-in not found or expired",
+		})
+	}
+
+	redirect := func(params url.Values) error {
+		u, err := h.callbackURL(flow.redirectURL, params)
+		if err != nil {
+			return err
+		}
+		return c.Redirect(http.StatusSeeOther, u)
+	}
+	// Successful sign-ins save the session along with the new session ID instead.
+	fail := func(reason string) error {
+		if err := sess.Save(c.Request(), c.Response()); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to save session").SetInternal(err)
+		}
+		return redirect(url.Values{"error": {reason}})
+	}
+
+	if time.Now().Unix() >= flow.expiresAt {
+		return fail("sign_in_expired")
+	}
+	// e.g. the user declined to sign in.
+	if req.Error != "" || req.Code == "" {
+		return fail("sign_in_cancelled")
+	}
+
+	provider, client, err := h.oidcClient(c, req.Provider)
+	if err != nil {
+		return err
+	}
+
+	ctx := c.Request().Context()
+	token, err := client.Exchange(ctx, req.Code, oauth2.VerifierOption(flow.verifier))
+	if err != nil {
+		h.Logger.Warn("failed to exchange OIDC authorization code", slog.String("provider", req.Provider), slog.String("error", err.Error()))
+		return fail("sign_in_failed")
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		h.Logger.Warn("OIDC token response has no ID token", slog.String("provider", req.Provider))
+		return fail("sign_in_failed")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: client.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil || subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(flow.nonce)) != 1 {
+		if err == nil {
+			err = errors.New("nonce mismatch")
+		}
+		h.Logger.Warn("failed to verify OIDC ID token", slog.String("provider", req.Provider), slog.String("error", err.Error()))
+		return fail("sign_in_failed")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}
+	if err = idToken.Claims(&claims); err != nil {
+		h.Logger.Warn("failed to parse OIDC ID token claims", slog.String("provider", req.Provider), slog.String("error", err.Error()))
+		return fail("sign_in_failed")
+	}
+
+	identity, err := h.Repo.GetUserIdentity(ctx, repository.GetUserIdentityParams{
+		Provider: req.Provider,
+		Subject:  idToken.Subject,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Linking by an unverified email would let anyone who controls the provider account take over the user with that email.
+		if !claims.EmailVerified || util.Validate.Var(claims.Email, "email") != nil {
+			return fail("email_not_verified")
+		}
+
+		err = h.inTx(ctx, func(repo repository.Querier) error {
+			user, err := repo.UpsertUser(ctx, canonicalizeEmail(claims.Email))
+			if err != nil {
+				return err
+			}
+			identity, err = repo.UpsertUserIdentity(ctx, repository.UpsertUserIdentityParams{
+				UserID:   user.ID,
+				Provider: req.Provider,
+				Subject:  idToken.Subject,
+				Email:    &claims.Email,
+			})
+			return err
+		})
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to link identity").SetInternal(err)
+		}
+	} else if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get identity").SetInternal(err)
+	}
+
+	totpRequired, err := h.authenticate(c, identity.UserID, false)
+	if err != nil {
+		return err
+	}
+	if totpRequired {
+		return redirect(url.Values{"totp_required": {"true"}})
+	}
+
+	return redirect(nil)
+}
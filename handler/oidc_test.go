@@ -0,0 +1,376 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+)
+
+const (
+	mockOIDCProvider     = "mock"
+	mockOIDCClientID     = "client"
+	mockOIDCClientSecret = "secret"
+)
+
+// mockIssuer is an OpenID Connect provider serving discovery, its signing keys & the token endpoint. Users "sign in" with 'authorize', which issues a code for the claims.
+type mockIssuer struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+type mockAuthorization struct {
+	claims        jwt.MapClaims
+	codeChallenge string
+	redirectURI   string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate signing key: %v", err)
+	}
+	m := &mockIssuer{
+		key:   key,
+		codes: map[string]mockAuthorization{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"issuer":                                m.srv.URL,
+			"authorization_endpoint":                m.srv.URL + "/authorize",
+			"token_endpoint":                        m.srv.URL + "/token",
+			"jwks_uri":                              m.srv.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   b64(key.N.Bytes()),
+				"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", m.token)
+
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// authorize signs the user with the claims in, answering the authorization URL from 'StartOIDCSignIn', & returns the query of the callback. The nonce of the URL is added to the claims unless they have one.
+func (m *mockIssuer) authorize(t *testing.T, authURL *url.URL, claims jwt.MapClaims) url.Values {
+	t.Helper()
+
+	query := authURL.Query()
+	if query.Get("client_id") != mockOIDCClientID || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+
+	code := rand.Text()
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{
+		claims:        claims,
+		codeChallenge: query.Get("code_challenge"),
+		redirectURI:   query.Get("redirect_uri"),
+	}
+	m.mu.Unlock()
+
+	return url.Values{"code": {code}, "state": {query.Get("state")}}
+}
+
+// token exchanges a code for an ID token, checking the PKCE verifier & the client like a provider would.
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != mockOIDCClientID || clientSecret != mockOIDCClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	auth, ok := m.codes[r.PostForm.Get("code")]
+	// Codes are single-use.
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("redirect_uri") != auth.redirectURI || base64.RawURLEncoding.EncodeToString(challenge[:]) != auth.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss": m.srv.URL,
+		"aud": mockOIDCClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Minute * 5).Unix(),
+	}
+	for k, v := range auth.claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	idToken, err := token.SignedString(m.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+type oidcTest struct {
+	cfg         *config.Config
+	repo        *fakeRepo
+	srv         *httptest.Server
+	issuer      *mockIssuer
+	redirectURL string
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+
+	issuer := newMockIssuer(t)
+	cfg := testConfig(t)
+	cfg.OIDCProviders = config.OIDCProviders{
+		mockOIDCProvider: {
+			Name:         "Mock",
+			IssuerURL:    issuer.srv.URL,
+			ClientID:     mockOIDCClientID,
+			ClientSecret: mockOIDCClientSecret,
+		},
+	}
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	return &oidcTest{
+		cfg:         cfg,
+		repo:        repo,
+		srv:         srv,
+		issuer:      issuer,
+		redirectURL: cfg.AllowedOrigins[0] + "/signed-in",
+	}
+}
+
+// get sends a GET request & returns the response, whose body is already closed.
+func get(t *testing.T, client *http.Client, rawURL string) *http.Response {
+	t.Helper()
+
+	res, err := client.Get(rawURL)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	res.Body.Close()
+	return res
+}
+
+// start starts a sign-in with the client & returns the authorization URL it redirects to.
+func (ot *oidcTest) start(t *testing.T, client *http.Client) *url.URL {
+	t.Helper()
+
+	res := get(t, client, ot.srv.URL+"/auth/oidc/"+mockOIDCProvider+"/start?"+url.Values{"redirect_url": {ot.redirectURL}}.Encode())
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("start: status = %d, want %d", res.StatusCode, http.StatusSeeOther)
+	}
+	authURL, err := url.Parse(res.Header.Get(echo.HeaderLocation))
+	if err != nil {
+		t.Fatalf("failed to parse authorization URL: %v", err)
+	}
+	return authURL
+}
+
+// callback sends the query to the callback & returns the 'error' param of the redirect, which is empty for successful sign-ins.
+func (ot *oidcTest) callback(t *testing.T, client *http.Client, query url.Values) string {
+	t.Helper()
+
+	res := get(t, client, ot.srv.URL+"/auth/oidc/"+mockOIDCProvider+"/callback?"+query.Encode())
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("callback: status = %d, want %d", res.StatusCode, http.StatusSeeOther)
+	}
+	location, err := url.Parse(res.Header.Get(echo.HeaderLocation))
+	if err != nil || !strings.HasPrefix(location.String(), ot.redirectURL) {
+		t.Fatalf("callback redirected to %q, want %q", res.Header.Get(echo.HeaderLocation), ot.redirectURL)
+	}
+	return location.Query().Get("error")
+}
+
+// signIn signs in with the claims & returns the client & the 'error' param of the redirect.
+func (ot *oidcTest) signIn(t *testing.T, claims jwt.MapClaims) (*http.Client, string) {
+	t.Helper()
+
+	client := newTestClient(t)
+	return client, ot.callback(t, client, ot.issuer.authorize(t, ot.start(t, client), claims))
+}
+
+// me returns the user the client is signed in as, or nil.
+func (ot *oidcTest) me(t *testing.T, client *http.Client) *repository.User {
+	t.Helper()
+
+	var res struct {
+		Data *repository.User `json:"data"`
+	}
+	if status := doJSON(t, client, http.MethodGet, ot.srv.URL+"/users/me", nil, &res); status != http.StatusOK {
+		return nil
+	}
+	return res.Data
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	ot := newOIDCTest(t)
+	existing, _ := ot.repo.UpsertUser(t.Context(), "user@example.com")
+
+	// Linked by the verified email on first sign-in.
+	client, errParam := ot.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "User@Example.com", "email_verified": true})
+	if errParam != "" {
+		t.Fatalf("first sign-in failed: %s", errParam)
+	}
+	if user := ot.me(t, client); user == nil || user.ID != existing.ID {
+		t.Fatalf("signed in as %+v, want %s", user, existing.ID)
+	}
+
+	// Resolved by the identity afterwards, even if the email changed & isn't verified anymore.
+	client, errParam = ot.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "renamed@example.com", "email_verified": false})
+	if errParam != "" {
+		t.Fatalf("repeat sign-in failed: %s", errParam)
+	}
+	if user := ot.me(t, client); user == nil || user.ID != existing.ID {
+		t.Errorf("signed in as %+v, want %s", user, existing.ID)
+	}
+	if len(ot.repo.users) != 1 || len(ot.repo.userIdentities) != 1 {
+		t.Errorf("%d users & %d identities, want 1 of each", len(ot.repo.users), len(ot.repo.userIdentities))
+	}
+}
+
+func TestOIDCUnverifiedEmailIsNotLinked(t *testing.T) {
+	ot := newOIDCTest(t)
+	ot.repo.UpsertUser(t.Context(), "victim@example.com")
+
+	client, errParam := ot.signIn(t, jwt.MapClaims{"sub": "attacker", "email": "victim@example.com", "email_verified": false})
+	if errParam != "email_not_verified" {
+		t.Errorf("error = %q, want %q", errParam, "email_not_verified")
+	}
+	if user := ot.me(t, client); user != nil {
+		t.Errorf("signed in as %+v", user)
+	}
+	if len(ot.repo.userIdentities) != 0 {
+		t.Error("identity was linked")
+	}
+}
+
+func TestOIDCStateMismatch(t *testing.T) {
+	ot := newOIDCTest(t)
+	client := newTestClient(t)
+	query := ot.issuer.authorize(t, ot.start(t, client), jwt.MapClaims{"sub": "subject-1", "email": "user@example.com", "email_verified": true})
+	query.Set("state", "forged")
+
+	res := get(t, client, ot.srv.URL+"/auth/oidc/"+mockOIDCProvider+"/callback?"+query.Encode())
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", res.StatusCode, http.StatusBadRequest)
+	}
+	if user := ot.me(t, client); user != nil {
+		t.Errorf("signed in as %+v", user)
+	}
+}
+
+func TestOIDCNonceMismatch(t *testing.T) {
+	ot := newOIDCTest(t)
+
+	// e.g. an ID token issued for another sign-in.
+	client, errParam := ot.signIn(t, jwt.MapClaims{"sub": "subject-1", "email": "user@example.com", "email_verified": true, "nonce": "other"})
+	if errParam != "sign_in_failed" {
+		t.Errorf("error = %q, want %q", errParam, "sign_in_failed")
+	}
+	if user := ot.me(t, client); user != nil {
+		t.Errorf("signed in as %+v", user)
+	}
+}
+
+func TestOIDCPKCEVerifier(t *testing.T) {
+	ot := newOIDCTest(t)
+
+	// A code bound to the challenge of another sign-in, whose verifier this one doesn't have.
+	victimURL := ot.start(t, newTestClient(t))
+
+	attacker := newTestClient(t)
+	authURL := ot.start(t, attacker)
+	query := authURL.Query()
+	query.Set("code_challenge", victimURL.Query().Get("code_challenge"))
+	authURL.RawQuery = query.Encode()
+	attackerQuery := ot.issuer.authorize(t, authURL, jwt.MapClaims{"sub": "attacker", "email": "attacker@example.com", "email_verified": true})
+
+	if errParam := ot.callback(t, attacker, attackerQuery); errParam != "sign_in_failed" {
+		t.Errorf("error = %q, want %q", errParam, "sign_in_failed")
+	}
+	if user := ot.me(t, attacker); user != nil {
+		t.Errorf("signed in as %+v", user)
+	}
+}
+
+func TestOIDCRedirectURLIgnoresHost(t *testing.T) {
+	ot := newOIDCTest(t)
+
+	req, err := http.NewRequest(http.MethodGet, ot.srv.URL+"/auth/oidc/"+mockOIDCProvider+"/start?"+url.Values{"redirect_url": {ot.redirectURL}}.Encode(), nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Host = "attacker.example"
+	res, err := newTestClient(t).Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	res.Body.Close()
+
+	authURL, err := url.Parse(res.Header.Get(echo.HeaderLocation))
+	if err != nil {
+		t.Fatalf("failed to parse authorization URL: %v", err)
+	}
+	if got, want := authURL.Query().Get("redirect_uri"), ot.cfg.PublicURL+"/auth/oidc/"+mockOIDCProvider+"/callback"; got != want {
+		t.Errorf("redirect_uri = %q, want %q", got, want)
+	}
+}
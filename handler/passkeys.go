@@ -0,0 +1,382 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/util"
+)
+
+const (
+	// Challenges of ceremonies in progress, keyed by user ID for registrations & by a random ID kept in the session cookie for sign-ins.
+	passkeyRegistrationKey = "passkeys:registration:"
+	passkeyLoginKey        = "passkeys:login:"
+	passkeyCeremonyTTL     = time.Minute * 5
+)
+
+type passkey struct {
+	ID         pgtype.UUID `json:"id"`
+	Name       string      `json:"name"`
+	LastUsedAt *time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+func newPasskey(cred *repository.WebauthnCredential) passkey {
+	var lastUsedAt *time.Time
+	if cred.LastUsedAt.Valid {
+		lastUsedAt = &cred.LastUsedAt.Time
+	}
+	return passkey{
+		ID:         cred.ID,
+		Name:       cred.Name,
+		LastUsedAt: lastUsedAt,
+		CreatedAt:  cred.CreatedAt.Time,
+	}
+}
+
+// passkeyUser is a user as seen by the WebAuthn library. The user handle stored on authenticators is the user ID.
+type passkeyUser struct {
+	id          pgtype.UUID
+	email       string
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return u.id.Bytes[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.email
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// loadPasskeyUser returns the user with their passkeys.
+func loadPasskeyUser(ctx context.Context, repo repository.Querier, userID pgtype.UUID, email string) (*passkeyUser, error) {
+	creds, err := repo.ListWebauthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &passkeyUser{
+		id:          userID,
+		email:       email,
+		credentials: make([]webauthn.Credential, 0, len(creds)),
+	}
+	for _, cred := range creds {
+		var c webauthn.Credential
+		if err = json.Unmarshal(cred.Credential, &c); err != nil {
+			return nil, err
+		}
+		u.credentials = append(u.credentials, c)
+	}
+	return u, nil
+}
+
+// webAuthn returns the relying party of the config, or 503 if passkeys aren't configured.
+func (h *Handler) webAuthn() (*webauthn.WebAuthn, error) {
+	cfg := h.Config.Get()
+	if cfg.WebAuthnRPID == "" {
+		return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "passkeys are not configured")
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthnRPID,
+		RPDisplayName: cfg.AppName,
+		RPOrigins:     cfg.AllowedOrigins,
+	})
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to configure passkeys").SetInternal(err)
+	}
+	return w, nil
+}
+
+// saveCeremony stores the challenge of a ceremony until it is finished.
+func (h *Handler) saveCeremony(ctx context.Context, key string, data *webauthn.SessionData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to marshal passkey challenge").SetInternal(err)
+	}
+	if err = h.Redis.Set(ctx, key, b, passkeyCeremonyTTL).Err(); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save passkey challenge").SetInternal(err)
+	}
+	return nil
+}
+
+// takeCeremony returns & removes the challenge of a ceremony, so that it can only be answered once.
+func (h *Handler) takeCeremony(ctx context.Context, key string) (*webauthn.SessionData, error) {
+	b, err := h.Redis.GetDel(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "passkey challenge not found or expired")
+		}
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get passkey challenge").SetInternal(err)
+	}
+
+	var data webauthn.SessionData
+	if err = json.Unmarshal(b, &data); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to unmarshal passkey challenge").SetInternal(err)
+	}
+	return &data, nil
+}
+
+// BeginPasskeyRegistration returns the options for 'navigator.credentials.create()' to add a passkey to the user.
+func (h *Handler) BeginPasskeyRegistration(c echo.Context) error {
+	w, err := h.webAuthn()
+	if err != nil {
+		return err
+	}
+	user := handlerutil.AuthenticatedUser(c)
+
+	u, err := loadPasskeyUser(c.Request().Context(), h.Repo, user.ID, user.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load passkeys").SetInternal(err)
+	}
+
+	creation, data, err := w.BeginRegistration(u,
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+		webauthn.WithExclusions(webauthn.Credentials(u.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin passkey registration").SetInternal(err)
+	}
+
+	if err = h.saveCeremony(c.Request().Context(), passkeyRegistrationKey+user.ID.String(), data); err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: creation,
+	})
+}
+
+// FinishPasskeyRegistration verifies the response of the authenticator, sent as the request body, & stores the passkey. It is labelled with the 'name' query param.
+func (h *Handler) FinishPasskeyRegistration(c echo.Context) error {
+	w, err := h.webAuthn()
+	if err != nil {
+		return err
+	}
+	var req struct {
+		Name string `query:"name" validate:"required,max=64"`
+	}
+	// The body is the authenticator's response, which is parsed by the WebAuthn library.
+	if err = (&echo.DefaultBinder{}).BindQueryParams(c, &req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid query params").SetInternal(err)
+	}
+	if err = util.Validate.Struct(&req); err != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "invalid passkey name").SetInternal(err)
+	}
+	user := handlerutil.AuthenticatedUser(c)
+
+	data, err := h.takeCeremony(c.Request().Context(), passkeyRegistrationKey+user.ID.String())
+	if err != nil {
+		return err
+	}
+
+	u, err := loadPasskeyUser(c.Request().Context(), h.Repo, user.ID, user.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to load passkeys").SetInternal(err)
+	}
+
+	cred, err := w.FinishRegistration(u, *data, c.Request())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid passkey registration").SetInternal(err)
+	}
+
+	credJSON, err := json.Marshal(cred)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to marshal passkey").SetInternal(err)
+	}
+
+	stored, err := h.Repo.CreateWebauthnCredential(c.Request().Context(), repository.CreateWebauthnCredentialParams{
+		UserID:       user.ID,
+		CredentialID: cred.ID,
+		Name:         req.Name,
+		Credential:   credJSON,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return c.JSON(http.StatusConflict, APIErrorResponse{
+				Error: "passkey is already registered",
+			})
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save passkey").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusCreated, APISuccessResponse{
+		Data: newPasskey(stored),
+	})
+}
+
+// BeginPasskeyLogin returns the options for 'navigator.credentials.get()'. Any passkey of any user is accepted, the authenticator tells which user it belongs to.
+func (h *Handler) BeginPasskeyLogin(c echo.Context) error {
+	w, err := h.webAuthn()
+	if err != nil {
+		return err
+	}
+
+	assertion, data, err := w.BeginDiscoverableLogin()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to begin passkey sign-in").SetInternal(err)
+	}
+
+	ceremonyID, err := util.GenerateToken(16)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate passkey challenge ID").SetInternal(err)
+	}
+	if err = h.saveCeremony(c.Request().Context(), passkeyLoginKey+ceremonyID, data); err != nil {
+		return err
+	}
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get session").SetInternal(err)
+	}
+	sess.Values["passkeyCeremonyID"] = ceremonyID
+	if err = sess.Save(c.Request(), c.Response()); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save session").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: assertion,
+	})
+}
+
+// FinishPasskeyLogin verifies the response of the authenticator, sent as the request body, & signs its user in like 'VerifyAuthOTP'.
+func (h *Handler) FinishPasskeyLogin(c echo.Context) error {
+	w, err := h.webAuthn()
+	if err != nil {
+		return err
+	}
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get session").SetInternal(err)
+	}
+	ceremonyID, _ := sess.Values["passkeyCeremonyID"].(string)
+	if ceremonyID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "passkey challenge not found or expired")
+	}
+	// Saved along with the new session.
+	delete(sess.Values, "passkeyCeremonyID")
+
+	data, err := h.takeCeremony(c.Request().Context(), passkeyLoginKey+ceremonyID)
+	if err != nil {
+		return err
+	}
+
+	var userID pgtype.UUID
+	findUser := func(_, userHandle []byte) (webauthn.User, error) {
+		id, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		userID = pgtype.UUID{Bytes: id, Valid: true}
+
+		user, err := h.Repo.GetUserByID(c.Request().Context(), userID)
+		if err != nil {
+			return nil, err
+		}
+		return loadPasskeyUser(c.Request().Context(), h.Repo, user.ID, user.Email)
+	}
+
+	_, cred, err := w.FinishPasskeyLogin(findUser, *data, c.Request())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid passkey").SetInternal(err)
+	}
+	// The sign count didn't increase since the last use, so the passkey may have been cloned. Authenticators that don't keep a count always report 0, which doesn't warn.
+	if cred.Authenticator.CloneWarning {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid passkey").SetInternal(fmt.Errorf("sign count %d isn't above the stored count, the passkey may have been cloned", cred.Authenticator.SignCount))
+	}
+
+	// Stores the new sign count, which the next sign-in must exceed.
+	credJSON, err := json.Marshal(cred)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to marshal passkey").SetInternal(err)
+	}
+	if err = h.Repo.UpdateWebauthnCredentialUsage(c.Request().Context(), repository.UpdateWebauthnCredentialUsageParams{
+		Credential:   credJSON,
+		CredentialID: cred.ID,
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to update passkey").SetInternal(err)
+	}
+
+	totpRequired, err := h.authenticate(c, userID, false)
+	if err != nil {
+		return err
+	}
+	if totpRequired {
+		return c.JSON(http.StatusOK, APISuccessResponse{
+			Data: echo.Map{
+				"totp_required": true,
+			},
+		})
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *Handler) ListMyPasskeys(c echo.Context) error {
+	user := handlerutil.AuthenticatedUser(c)
+
+	creds, err := h.Repo.ListWebauthnCredentials(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list passkeys").SetInternal(err)
+	}
+
+	out := make([]passkey, 0, len(creds))
+	for _, cred := range creds {
+		out = append(out, newPasskey(cred))
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
+
+func (h *Handler) DeleteMyPasskey(c echo.Context) error {
+	var req struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	passkeyID, err := parseUUID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	n, err := h.Repo.DeleteWebauthnCredential(c.Request().Context(), repository.DeleteWebauthnCredentialParams{
+		ID:     passkeyID,
+		UserID: handlerutil.AuthenticatedUser(c).ID,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete passkey").SetInternal(err)
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, APIErrorResponse{
+			Error: "passkey not found",
+		})
+	}
+
+	return c.NoContent(http.StatusOK)
+}
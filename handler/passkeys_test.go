@@ -0,0 +1,427 @@
+package handler
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/deps/config"
+)
+
+// Flags of the authenticator data, see https://www.w3.org/TR/webauthn-3/#authdata-flags.
+const (
+	authDataUserPresent      = 0x01
+	authDataUserVerified     = 0x04
+	authDataAttestedCredData = 0x40
+)
+
+// softAuthenticator is a platform authenticator in software with a single ES256 passkey. It answers ceremonies like a browser would, with 'none' attestation.
+type softAuthenticator struct {
+	rpID         string
+	origin       string
+	credentialID []byte
+	key          *ecdsa.PrivateKey
+	// Set by 'register', like a resident key stores it. Tests may change it to impersonate another user.
+	userHandle []byte
+	// Reported in the authenticator data. It stays 0 unless a test sets it, like authenticators that can't keep a counter report.
+	signCount uint32
+}
+
+func newSoftAuthenticator(t *testing.T, cfg *config.Config) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate passkey: %v", err)
+	}
+	credentialID := make([]byte, 32)
+	_, _ = rand.Read(credentialID)
+
+	return &softAuthenticator{
+		rpID:         cfg.WebAuthnRPID,
+		origin:       cfg.AllowedOrigins[0],
+		credentialID: credentialID,
+		key:          key,
+	}
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge string) []byte {
+	t.Helper()
+
+	clientData, err := json.Marshal(map[string]any{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.origin,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal client data: %v", err)
+	}
+	return clientData
+}
+
+// authData returns the authenticator data, with the attested credential if it is for a registration.
+func (a *softAuthenticator) authData(t *testing.T, attested bool) []byte {
+	t.Helper()
+
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := byte(authDataUserPresent | authDataUserVerified)
+	if attested {
+		flags |= authDataAttestedCredData
+	}
+	data = binary.BigEndian.AppendUint32(append(data, flags), a.signCount)
+	if !attested {
+		return data
+	}
+
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal public key: %v", err)
+	}
+	data = append(data, make([]byte, 16)...) // AAGUID
+	data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+	data = append(data, a.credentialID...)
+	return append(data, publicKey...)
+}
+
+// register returns the response of 'navigator.credentials.create()' to the challenge.
+func (a *softAuthenticator) register(t *testing.T, challenge string, userHandle []byte) json.RawMessage {
+	t.Helper()
+
+	a.userHandle = userHandle
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(t, true),
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal attestation: %v", err)
+	}
+
+	return a.credential(t, map[string]any{
+		"clientDataJSON":    b64(a.clientData(t, "webauthn.create", challenge)),
+		"attestationObject": b64(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// login returns the response of 'navigator.credentials.get()' to the challenge.
+func (a *softAuthenticator) login(t *testing.T, challenge string) json.RawMessage {
+	t.Helper()
+
+	clientData := a.clientData(t, "webauthn.get", challenge)
+	authData := a.authData(t, false)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("failed to sign assertion: %v", err)
+	}
+
+	return a.credential(t, map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(signature),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]any) json.RawMessage {
+	t.Helper()
+
+	b, err := json.Marshal(map[string]any{
+		"id":                      b64(a.credentialID),
+		"rawId":                   b64(a.credentialID),
+		"type":                    "public-key",
+		"authenticatorAttachment": "platform",
+		"response":                response,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal credential: %v", err)
+	}
+	return b
+}
+
+// ceremonyOptions are the parts of the options of both ceremonies that the authenticator needs.
+type ceremonyOptions struct {
+	Data struct {
+		PublicKey struct {
+			Challenge string `json:"challenge"`
+			User      struct {
+				ID string `json:"id"`
+			} `json:"user"`
+		} `json:"publicKey"`
+	} `json:"data"`
+}
+
+type passkeyTest struct {
+	cfg  *config.Config
+	repo *fakeRepo
+	srv  *httptest.Server
+}
+
+func newPasskeyTest(t *testing.T) *passkeyTest {
+	t.Helper()
+
+	cfg := testConfig(t)
+	cfg.WebAuthnRPID = "localhost"
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+	return &passkeyTest{cfg: cfg, repo: repo, srv: srv}
+}
+
+// signIn returns a client of a new session of the user with the email.
+func (pt *passkeyTest) signIn(t *testing.T, email string) (*http.Client, pgtype.UUID) {
+	t.Helper()
+
+	user, sessionID := pt.repo.addSession(email)
+	return newSignedInClient(t, pt.srv, pt.cfg, sessionID), user.ID
+}
+
+// beginRegistration returns the response of the authenticator to the registration challenge.
+func (pt *passkeyTest) beginRegistration(t *testing.T, client *http.Client, a *softAuthenticator) json.RawMessage {
+	t.Helper()
+
+	var opts ceremonyOptions
+	if status := doJSON(t, client, http.MethodPost, pt.srv.URL+"/auth/passkeys/register/begin", nil, &opts); status != http.StatusOK {
+		t.Fatalf("begin registration: status = %d, want %d", status, http.StatusOK)
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(opts.Data.PublicKey.User.ID)
+	if err != nil {
+		t.Fatalf("failed to decode user handle: %v", err)
+	}
+	return a.register(t, opts.Data.PublicKey.Challenge, userHandle)
+}
+
+func (pt *passkeyTest) finishRegistration(t *testing.T, client *http.Client, response json.RawMessage) int {
+	t.Helper()
+
+	return doJSON(t, client, http.MethodPost, pt.srv.URL+"/auth/passkeys/register/finish?name=Laptop", response, nil)
+}
+
+// register adds the passkey of the authenticator to the user of the client.
+func (pt *passkeyTest) register(t *testing.T, client *http.Client, a *softAuthenticator) {
+	t.Helper()
+
+	if status := pt.finishRegistration(t, client, pt.beginRegistration(t, client, a)); status != http.StatusCreated {
+		t.Fatalf("finish registration: status = %d, want %d", status, http.StatusCreated)
+	}
+}
+
+// beginLogin returns the response of the authenticator to the sign-in challenge. The challenge is tied to the cookies of the client.
+func (pt *passkeyTest) beginLogin(t *testing.T, client *http.Client, a *softAuthenticator) json.RawMessage {
+	t.Helper()
+
+	var opts ceremonyOptions
+	if status := doJSON(t, client, http.MethodPost, pt.srv.URL+"/auth/passkeys/login/begin", nil, &opts); status != http.StatusOK {
+		t.Fatalf("begin login: status = %d, want %d", status, http.StatusOK)
+	}
+	return a.login(t, opts.Data.PublicKey.Challenge)
+}
+
+func (pt *passkeyTest) finishLogin(t *testing.T, client *http.Client, response json.RawMessage) int {
+	t.Helper()
+
+	return doJSON(t, client, http.MethodPost, pt.srv.URL+"/auth/passkeys/login/finish", response, nil)
+}
+
+func (pt *passkeyTest) listPasskeys(t *testing.T, client *http.Client) []passkey {
+	t.Helper()
+
+	var res struct {
+		Data []passkey `json:"data"`
+	}
+	if status := doJSON(t, client, http.MethodGet, pt.srv.URL+"/auth/passkeys", nil, &res); status != http.StatusOK {
+		t.Fatalf("list passkeys: status = %d, want %d", status, http.StatusOK)
+	}
+	return res.Data
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	pt := newPasskeyTest(t)
+	owner, ownerID := pt.signIn(t, "owner@example.com")
+	a := newSoftAuthenticator(t, pt.cfg)
+	pt.register(t, owner, a)
+
+	if passkeys := pt.listPasskeys(t, owner); len(passkeys) != 1 || passkeys[0].Name != "Laptop" {
+		t.Fatalf("passkeys = %+v, want the registered one", passkeys)
+	}
+
+	client := newTestClient(t)
+	if status := pt.finishLogin(t, client, pt.beginLogin(t, client, a)); status != http.StatusOK {
+		t.Fatalf("finish login: status = %d, want %d", status, http.StatusOK)
+	}
+	// Signed in.
+	if passkeys := pt.listPasskeys(t, client); len(passkeys) != 1 || passkeys[0].LastUsedAt == nil {
+		t.Errorf("passkeys = %+v, want the one used to sign in", passkeys)
+	}
+	// Passkeys don't prove access to the email.
+	if pt.repo.users[ownerID].VerifiedAt.Valid {
+		t.Error("email was verified by signing in with a passkey")
+	}
+}
+
+func TestPasskeyChallengeReplay(t *testing.T) {
+	pt := newPasskeyTest(t)
+	owner, _ := pt.signIn(t, "owner@example.com")
+	a := newSoftAuthenticator(t, pt.cfg)
+
+	t.Run("registration", func(t *testing.T) {
+		response := pt.beginRegistration(t, owner, a)
+		if status := pt.finishRegistration(t, owner, response); status != http.StatusCreated {
+			t.Fatalf("finish registration: status = %d, want %d", status, http.StatusCreated)
+		}
+		if status := pt.finishRegistration(t, owner, response); status != http.StatusBadRequest {
+			t.Errorf("replayed registration: status = %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("login", func(t *testing.T) {
+		client := newTestClient(t)
+		response := pt.beginLogin(t, client, a)
+
+		// Replayed with the cookie the challenge was issued to, which signing in replaces.
+		srvURL, _ := url.Parse(pt.srv.URL)
+		replayer := newTestClient(t)
+		replayer.Jar.SetCookies(srvURL, client.Jar.Cookies(srvURL))
+
+		if status := pt.finishLogin(t, client, response); status != http.StatusOK {
+			t.Fatalf("finish login: status = %d, want %d", status, http.StatusOK)
+		}
+		if status := pt.finishLogin(t, replayer, response); status != http.StatusBadRequest {
+			t.Errorf("replayed login: status = %d, want %d", status, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestPasskeyLoginUnknownUserHandle(t *testing.T) {
+	pt := newPasskeyTest(t)
+	owner, _ := pt.signIn(t, "owner@example.com")
+	a := newSoftAuthenticator(t, pt.cfg)
+	pt.register(t, owner, a)
+
+	unknownID := uuid.New()
+	a.userHandle = unknownID[:]
+
+	client := newTestClient(t)
+	if status := pt.finishLogin(t, client, pt.beginLogin(t, client, a)); status != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestPasskeyLoginOtherUsersHandle(t *testing.T) {
+	pt := newPasskeyTest(t)
+	owner, _ := pt.signIn(t, "owner@example.com")
+	a := newSoftAuthenticator(t, pt.cfg)
+	pt.register(t, owner, a)
+	_, otherID := pt.signIn(t, "other@example.com")
+
+	// The passkey isn't one of the other user's, so it can't sign in as them.
+	a.userHandle = otherID.Bytes[:]
+
+	client := newTestClient(t)
+	if status := pt.finishLogin(t, client, pt.beginLogin(t, client, a)); status != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestPasskeyDuplicateCredential(t *testing.T) {
+	pt := newPasskeyTest(t)
+	owner, _ := pt.signIn(t, "owner@example.com")
+	a := newSoftAuthenticator(t, pt.cfg)
+	pt.register(t, owner, a)
+
+	other, _ := pt.signIn(t, "other@example.com")
+	if status := pt.finishRegistration(t, other, pt.beginRegistration(t, other, a)); status != http.StatusConflict {
+		t.Errorf("status = %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestDeletePasskeyIsScopedToOwner(t *testing.T) {
+	pt := newPasskeyTest(t)
+	owner, _ := pt.signIn(t, "owner@example.com")
+	pt.register(t, owner, newSoftAuthenticator(t, pt.cfg))
+	passkeyURL := pt.srv.URL + "/auth/passkeys/" + pt.listPasskeys(t, owner)[0].ID.String()
+
+	other, _ := pt.signIn(t, "other@example.com")
+	if status := doJSON(t, other, http.MethodDelete, passkeyURL, nil, nil); status != http.StatusNotFound {
+		t.Errorf("deleted by another user: status = %d, want %d", status, http.StatusNotFound)
+	}
+	if passkeys := pt.listPasskeys(t, owner); len(passkeys) != 1 {
+		t.Fatalf("owner has %d passkeys, want 1", len(passkeys))
+	}
+
+	if status := doJSON(t, owner, http.MethodDelete, passkeyURL, nil, nil); status != http.StatusOK {
+		t.Errorf("deleted by owner: status = %d, want %d", status, http.StatusOK)
+	}
+	if passkeys := pt.listPasskeys(t, owner); len(passkeys) != 0 {
+		t.Errorf("owner has %d passkeys, want 0", len(passkeys))
+	}
+}
+
+func TestPasskeyLoginRejectsClonedPasskey(t *testing.T) {
+	pt := newPasskeyTest(t)
+	owner, _ := pt.signIn(t, "owner@example.com")
+	a := newSoftAuthenticator(t, pt.cfg)
+	a.signCount = 5
+	pt.register(t, owner, a)
+
+	storedSignCount := func() uint32 {
+		t.Helper()
+
+		for _, cred := range pt.repo.webauthnCreds {
+			var stored webauthn.Credential
+			if err := json.Unmarshal(cred.Credential, &stored); err != nil {
+				t.Fatalf("failed to unmarshal passkey: %v", err)
+			}
+			return stored.Authenticator.SignCount
+		}
+		t.Fatal("passkey not found")
+		return 0
+	}
+
+	tests := []struct {
+		name       string
+		signCount  uint32
+		wantStatus int
+		// Of the passkey after the sign-in.
+		wantStored uint32
+	}{
+		{name: "increased", signCount: 6, wantStatus: http.StatusOK, wantStored: 6},
+		{name: "same", signCount: 6, wantStatus: http.StatusUnauthorized, wantStored: 6},
+		{name: "decreased", signCount: 3, wantStatus: http.StatusUnauthorized, wantStored: 6},
+		{name: "increased again", signCount: 10, wantStatus: http.StatusOK, wantStored: 10},
+	}
+	for _, tt := range tests {
+		a.signCount = tt.signCount
+		client := newTestClient(t)
+		if status := pt.finishLogin(t, client, pt.beginLogin(t, client, a)); status != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, status, tt.wantStatus)
+		}
+		if got := storedSignCount(); got != tt.wantStored {
+			t.Errorf("%s: stored sign count = %d, want %d", tt.name, got, tt.wantStored)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis answers the commands used by the handlers & their dependencies from memory, so that tests don't need a redis server. Other commands, e.g. scripts, fail like an unreachable server.
+type fakeRedis struct {
+	mu      sync.Mutex
+	strings map[string]string
+	hashes  map[string]map[string]string
+	expiry  map[string]time.Time
+}
+
+// newFakeRedis returns a client that never connects, as every command is answered by the fake.
+func newFakeRedis() *redis.Client {
+	f := &fakeRedis{
+		strings: map[string]string{},
+		hashes:  map[string]map[string]string{},
+		expiry:  map[string]time.Time{},
+	}
+	rdb := redis.NewClient(&redis.Options{
+		Addr:            "fake:6379",
+		MaxRetries:      -1,
+		DisableIdentity: true,
+	})
+	rdb.AddHook(f)
+	return rdb
+}
+
+func (f *fakeRedis) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, fmt.Errorf("fake redis doesn't dial %s", addr)
+	}
+}
+
+func (f *fakeRedis) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.process(cmd)
+		return cmd.Err()
+	}
+}
+
+func (f *fakeRedis) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, cmd := range cmds {
+			f.process(cmd)
+		}
+		for _, cmd := range cmds {
+			if err := cmd.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// expire drops the key if it has expired.
+func (f *fakeRedis) expire(key string) {
+	if at, ok := f.expiry[key]; ok && !time.Now().Before(at) {
+		f.del(key)
+	}
+}
+
+func (f *fakeRedis) del(key string) bool {
+	_, isString := f.strings[key]
+	_, isHash := f.hashes[key]
+	delete(f.strings, key)
+	delete(f.hashes, key)
+	delete(f.expiry, key)
+	return isString || isHash
+}
+
+func (f *fakeRedis) exists(key string) bool {
+	f.expire(key)
+	_, isString := f.strings[key]
+	_, isHash := f.hashes[key]
+	return isString || isHash
+}
+
+func (f *fakeRedis) process(cmd redis.Cmder) {
+	args := make([]string, len(cmd.Args()))
+	for i, arg := range cmd.Args() {
+		switch v := arg.(type) {
+		case string:
+			args[i] = v
+		case []byte:
+			args[i] = string(v)
+		default:
+			args[i] = fmt.Sprint(v)
+		}
+	}
+	if len(args) > 1 {
+		f.expire(args[1])
+	}
+
+	switch name := strings.ToLower(args[0]); name {
+	case "multi", "exec":
+		setStatus(cmd, "OK")
+
+	case "get", "getdel":
+		val, ok := f.strings[args[1]]
+		if !ok {
+			cmd.SetErr(redis.Nil)
+			return
+		}
+		if name == "getdel" {
+			f.del(args[1])
+		}
+		cmd.(*redis.StringCmd).SetVal(val)
+
+	case "set":
+		var ttl time.Duration
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToLower(args[i]) {
+			case "ex":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl, i = time.Duration(n)*time.Second, i+1
+			case "px":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl, i = time.Duration(n)*time.Millisecond, i+1
+			case "nx":
+				nx = true
+			}
+		}
+		if nx && f.exists(args[1]) {
+			if c, ok := cmd.(*redis.BoolCmd); ok {
+				c.SetVal(false)
+			} else {
+				cmd.SetErr(redis.Nil)
+			}
+			return
+		}
+		f.del(args[1])
+		f.strings[args[1]] = args[2]
+		if ttl > 0 {
+			f.expiry[args[1]] = time.Now().Add(ttl)
+		}
+		if c, ok := cmd.(*redis.BoolCmd); ok {
+			c.SetVal(true)
+		} else {
+			setStatus(cmd, "OK")
+		}
+
+	case "del":
+		var n int64
+		for _, key := range args[1:] {
+			f.expire(key)
+			if f.del(key) {
+				n++
+			}
+		}
+		cmd.(*redis.IntCmd).SetVal(n)
+
+	case "exists":
+		var n int64
+		for _, key := range args[1:] {
+			if f.exists(key) {
+				n++
+			}
+		}
+		cmd.(*redis.IntCmd).SetVal(n)
+
+	case "incr", "incrby":
+		by := int64(1)
+		if name == "incrby" {
+			by, _ = strconv.ParseInt(args[2], 10, 64)
+		}
+		n, _ := strconv.ParseInt(f.strings[args[1]], 10, 64)
+		n += by
+		f.strings[args[1]] = strconv.FormatInt(n, 10)
+		cmd.(*redis.IntCmd).SetVal(n)
+
+	case "expire", "pexpire":
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		ttl := time.Duration(n) * time.Second
+		if name == "pexpire" {
+			ttl = time.Duration(n) * time.Millisecond
+		}
+		ok := f.exists(args[1])
+		if ok {
+			f.expiry[args[1]] = time.Now().Add(ttl)
+		}
+		cmd.(*redis.BoolCmd).SetVal(ok)
+
+	case "pttl":
+		ttl := time.Duration(-2)
+		if f.exists(args[1]) {
+			ttl = -1
+			if at, ok := f.expiry[args[1]]; ok {
+				ttl = time.Until(at)
+			}
+		}
+		// Like go-redis, which reports the special values as is & the rest as milliseconds.
+		if ttl > 0 {
+			ttl = ttl.Truncate(time.Millisecond)
+		}
+		cmd.(*redis.DurationCmd).SetVal(ttl)
+
+	case "hset":
+		hash, ok := f.hashes[args[1]]
+		if !ok {
+			hash = map[string]string{}
+			f.hashes[args[1]] = hash
+		}
+		var n int64
+		for i := 2; i+1 < len(args); i += 2 {
+			if _, ok := hash[args[i]]; !ok {
+				n++
+			}
+			hash[args[i]] = args[i+1]
+		}
+		cmd.(*redis.IntCmd).SetVal(n)
+
+	case "hincrby":
+		hash, ok := f.hashes[args[1]]
+		if !ok {
+			hash = map[string]string{}
+			f.hashes[args[1]] = hash
+		}
+		by, _ := strconv.ParseInt(args[3], 10, 64)
+		n, _ := strconv.ParseInt(hash[args[2]], 10, 64)
+		n += by
+		hash[args[2]] = strconv.FormatInt(n, 10)
+		cmd.(*redis.IntCmd).SetVal(n)
+
+	case "hgetall":
+		hash := map[string]string{}
+		for k, v := range f.hashes[args[1]] {
+			hash[k] = v
+		}
+		cmd.(*redis.MapStringStringCmd).SetVal(hash)
+
+	case "rename":
+		if !f.exists(args[1]) {
+			cmd.SetErr(redisError("ERR no such key"))
+			return
+		}
+		str, isString := f.strings[args[1]]
+		hash, isHash := f.hashes[args[1]]
+		at, hasExpiry := f.expiry[args[1]]
+		f.del(args[1])
+		f.del(args[2])
+		if isString {
+			f.strings[args[2]] = str
+		}
+		if isHash {
+			f.hashes[args[2]] = hash
+		}
+		if hasExpiry {
+			f.expiry[args[2]] = at
+		}
+		setStatus(cmd, "OK")
+
+	default:
+		cmd.SetErr(fmt.Errorf("fake redis doesn't support %s", name))
+	}
+}
+
+// redisError is an error reply of the server, see 'redis.Error'.
+type redisError string
+
+func (e redisError) Error() string { return string(e) }
+
+func (redisError) RedisError() {}
+
+func setStatus(cmd redis.Cmder, status string) {
+	if c, ok := cmd.(*redis.StatusCmd); ok {
+		c.SetVal(status)
+	}
+}
@@ -0,0 +1,982 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+func newUUID() pgtype.UUID {
+	return pgtype.UUID{Bytes: uuid.Must(uuid.NewV7()), Valid: true}
+}
+
+// fakeTables are the rows of 'fakeRepo'. Rows are replaced rather than modified, so that a shallow copy is a snapshot.
+type fakeTables struct {
+	accountInvitations map[pgtype.UUID]*repository.AccountInvitation
+	accounts           map[pgtype.UUID]*repository.Account
+	apiKeys            map[pgtype.UUID]*repository.ApiKey
+	billingEvents      map[string]*repository.BillingEvent
+	otps               map[pgtype.UUID]*repository.Otp
+	// Keyed by user ID.
+	recoveryCodes map[pgtype.UUID][]*repository.RecoveryCode
+	// Keyed by token hash.
+	refreshTokens map[string]*repository.RefreshToken
+	sessions      map[pgtype.UUID]*repository.Session
+	// Keyed by account ID.
+	subscriptions map[pgtype.UUID]*repository.Subscription
+	// Keyed by user ID.
+	totpCredentials map[pgtype.UUID]*repository.TotpCredential
+	// Keyed by user ID & account ID.
+	userAccounts map[[2]pgtype.UUID]*repository.UserAccount
+	// Keyed by provider & subject.
+	userIdentities map[[2]string]*repository.UserIdentity
+	users          map[pgtype.UUID]*repository.User
+	webauthnCreds  map[pgtype.UUID]*repository.WebauthnCredential
+}
+
+func (t *fakeTables) clone() fakeTables {
+	return fakeTables{
+		accountInvitations: maps.Clone(t.accountInvitations),
+		accounts:           maps.Clone(t.accounts),
+		apiKeys:            maps.Clone(t.apiKeys),
+		billingEvents:      maps.Clone(t.billingEvents),
+		otps:               maps.Clone(t.otps),
+		recoveryCodes:      maps.Clone(t.recoveryCodes),
+		refreshTokens:      maps.Clone(t.refreshTokens),
+		sessions:           maps.Clone(t.sessions),
+		subscriptions:      maps.Clone(t.subscriptions),
+		totpCredentials:    maps.Clone(t.totpCredentials),
+		userAccounts:       maps.Clone(t.userAccounts),
+		userIdentities:     maps.Clone(t.userIdentities),
+		users:              maps.Clone(t.users),
+		webauthnCreds:      maps.Clone(t.webauthnCreds),
+	}
+}
+
+// fakeRepo is an in-memory 'repository.Store' for handler tests. Queries the tests don't need panic through the nil 'repository.Querier'.
+type fakeRepo struct {
+	repository.Querier
+	mu sync.Mutex
+	fakeTables
+	// failQuery names a query that fails with errFakeQuery, e.g. to test that transactions are rolled back.
+	failQuery string
+}
+
+var errFakeQuery = errors.New("fake query failure")
+
+func newFakeRepo() *fakeRepo {
+	return &fakeRepo{
+		fakeTables: fakeTables{
+			accountInvitations: map[pgtype.UUID]*repository.AccountInvitation{},
+			accounts:           map[pgtype.UUID]*repository.Account{},
+			apiKeys:            map[pgtype.UUID]*repository.ApiKey{},
+			billingEvents:      map[string]*repository.BillingEvent{},
+			otps:               map[pgtype.UUID]*repository.Otp{},
+			recoveryCodes:      map[pgtype.UUID][]*repository.RecoveryCode{},
+			refreshTokens:      map[string]*repository.RefreshToken{},
+			sessions:           map[pgtype.UUID]*repository.Session{},
+			subscriptions:      map[pgtype.UUID]*repository.Subscription{},
+			totpCredentials:    map[pgtype.UUID]*repository.TotpCredential{},
+			userAccounts:       map[[2]pgtype.UUID]*repository.UserAccount{},
+			userIdentities:     map[[2]string]*repository.UserIdentity{},
+			users:              map[pgtype.UUID]*repository.User{},
+			webauthnCreds:      map[pgtype.UUID]*repository.WebauthnCredential{},
+		},
+	}
+}
+
+// InTx restores the rows as they were before fn if it fails. Transactions aren't isolated from each other.
+func (r *fakeRepo) InTx(ctx context.Context, fn func(repo repository.Querier) error) error {
+	r.mu.Lock()
+	snapshot := r.clone()
+	r.mu.Unlock()
+
+	if err := fn(r); err != nil {
+		r.mu.Lock()
+		r.fakeTables = snapshot
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *fakeRepo) GetAccount(ctx context.Context, id pgtype.UUID) (*repository.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (r *fakeRepo) CreateAccount(ctx context.Context, name *string) (*repository.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	account := &repository.Account{
+		ID:        newUUID(),
+		Name:      name,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.accounts[account.ID] = account
+	copied := *account
+	return &copied, nil
+}
+
+func (r *fakeRepo) UpdateAccountName(ctx context.Context, arg repository.UpdateAccountNameParams) (*repository.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	account, ok := r.accounts[arg.ID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	updated := *account
+	updated.Name = arg.Name
+	updated.UpdatedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	r.accounts[arg.ID] = &updated
+	copied := updated
+	return &copied, nil
+}
+
+func (r *fakeRepo) DeleteAccount(ctx context.Context, id pgtype.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.accounts[id]; !ok {
+		return 0, nil
+	}
+	delete(r.accounts, id)
+	return 1, nil
+}
+
+func (r *fakeRepo) CreateUserAccount(ctx context.Context, arg repository.CreateUserAccountParams) (*repository.UserAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failQuery == "CreateUserAccount" {
+		return nil, errFakeQuery
+	}
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	membership := &repository.UserAccount{
+		UserID:    arg.UserID,
+		AccountID: arg.AccountID,
+		Status:    "active",
+		Role:      arg.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.userAccounts[[2]pgtype.UUID{arg.UserID, arg.AccountID}] = membership
+	copied := *membership
+	return &copied, nil
+}
+
+func (r *fakeRepo) GetUserAccount(ctx context.Context, arg repository.GetUserAccountParams) (*repository.UserAccount, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	membership, ok := r.userAccounts[[2]pgtype.UUID{arg.UserID, arg.AccountID}]
+	if !ok || membership.Status != "active" {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *membership
+	return &copied, nil
+}
+
+func (r *fakeRepo) ListUserAccounts(ctx context.Context, userID pgtype.UUID) ([]*repository.ListUserAccountsRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rows []*repository.ListUserAccountsRow
+	for key, membership := range r.userAccounts {
+		account, ok := r.accounts[key[1]]
+		if key[0] != userID || membership.Status != "active" || !ok {
+			continue
+		}
+		rows = append(rows, &repository.ListUserAccountsRow{
+			ID:        account.ID,
+			Name:      account.Name,
+			CreatedAt: account.CreatedAt,
+			UpdatedAt: account.UpdatedAt,
+			Role:      membership.Role,
+		})
+	}
+	slices.SortFunc(rows, func(a, b *repository.ListUserAccountsRow) int {
+		return a.CreatedAt.Time.Compare(b.CreatedAt.Time)
+	})
+	return rows, nil
+}
+
+func (r *fakeRepo) DeleteAccountMembers(ctx context.Context, accountID pgtype.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key := range r.userAccounts {
+		if key[1] == accountID {
+			delete(r.userAccounts, key)
+		}
+	}
+	return nil
+}
+
+// LockAccount doesn't lock, as transactions of the fake aren't isolated anyway.
+func (r *fakeRepo) LockAccount(ctx context.Context, id pgtype.UUID) (*repository.Account, error) {
+	return r.GetAccount(ctx, id)
+}
+
+func (r *fakeRepo) ListAccountMembers(ctx context.Context, accountID pgtype.UUID) ([]*repository.ListAccountMembersRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var rows []*repository.ListAccountMembersRow
+	for key, membership := range r.userAccounts {
+		if key[1] != accountID {
+			continue
+		}
+		rows = append(rows, &repository.ListAccountMembersRow{
+			UserID:    membership.UserID,
+			AccountID: membership.AccountID,
+			Status:    membership.Status,
+			CreatedAt: membership.CreatedAt,
+			UpdatedAt: membership.UpdatedAt,
+			Role:      membership.Role,
+			Email:     r.users[membership.UserID].Email,
+		})
+	}
+	slices.SortFunc(rows, func(a, b *repository.ListAccountMembersRow) int {
+		return a.CreatedAt.Time.Compare(b.CreatedAt.Time)
+	})
+	return rows, nil
+}
+
+func (r *fakeRepo) AccountHasMemberWithEmail(ctx context.Context, arg repository.AccountHasMemberWithEmailParams) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, membership := range r.userAccounts {
+		if key[1] == arg.AccountID && membership.Status == "active" && r.users[key[0]].Email == arg.Email {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeRepo) CreateAccountInvitation(ctx context.Context, arg repository.CreateAccountInvitationParams) (*repository.AccountInvitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, inv := range r.accountInvitations {
+		if inv.AccountID == arg.AccountID && inv.Email == arg.Email && inv.Status == "pending" {
+			return nil, &pgconn.PgError{Code: "23505", ConstraintName: "idx_account_invitations_pending_email"}
+		}
+	}
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	inv := &repository.AccountInvitation{
+		ID:        newUUID(),
+		AccountID: arg.AccountID,
+		Email:     arg.Email,
+		Role:      arg.Role,
+		TokenHash: arg.TokenHash,
+		InvitedBy: arg.InvitedBy,
+		Status:    "pending",
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.accountInvitations[inv.ID] = inv
+	copied := *inv
+	return &copied, nil
+}
+
+func (r *fakeRepo) CountPendingAccountInvitations(ctx context.Context, accountID pgtype.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for _, inv := range r.accountInvitations {
+		if inv.AccountID == accountID && inv.Status == "pending" && inv.ExpiresAt.Time.After(time.Now()) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *fakeRepo) RevokeAccountInvitation(ctx context.Context, arg repository.RevokeAccountInvitationParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	inv, ok := r.accountInvitations[arg.ID]
+	if !ok || inv.AccountID != arg.AccountID || inv.Status != "pending" {
+		return 0, nil
+	}
+	revoked := *inv
+	revoked.Status = "revoked"
+	revoked.RespondedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	r.accountInvitations[arg.ID] = &revoked
+	return 1, nil
+}
+
+// GetUsageRecord finds nothing, as usage is only counted in redis by the tests.
+func (r *fakeRepo) GetUsageRecord(ctx context.Context, arg repository.GetUsageRecordParams) (*repository.UsageRecord, error) {
+	return nil, pgx.ErrNoRows
+}
+
+func (r *fakeRepo) CreateBillingEvent(ctx context.Context, arg repository.CreateBillingEventParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.billingEvents[arg.ID]; ok {
+		return 0, nil
+	}
+	r.billingEvents[arg.ID] = &repository.BillingEvent{
+		ID:      arg.ID,
+		Type:    arg.Type,
+		Payload: arg.Payload,
+	}
+	return 1, nil
+}
+
+func (r *fakeRepo) GetSubscriptionByAccountID(ctx context.Context, accountID pgtype.UUID) (*repository.Subscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	subscription, ok := r.subscriptions[accountID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *subscription
+	return &copied, nil
+}
+
+func (r *fakeRepo) UpsertSubscription(ctx context.Context, arg repository.UpsertSubscriptionParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Same as the query's 'WHERE' on conflict.
+	if existing, ok := r.subscriptions[arg.AccountID]; ok && existing.LastEventAt.Valid && existing.LastEventAt.Time.After(arg.LastEventAt.Time) {
+		return 0, nil
+	}
+	r.subscriptions[arg.AccountID] = &repository.Subscription{
+		ID:          newUUID(),
+		AccountID:   arg.AccountID,
+		PlanID:      arg.PlanID,
+		Status:      arg.Status,
+		StartsAt:    arg.StartsAt,
+		EndsAt:      arg.EndsAt,
+		LastEventAt: arg.LastEventAt,
+	}
+	return 1, nil
+}
+
+func (r *fakeRepo) UpsertUser(ctx context.Context, email string) (*repository.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	user := &repository.User{
+		ID:    newUUID(),
+		Email: email,
+		Role:  handlerutil.UserRoleUser,
+	}
+	r.users[user.ID] = user
+	copied := *user
+	return &copied, nil
+}
+
+// addSession creates a user with the email unless it exists & a session for them, like signing in.
+func (r *fakeRepo) addSession(email string) (*repository.User, pgtype.UUID) {
+	user, _ := r.UpsertUser(context.Background(), email)
+	sessionID, _ := r.CreateSession(context.Background(), repository.CreateSessionParams{
+		UserID:    user.ID,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	})
+	return user, sessionID
+}
+
+// addAccount creates an account owned by the user.
+func (r *fakeRepo) addAccount(ownerID pgtype.UUID) pgtype.UUID {
+	name := "Acme"
+	account, _ := r.CreateAccount(context.Background(), &name)
+	_, _ = r.CreateUserAccount(context.Background(), repository.CreateUserAccountParams{
+		UserID:    ownerID,
+		AccountID: account.ID,
+		Role:      handlerutil.RoleOwner,
+	})
+	return account.ID
+}
+
+func (r *fakeRepo) CreateSession(ctx context.Context, arg repository.CreateSessionParams) (pgtype.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session := &repository.Session{
+		ID:         newUUID(),
+		UserID:     arg.UserID,
+		UserAgent:  arg.UserAgent,
+		IpAddress:  arg.IpAddress,
+		ExpiresAt:  arg.ExpiresAt,
+		CreatedAt:  pgtype.Timestamptz{Time: time.Now(), Valid: true},
+		LastSeenAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	r.sessions[session.ID] = session
+	return session.ID, nil
+}
+
+func (r *fakeRepo) GetUserBySessionId(ctx context.Context, sessionID pgtype.UUID) (*repository.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[sessionID]
+	if !ok || session.RevokedAt.Valid || !session.ExpiresAt.Time.After(time.Now()) {
+		return nil, pgx.ErrNoRows
+	}
+	user, ok := r.users[session.UserID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeRepo) RevokeSession(ctx context.Context, id pgtype.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if session, ok := r.sessions[id]; ok && !session.RevokedAt.Valid {
+		revoked := *session
+		revoked.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+		r.sessions[id] = &revoked
+	}
+	return nil
+}
+
+func (r *fakeRepo) ListUserSessions(ctx context.Context, userID pgtype.UUID) ([]*repository.Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sessions []*repository.Session
+	for _, session := range r.sessions {
+		if session.UserID == userID && !session.RevokedAt.Valid && session.ExpiresAt.Time.After(time.Now()) {
+			copied := *session
+			sessions = append(sessions, &copied)
+		}
+	}
+	slices.SortFunc(sessions, func(a, b *repository.Session) int {
+		return b.CreatedAt.Time.Compare(a.CreatedAt.Time)
+	})
+	return sessions, nil
+}
+
+func (r *fakeRepo) RevokeUserSession(ctx context.Context, arg repository.RevokeUserSessionParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, ok := r.sessions[arg.ID]
+	if !ok || session.UserID != arg.UserID || session.RevokedAt.Valid {
+		return 0, nil
+	}
+	revoked := *session
+	revoked.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	r.sessions[arg.ID] = &revoked
+	return 1, nil
+}
+
+func (r *fakeRepo) RevokeOtherUserSessions(ctx context.Context, arg repository.RevokeOtherUserSessionsParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for id, session := range r.sessions {
+		if session.UserID != arg.UserID || id == arg.CurrentSessionID || session.RevokedAt.Valid {
+			continue
+		}
+		revoked := *session
+		revoked.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+		r.sessions[id] = &revoked
+		n++
+	}
+	return n, nil
+}
+
+// TouchSessions slides expiries like the query, capped at the max lifetime from creation.
+func (r *fakeRepo) TouchSessions(ctx context.Context, arg repository.TouchSessionsParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idleTimeout := time.Duration(arg.IdleTimeout.Microseconds) * time.Microsecond
+	maxLifetime := time.Duration(arg.MaxLifetime.Microseconds) * time.Microsecond
+	for i, id := range arg.Ids {
+		session, ok := r.sessions[id]
+		seenAt := arg.SeenAts[i].Time
+		if !ok || session.RevokedAt.Valid || !seenAt.After(session.LastSeenAt.Time) {
+			continue
+		}
+		touched := *session
+		touched.LastSeenAt = arg.SeenAts[i]
+		expiresAt := seenAt.Add(idleTimeout)
+		if maxExpiresAt := session.CreatedAt.Time.Add(maxLifetime); maxExpiresAt.Before(expiresAt) {
+			expiresAt = maxExpiresAt
+		}
+		touched.ExpiresAt = pgtype.Timestamptz{Time: expiresAt, Valid: true}
+		r.sessions[id] = &touched
+	}
+	return nil
+}
+
+func (r *fakeRepo) GetTotpCredential(ctx context.Context, userID pgtype.UUID) (*repository.TotpCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cred, ok := r.totpCredentials[userID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *cred
+	return &copied, nil
+}
+
+func (r *fakeRepo) UpsertTotpCredential(ctx context.Context, arg repository.UpsertTotpCredentialParams) (*repository.TotpCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.totpCredentials[arg.UserID]; ok && existing.ConfirmedAt.Valid {
+		return nil, pgx.ErrNoRows
+	}
+	cred := &repository.TotpCredential{
+		UserID:          arg.UserID,
+		SecretEncrypted: arg.SecretEncrypted,
+	}
+	r.totpCredentials[arg.UserID] = cred
+	copied := *cred
+	return &copied, nil
+}
+
+func (r *fakeRepo) ConfirmTotpCredential(ctx context.Context, userID pgtype.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cred, ok := r.totpCredentials[userID]
+	if !ok || cred.ConfirmedAt.Valid {
+		return 0, nil
+	}
+	confirmed := *cred
+	confirmed.ConfirmedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	r.totpCredentials[userID] = &confirmed
+	return 1, nil
+}
+
+func (r *fakeRepo) DeleteTotpCredential(ctx context.Context, userID pgtype.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.totpCredentials[userID]; !ok {
+		return 0, nil
+	}
+	delete(r.totpCredentials, userID)
+	return 1, nil
+}
+
+func (r *fakeRepo) CreateRecoveryCodes(ctx context.Context, arg repository.CreateRecoveryCodesParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	codes := slices.Clone(r.recoveryCodes[arg.UserID])
+	for _, hash := range arg.CodeHashes {
+		codes = append(codes, &repository.RecoveryCode{ID: newUUID(), UserID: arg.UserID, CodeHash: hash})
+	}
+	r.recoveryCodes[arg.UserID] = codes
+	return nil
+}
+
+func (r *fakeRepo) DeleteRecoveryCodes(ctx context.Context, userID pgtype.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.recoveryCodes, userID)
+	return nil
+}
+
+func (r *fakeRepo) GetUserByID(ctx context.Context, id pgtype.UUID) (*repository.GetUserByIDRow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &repository.GetUserByIDRow{ID: user.ID, Username: user.Username, Email: user.Email}, nil
+}
+
+func (r *fakeRepo) UpdateUser(ctx context.Context, arg repository.UpdateUserParams) (*repository.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[arg.ID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	updated := *user
+	if email, ok := arg.Email.(string); ok && email != "" {
+		updated.Email = email
+	}
+	if arg.VerifiedAt.Valid {
+		updated.VerifiedAt = arg.VerifiedAt
+	}
+	r.users[arg.ID] = &updated
+	copied := updated
+	return &copied, nil
+}
+
+func (r *fakeRepo) ActivateUserAccounts(ctx context.Context, userID pgtype.UUID) error {
+	return nil
+}
+
+func (r *fakeRepo) CreateWebauthnCredential(ctx context.Context, arg repository.CreateWebauthnCredentialParams) (*repository.WebauthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, cred := range r.webauthnCreds {
+		if bytes.Equal(cred.CredentialID, arg.CredentialID) {
+			return nil, &pgconn.PgError{Code: "23505", ConstraintName: "webauthn_credentials_credential_id_key"}
+		}
+	}
+	cred := &repository.WebauthnCredential{
+		ID:           newUUID(),
+		UserID:       arg.UserID,
+		CredentialID: arg.CredentialID,
+		Name:         arg.Name,
+		Credential:   arg.Credential,
+		CreatedAt:    pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	r.webauthnCreds[cred.ID] = cred
+	copied := *cred
+	return &copied, nil
+}
+
+func (r *fakeRepo) ListWebauthnCredentials(ctx context.Context, userID pgtype.UUID) ([]*repository.WebauthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var creds []*repository.WebauthnCredential
+	for _, cred := range r.webauthnCreds {
+		if cred.UserID == userID {
+			copied := *cred
+			creds = append(creds, &copied)
+		}
+	}
+	slices.SortFunc(creds, func(a, b *repository.WebauthnCredential) int {
+		return a.CreatedAt.Time.Compare(b.CreatedAt.Time)
+	})
+	return creds, nil
+}
+
+func (r *fakeRepo) UpdateWebauthnCredentialUsage(ctx context.Context, arg repository.UpdateWebauthnCredentialUsageParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, cred := range r.webauthnCreds {
+		if bytes.Equal(cred.CredentialID, arg.CredentialID) {
+			updated := *cred
+			updated.Credential = arg.Credential
+			updated.LastUsedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+			r.webauthnCreds[id] = &updated
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) DeleteWebauthnCredential(ctx context.Context, arg repository.DeleteWebauthnCredentialParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cred, ok := r.webauthnCreds[arg.ID]
+	if !ok || cred.UserID != arg.UserID {
+		return 0, nil
+	}
+	delete(r.webauthnCreds, arg.ID)
+	return 1, nil
+}
+
+func (r *fakeRepo) GetUserIdentity(ctx context.Context, arg repository.GetUserIdentityParams) (*repository.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identity, ok := r.userIdentities[[2]string{arg.Provider, arg.Subject}]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *identity
+	return &copied, nil
+}
+
+func (r *fakeRepo) UpsertUserIdentity(ctx context.Context, arg repository.UpsertUserIdentityParams) (*repository.UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{arg.Provider, arg.Subject}
+	identity := &repository.UserIdentity{
+		ID:       newUUID(),
+		UserID:   arg.UserID,
+		Provider: arg.Provider,
+		Subject:  arg.Subject,
+	}
+	// Same as the query, which keeps the linked user on conflict.
+	if existing, ok := r.userIdentities[key]; ok {
+		copied := *existing
+		identity = &copied
+	}
+	identity.Email = arg.Email
+	r.userIdentities[key] = identity
+	copied := *identity
+	return &copied, nil
+}
+
+func (r *fakeRepo) CreateRefreshToken(ctx context.Context, arg repository.CreateRefreshTokenParams) (*repository.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token := &repository.RefreshToken{
+		ID:        newUUID(),
+		FamilyID:  arg.FamilyID,
+		SessionID: arg.SessionID,
+		TokenHash: arg.TokenHash,
+		Scopes:    arg.Scopes,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	r.refreshTokens[arg.TokenHash] = token
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeRepo) GetRefreshToken(ctx context.Context, tokenHash string) (*repository.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[tokenHash]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *fakeRepo) UseRefreshToken(ctx context.Context, tokenHash string) (*repository.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, ok := r.refreshTokens[tokenHash]
+	if !ok || token.UsedAt.Valid || token.RevokedAt.Valid || !token.ExpiresAt.Time.After(time.Now()) {
+		return nil, pgx.ErrNoRows
+	}
+	used := *token
+	used.UsedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	r.refreshTokens[tokenHash] = &used
+	copied := used
+	return &copied, nil
+}
+
+func (r *fakeRepo) RevokeRefreshTokenFamily(ctx context.Context, familyID pgtype.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, token := range r.refreshTokens {
+		if token.FamilyID == familyID && !token.RevokedAt.Valid {
+			revoked := *token
+			revoked.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+			r.refreshTokens[hash] = &revoked
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) CreateOtp(ctx context.Context, arg repository.CreateOtpParams) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	otp := &repository.Otp{
+		ID:        newUUID(),
+		UserID:    arg.UserID,
+		CodeHash:  arg.CodeHash,
+		ExpiresAt: arg.ExpiresAt,
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	r.otps[otp.ID] = otp
+	return nil
+}
+
+// otpValid reports whether the OTP can still be used, like the queries' 'WHERE'.
+func otpValid(otp *repository.Otp) bool {
+	return !otp.ConsumedAt.Valid && otp.ExpiresAt.Time.After(time.Now())
+}
+
+func (r *fakeRepo) GetOtpByUserId(ctx context.Context, userID pgtype.UUID) (*repository.Otp, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var latest *repository.Otp
+	for _, otp := range r.otps {
+		if otp.UserID == userID && otpValid(otp) && (latest == nil || otp.CreatedAt.Time.After(latest.CreatedAt.Time)) {
+			latest = otp
+		}
+	}
+	if latest == nil {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (r *fakeRepo) IncrementOtpAttempts(ctx context.Context, id pgtype.UUID) (int32, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	otp, ok := r.otps[id]
+	if !ok {
+		return 0, pgx.ErrNoRows
+	}
+	updated := *otp
+	updated.Attempts++
+	r.otps[id] = &updated
+	return updated.Attempts, nil
+}
+
+func (r *fakeRepo) ConsumeOtp(ctx context.Context, id pgtype.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	otp, ok := r.otps[id]
+	if !ok || !otpValid(otp) {
+		return 0, nil
+	}
+	consumed := *otp
+	consumed.ConsumedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	r.otps[id] = &consumed
+	return 1, nil
+}
+
+func (r *fakeRepo) ExpireOtps(ctx context.Context, userID pgtype.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, otp := range r.otps {
+		if otp.UserID == userID && otpValid(otp) {
+			expired := *otp
+			expired.ExpiresAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+			r.otps[id] = &expired
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) CreateApiKey(ctx context.Context, arg repository.CreateApiKeyParams) (*repository.ApiKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	key := &repository.ApiKey{
+		ID:        newUUID(),
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		KeyHash:   arg.KeyHash,
+		Scopes:    arg.Scopes,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	r.apiKeys[key.ID] = key
+	copied := *key
+	return &copied, nil
+}
+
+func (r *fakeRepo) GetApiKeyByPrefix(ctx context.Context, prefix string) (*repository.ApiKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.apiKeys {
+		if key.Prefix == prefix && !key.RevokedAt.Valid {
+			copied := *key
+			return &copied, nil
+		}
+	}
+	return nil, pgx.ErrNoRows
+}
+
+func (r *fakeRepo) GetUserByApiKeyId(ctx context.Context, apiKeyID pgtype.UUID) (*repository.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[apiKeyID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	user, ok := r.users[key.UserID]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeRepo) ListApiKeys(ctx context.Context, userID pgtype.UUID) ([]*repository.ApiKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []*repository.ApiKey
+	for _, key := range r.apiKeys {
+		if key.UserID == userID && !key.RevokedAt.Valid {
+			copied := *key
+			keys = append(keys, &copied)
+		}
+	}
+	slices.SortFunc(keys, func(a, b *repository.ApiKey) int {
+		return a.CreatedAt.Time.Compare(b.CreatedAt.Time)
+	})
+	return keys, nil
+}
+
+func (r *fakeRepo) RevokeApiKey(ctx context.Context, arg repository.RevokeApiKeyParams) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[arg.ID]
+	if !ok || key.UserID != arg.UserID || key.RevokedAt.Valid {
+		return 0, nil
+	}
+	revoked := *key
+	revoked.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	r.apiKeys[arg.ID] = &revoked
+	return 1, nil
+}
+
+func (r *fakeRepo) TouchApiKey(ctx context.Context, id pgtype.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, ok := r.apiKeys[id]; ok {
+		touched := *key
+		touched.LastUsedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+		r.apiKeys[id] = &touched
+	}
+	return nil
+}
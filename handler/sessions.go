@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/mileusna/useragent"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+type clientDevice struct {
+	Browser        string `json:"browser"`
+	BrowserVersion string `json:"browser_version"`
+	OS             string `json:"os"`
+	OSVersion      string `json:"os_version"`
+	Device         string `json:"device"`
+	Mobile         bool   `json:"mobile"`
+	Tablet         bool   `json:"tablet"`
+	Desktop        bool   `json:"desktop"`
+	Bot            bool   `json:"bot"`
+}
+
+type userSession struct {
+	ID         pgtype.UUID   `json:"id"`
+	IPAddress  string        `json:"ip_address"`
+	UserAgent  *string       `json:"user_agent"`
+	Device     *clientDevice `json:"device"`
+	Current    bool          `json:"current"`
+	CreatedAt  time.Time     `json:"created_at"`
+	LastSeenAt time.Time     `json:"last_seen_at"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+}
+
+func newUserSession(s *repository.Session, currentSessionID pgtype.UUID) userSession {
+	out := userSession{
+		ID:         s.ID,
+		IPAddress:  s.IpAddress.String(),
+		UserAgent:  s.UserAgent,
+		Current:    s.ID == currentSessionID,
+		CreatedAt:  s.CreatedAt.Time,
+		LastSeenAt: s.LastSeenAt.Time,
+		ExpiresAt:  s.ExpiresAt.Time,
+	}
+
+	if s.UserAgent != nil {
+		ua := useragent.Parse(*s.UserAgent)
+		out.Device = &clientDevice{
+			Browser:        ua.Name,
+			BrowserVersion: ua.Version,
+			OS:             ua.OS,
+			OSVersion:      ua.OSVersion,
+			Device:         ua.Device,
+			Mobile:         ua.Mobile,
+			Tablet:         ua.Tablet,
+			Desktop:        ua.Desktop,
+			Bot:            ua.Bot,
+		}
+	}
+
+	return out
+}
+
+func (h *Handler) ListMySessions(c echo.Context) error {
+	user := handlerutil.AuthenticatedUser(c)
+
+	sessions, err := h.Repo.ListUserSessions(c.Request().Context(), user.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to list sessions").SetInternal(err)
+	}
+
+	currentSessionID, _ := handlerutil.CurrentSessionID(c)
+	out := make([]userSession, 0, len(sessions))
+	for _, s := range sessions {
+		out = append(out, newUserSession(s, currentSessionID))
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: out,
+	})
+}
+
+func (h *Handler) RevokeMySession(c echo.Context) error {
+	var req struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	sessionID, err := parseUUID(req.ID)
+	if err != nil {
+		return err
+	}
+
+	user := handlerutil.AuthenticatedUser(c)
+
+	n, err := h.Repo.RevokeUserSession(c.Request().Context(), repository.RevokeUserSessionParams{
+		ID:     sessionID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session").SetInternal(err)
+	}
+	if n == 0 {
+		return c.JSON(http.StatusNotFound, APIErrorResponse{
+			Error: "session not found",
+		})
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// RevokeMyOtherSessions signs the user out everywhere except the current session.
+func (h *Handler) RevokeMyOtherSessions(c echo.Context) error {
+	user := handlerutil.AuthenticatedUser(c)
+
+	currentSessionID, _ := handlerutil.CurrentSessionID(c)
+	n, err := h.Repo.RevokeOtherUserSessions(c.Request().Context(), repository.RevokeOtherUserSessionsParams{
+		UserID:           user.ID,
+		CurrentSessionID: currentSessionID,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke sessions").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: echo.Map{
+			"revoked_count": n,
+		},
+	})
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rohitxdev/go-api/database/repository"
+)
+
+// updateSession replaces the session row with a copy modified by fn.
+func (r *fakeRepo) updateSession(id pgtype.UUID, fn func(s *repository.Session)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	updated := *r.sessions[id]
+	fn(&updated)
+	r.sessions[id] = &updated
+}
+
+func TestRequireAuthRejectsInvalidSessions(t *testing.T) {
+	tests := []struct {
+		name       string
+		update     func(s *repository.Session)
+		wantStatus int
+	}{
+		{
+			name:       "valid",
+			update:     func(s *repository.Session) {},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "expired",
+			update: func(s *repository.Session) {
+				s.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true}
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name: "revoked",
+			update: func(s *repository.Session) {
+				s.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+			},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			repo := newFakeRepo()
+			srv, _ := newTestServer(t, cfg, repo)
+
+			_, sessionID := repo.addSession("jane@example.com")
+			repo.updateSession(sessionID, tt.update)
+
+			client := newSignedInClient(t, srv, cfg, sessionID)
+			if status := doJSON(t, client, http.MethodGet, srv.URL+"/users/me", nil, nil); status != tt.wantStatus {
+				t.Errorf("status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSignOutRevokesSession(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	_, sessionID := repo.addSession("jane@example.com")
+	client := newSignedInClient(t, srv, cfg, sessionID)
+	// Has a copy of the cookie, like a stolen one.
+	copied := newSignedInClient(t, srv, cfg, sessionID)
+
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/auth/sign-out", nil, nil); status != http.StatusOK {
+		t.Fatalf("sign out: status = %d, want %d", status, http.StatusOK)
+	}
+
+	if !repo.sessions[sessionID].RevokedAt.Valid {
+		t.Error("session wasn't revoked")
+	}
+	for name, c := range map[string]*http.Client{"signed-out": client, "copied cookie": copied} {
+		if status := doJSON(t, c, http.MethodGet, srv.URL+"/users/me", nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want %d", name, status, http.StatusUnauthorized)
+		}
+	}
+}
+
+type sessionsResponse struct {
+	Data []struct {
+		ID      pgtype.UUID `json:"id"`
+		Current bool        `json:"current"`
+	} `json:"data"`
+}
+
+func TestListMySessions(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	user, current := repo.addSession("jane@example.com")
+	_, other := repo.addSession(user.Email)
+	_, revoked := repo.addSession(user.Email)
+	repo.updateSession(revoked, func(s *repository.Session) {
+		s.RevokedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+	})
+	repo.addSession("john@example.com")
+
+	var res sessionsResponse
+	if status := doJSON(t, newSignedInClient(t, srv, cfg, current), http.MethodGet, srv.URL+"/users/me/sessions", nil, &res); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	// Newest first, without revoked sessions or those of other users.
+	if len(res.Data) != 2 || res.Data[0].ID != other || res.Data[1].ID != current {
+		t.Fatalf("sessions = %+v, want the 2 active sessions of the user", res.Data)
+	}
+	if res.Data[0].Current || !res.Data[1].Current {
+		t.Errorf("sessions = %+v, want only the session of the request to be current", res.Data)
+	}
+}
+
+func TestRevokeMySession(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	user, current := repo.addSession("jane@example.com")
+	_, other := repo.addSession(user.Email)
+	_, notMine := repo.addSession("john@example.com")
+	client := newSignedInClient(t, srv, cfg, current)
+
+	// Sessions of other users look like they don't exist.
+	if status := doJSON(t, client, http.MethodDelete, srv.URL+"/users/me/sessions/"+notMine.String(), nil, nil); status != http.StatusNotFound {
+		t.Errorf("other user's session: status = %d, want %d", status, http.StatusNotFound)
+	}
+	if repo.sessions[notMine].RevokedAt.Valid {
+		t.Error("other user's session was revoked")
+	}
+
+	if status := doJSON(t, client, http.MethodDelete, srv.URL+"/users/me/sessions/"+other.String(), nil, nil); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if status := doJSON(t, newSignedInClient(t, srv, cfg, other), http.MethodGet, srv.URL+"/users/me", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("revoked session: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	if status := doJSON(t, client, http.MethodDelete, srv.URL+"/users/me/sessions/"+other.String(), nil, nil); status != http.StatusNotFound {
+		t.Errorf("revoking again: status = %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestRevokeMyOtherSessions(t *testing.T) {
+	cfg := testConfig(t)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	user, current := repo.addSession("jane@example.com")
+	repo.addSession(user.Email)
+	repo.addSession(user.Email)
+	_, notMine := repo.addSession("john@example.com")
+	client := newSignedInClient(t, srv, cfg, current)
+
+	var res struct {
+		Data struct {
+			RevokedCount int `json:"revoked_count"`
+		} `json:"data"`
+	}
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/users/me/sessions/revoke-others", nil, &res); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if res.Data.RevokedCount != 2 {
+		t.Errorf("revoked_count = %d, want 2", res.Data.RevokedCount)
+	}
+
+	var sessions sessionsResponse
+	doJSON(t, client, http.MethodGet, srv.URL+"/users/me/sessions", nil, &sessions)
+	if len(sessions.Data) != 1 || sessions.Data[0].ID != current {
+		t.Errorf("sessions = %+v, want only the current one", sessions.Data)
+	}
+	if repo.sessions[notMine].RevokedAt.Valid {
+		t.Error("other user's session was revoked")
+	}
+}
+
+func TestSessionExpirySlidesUpToMaxLifetime(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Duration
+		// Of the expiry from the time of the request.
+		wantExpiresIn time.Duration
+	}{
+		// Slid to the idle timeout of 1h from the request.
+		{name: "idle timeout", createdAt: time.Hour * 2, wantExpiresIn: time.Hour},
+		// Created 23.5h ago, so capped at the max lifetime of 24h.
+		{name: "max lifetime", createdAt: time.Hour*23 + time.Minute*30, wantExpiresIn: time.Minute * 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			repo := newFakeRepo()
+			srv, h := newTestServer(t, cfg, repo)
+
+			_, sessionID := repo.addSession("jane@example.com")
+			repo.updateSession(sessionID, func(s *repository.Session) {
+				s.CreatedAt = pgtype.Timestamptz{Time: time.Now().Add(-tt.createdAt), Valid: true}
+				s.LastSeenAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute * 30), Valid: true}
+				s.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(time.Minute * 30), Valid: true}
+			})
+
+			requestedAt := time.Now()
+			if status := doJSON(t, newSignedInClient(t, srv, cfg, sessionID), http.MethodGet, srv.URL+"/users/me", nil, nil); status != http.StatusOK {
+				t.Fatalf("status = %d, want %d", status, http.StatusOK)
+			}
+
+			// Flushes the buffered activity once, since the context is already cancelled.
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			h.SessionTracker.Run(ctx)
+
+			session := repo.sessions[sessionID]
+			// Activity is recorded in seconds.
+			if diff := session.LastSeenAt.Time.Sub(requestedAt).Abs(); diff > time.Second {
+				t.Errorf("last seen %s from the request, want at the request", diff)
+			}
+			if diff := session.ExpiresAt.Time.Sub(requestedAt.Add(tt.wantExpiresIn)).Abs(); diff > time.Second {
+				t.Errorf("expires %s after the request, want %s", session.ExpiresAt.Time.Sub(requestedAt), tt.wantExpiresIn)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo/v4"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/deps/config"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/util"
+)
+
+const refreshTokenSize = 32
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// issueTokens stores a new refresh token in the family & returns it along with an access token.
+func issueTokens(ctx context.Context, repo repository.Querier, cfg *config.Config, userID pgtype.UUID, sessionID pgtype.UUID, familyID pgtype.UUID, scopes []string) (*tokenResponse, error) {
+	accessToken, err := handlerutil.SignAccessToken(cfg, userID, sessionID, scopes)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to sign access token").SetInternal(err)
+	}
+
+	refreshToken, err := util.GenerateToken(refreshTokenSize)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to generate refresh token").SetInternal(err)
+	}
+	if _, err = repo.CreateRefreshToken(ctx, repository.CreateRefreshTokenParams{
+		FamilyID:  familyID,
+		SessionID: sessionID,
+		TokenHash: util.HashToken(refreshToken),
+		Scopes:    scopes,
+		ExpiresAt: pgtype.Timestamptz{
+			Time:  time.Now().Add(cfg.RefreshTokenTTL),
+			Valid: true,
+		},
+	}); err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to create refresh token").SetInternal(err)
+	}
+
+	return &tokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(cfg.AccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// IssueToken issues an access token & a refresh token to clients that can't use the session cookie, e.g. mobile apps. Both are bound to a session, so that signing out or revoking the session revokes them.
+//
+// The 'session' grant exchanges the session cookie of a signed-in user, optionally narrowed to the space-separated scopes. The 'refresh_token' grant rotates a refresh token: it can only be used once, & using it again revokes every token rotated from the same grant along with its session, as the token must have leaked.
+func (h *Handler) IssueToken(c echo.Context) error {
+	var req struct {
+		GrantType    string `json:"grant_type" validate:"required,oneof=session refresh_token"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	cfg := h.Config.Get()
+	if cfg.JWTSigningKey == "" {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "access tokens are not configured")
+	}
+
+	var res *tokenResponse
+	var err error
+	if req.GrantType == "session" {
+		res, err = h.issueSessionTokens(c, cfg, req.Scope)
+	} else {
+		res, err = h.rotateRefreshToken(c, cfg, req.RefreshToken)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Tokens must not be cached, see RFC 6749.
+	c.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: res,
+	})
+}
+
+func (h *Handler) issueSessionTokens(c echo.Context, cfg *config.Config, scope string) (*tokenResponse, error) {
+	user := handlerutil.CurrentUser(c, h.Repo, h.SessionTracker, h.Logger)
+	sessionID, ok := handlerutil.CurrentSessionID(c)
+	if user == nil || !ok {
+		return nil, echo.NewHTTPError(http.StatusUnauthorized, "user not authenticated")
+	}
+
+	scopes := handlerutil.Permissions()
+	if requested := strings.Fields(scope); len(requested) > 0 {
+		for _, s := range requested {
+			if !slices.Contains(scopes, s) {
+				return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, "unknown scope "+s)
+			}
+		}
+		scopes = requested
+	}
+
+	familyID, err := uuid.NewV7()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to generate token family").SetInternal(err)
+	}
+
+	return issueTokens(c.Request().Context(), h.Repo, cfg, user.ID, sessionID, pgtype.UUID{Bytes: familyID, Valid: true}, scopes)
+}
+
+func (h *Handler) rotateRefreshToken(c echo.Context, cfg *config.Config, refreshToken string) (*tokenResponse, error) {
+	if refreshToken == "" {
+		return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, "refresh token is required")
+	}
+	ctx := c.Request().Context()
+	hash := util.HashToken(refreshToken)
+
+	var res *tokenResponse
+	var found bool
+	err := h.inTx(ctx, func(repo repository.Querier) error {
+		token, err := repo.UseRefreshToken(ctx, hash)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+
+		user, err := repo.GetUserBySessionId(ctx, token.SessionID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return echo.NewHTTPError(http.StatusUnauthorized, "session has expired or been revoked")
+			}
+			return err
+		}
+
+		res, err = issueTokens(ctx, repo, cfg, user.ID, token.SessionID, token.FamilyID, token.Scopes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return res, nil
+	}
+
+	token, err := h.Repo.GetRefreshToken(ctx, hash)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, echo.NewHTTPError(http.StatusInternalServerError, "failed to get refresh token").SetInternal(err)
+	}
+	if err == nil && token.UsedAt.Valid && !token.RevokedAt.Valid {
+		// The access tokens issued from the family stay valid until they expire unless their session is revoked too.
+		err = h.inTx(ctx, func(repo repository.Querier) error {
+			if err := repo.RevokeRefreshTokenFamily(ctx, token.FamilyID); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke refresh tokens").SetInternal(err)
+			}
+			if err := repo.RevokeSession(ctx, token.SessionID); err != nil {
+				return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke session").SetInternal(err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		h.Logger.Warn("refresh token was reused, revoked its family & session", slog.String("family_id", token.FamilyID.String()), slog.String("session_id", token.SessionID.String()))
+	}
+
+	return nil, echo.NewHTTPError(http.StatusUnauthorized, "invalid refresh token")
+}
+
+// RevokeToken revokes a refresh token along with every token rotated from the same grant. Unknown tokens are ignored, see RFC 7009.
+func (h *Handler) RevokeToken(c echo.Context) error {
+	var req struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	token, err := h.Repo.GetRefreshToken(c.Request().Context(), util.HashToken(req.RefreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.NoContent(http.StatusOK)
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get refresh token").SetInternal(err)
+	}
+	if err = h.Repo.RevokeRefreshTokenFamily(c.Request().Context(), token.FamilyID); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to revoke refresh tokens").SetInternal(err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
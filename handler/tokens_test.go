@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+)
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.JWTSigningKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+	_, sessionID := repo.addSession("user@example.com")
+	client := newSignedInClient(t, srv, cfg, sessionID)
+
+	var issued struct {
+		Data tokenResponse `json:"data"`
+	}
+	if status := doJSON(t, client, http.MethodPost, srv.URL+"/auth/token", map[string]string{"grant_type": "session"}, &issued); status != http.StatusOK {
+		t.Fatalf("session grant: status = %d, want %d", status, http.StatusOK)
+	}
+
+	anonymous := newTestClient(t)
+	var rotated struct {
+		Data tokenResponse `json:"data"`
+	}
+	refresh := map[string]string{"grant_type": "refresh_token", "refresh_token": issued.Data.RefreshToken}
+	if status := doJSON(t, anonymous, http.MethodPost, srv.URL+"/auth/token", refresh, &rotated); status != http.StatusOK {
+		t.Fatalf("rotation: status = %d, want %d", status, http.StatusOK)
+	}
+
+	// The rotated token is replayed, e.g. by whoever stole it.
+	if status := doJSON(t, anonymous, http.MethodPost, srv.URL+"/auth/token", refresh, nil); status != http.StatusUnauthorized {
+		t.Fatalf("reuse: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	refresh["refresh_token"] = rotated.Data.RefreshToken
+	if status := doJSON(t, anonymous, http.MethodPost, srv.URL+"/auth/token", refresh, nil); status != http.StatusUnauthorized {
+		t.Errorf("refresh token of the family: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+"/users/me", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+rotated.Data.AccessToken)
+	res, err := anonymous.Do(req)
+	if err != nil {
+		t.Fatalf("failed to send request: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("access token of the family: status = %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	if status := doJSON(t, client, http.MethodGet, srv.URL+"/users/me", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("session: status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireAuthPrefersBearerToken(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.JWTSigningKey = base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	_, janeSessionID := repo.addSession("jane@example.com")
+	john, johnSessionID := repo.addSession("john@example.com")
+	johnToken, err := handlerutil.SignAccessToken(cfg, john.ID, johnSessionID, nil)
+	if err != nil {
+		t.Fatalf("failed to sign access token: %v", err)
+	}
+	client := newSignedInClient(t, srv, cfg, janeSessionID)
+
+	getMe := func(token string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/users/me", nil)
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		defer res.Body.Close()
+
+		var out struct {
+			Data struct {
+				Email string `json:"email"`
+			} `json:"data"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&out)
+		return res.StatusCode, out.Data.Email
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+		wantEmail  string
+	}{
+		{name: "session", wantStatus: http.StatusOK, wantEmail: "jane@example.com"},
+		{name: "bearer over session", token: johnToken, wantStatus: http.StatusOK, wantEmail: "john@example.com"},
+		// The session isn't a fallback for an invalid token.
+		{name: "invalid bearer", token: "invalid", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, email := getMe(tt.token)
+			if status != tt.wantStatus || email != tt.wantEmail {
+				t.Errorf("got %d as %q, want %d as %q", status, email, tt.wantStatus, tt.wantEmail)
+			}
+		})
+	}
+}
@@ -0,0 +1,394 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/labstack/echo-contrib/session"
+	"github.com/labstack/echo/v4"
+	"github.com/pquerna/otp/totp"
+	"github.com/rohitxdev/go-api/database/repository"
+	"github.com/rohitxdev/go-api/handler/handlerutil"
+	"github.com/rohitxdev/go-api/util"
+)
+
+const (
+	recoveryCodeCount = 10
+	// Recovery codes are formatted as two groups of this many characters, e.g. 'ABCDE-FGHJK'.
+	recoveryCodeGroupLen = 5
+	// How long a user who has entered their email OTP has to enter their TOTP code.
+	totpChallengeValidity = time.Minute * 5
+	// Codes used by a user, kept until they can't be valid anymore so that they can't be replayed.
+	totpUsedKey = "totp:used:"
+	totpUsedTTL = time.Second * 90
+)
+
+// encryptionKey returns the key that TOTP secrets are encrypted with, or 503 if it isn't configured.
+func (h *Handler) encryptionKey() ([]byte, error) {
+	key := h.Config.Get().EncryptionKey
+	if key == "" {
+		return nil, echo.NewHTTPError(http.StatusServiceUnavailable, "two-factor authentication is not configured")
+	}
+	return []byte(key), nil
+}
+
+// validateTOTP reports whether the code is valid for the credential & hasn't been used before.
+func (h *Handler) validateTOTP(ctx context.Context, cred *repository.TotpCredential, code string) (bool, error) {
+	key, err := h.encryptionKey()
+	if err != nil {
+		return false, err
+	}
+	secret, err := util.DecryptAES(cred.SecretEncrypted, key)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to decrypt TOTP secret").SetInternal(err)
+	}
+
+	if !totp.Validate(code, string(secret)) {
+		return false, nil
+	}
+
+	ok, err := h.Redis.SetNX(ctx, totpUsedKey+cred.UserID.String()+":"+code, 1, totpUsedTTL).Result()
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to check TOTP code reuse").SetInternal(err)
+	}
+	return ok, nil
+}
+
+// totpLockoutSubject is the 'lockout.Guard' subject counting invalid codes of the user, shared by every route that takes one.
+func totpLockoutSubject(userID pgtype.UUID) string {
+	return "totp:" + userID.String()
+}
+
+// checkTOTP validates a code of the signed-in user. Invalid codes count towards the same lockout as 'VerifyTOTPChallenge', so that no route can be used to guess codes faster.
+func (h *Handler) checkTOTP(c echo.Context, cred *repository.TotpCredential, code string) error {
+	subject := totpLockoutSubject(cred.UserID)
+	lockedFor, err := h.OTPGuard.LockedFor(c.Request().Context(), subject)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check TOTP lockout").SetInternal(err)
+	}
+	if lockedFor > 0 {
+		return retryLater(c, lockedFor, "too many invalid codes, try again later")
+	}
+
+	ok, err := h.validateTOTP(c.Request().Context(), cred, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		lockout, err := h.OTPGuard.RecordFailure(c.Request().Context(), subject)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record TOTP failure").SetInternal(err)
+		}
+		if lockout > 0 {
+			return retryLater(c, lockout, "too many invalid codes, try again later")
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid TOTP code")
+	}
+
+	if err = h.OTPGuard.Reset(c.Request().Context(), subject); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to reset TOTP failures").SetInternal(err)
+	}
+	return nil
+}
+
+// newRecoveryCodes returns recovery codes to show to the user once & the hashes that are stored in their place.
+func newRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([][]byte, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		code, err := util.GenerateAlphaNumCode(recoveryCodeGroupLen * 2)
+		if err != nil {
+			return nil, nil, err
+		}
+		hash, err := util.GenerateSecureHash([]byte(code))
+		if err != nil {
+			return nil, nil, err
+		}
+		codes = append(codes, code[:recoveryCodeGroupLen]+"-"+code[recoveryCodeGroupLen:])
+		hashes = append(hashes, hash)
+	}
+
+	return codes, hashes, nil
+}
+
+// useRecoveryCode marks the unused recovery code of the user that matches code as used & reports whether there was one.
+func useRecoveryCode(ctx context.Context, repo repository.Querier, userID pgtype.UUID, code string) (bool, error) {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	recoveryCodes, err := repo.ListUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to list recovery codes").SetInternal(err)
+	}
+
+	for _, rc := range recoveryCodes {
+		if !util.VerifySecureHash([]byte(code), rc.CodeHash) {
+			continue
+		}
+		n, err := repo.UseRecoveryCode(ctx, rc.ID)
+		if err != nil {
+			return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to use recovery code").SetInternal(err)
+		}
+		// Used by a concurrent request.
+		return n > 0, nil
+	}
+
+	return false, nil
+}
+
+// authenticate signs in a user with 'signIn'. Users with two-factor authentication get a partial session instead, which 'VerifyTOTPChallenge' completes. It reports whether that is the case.
+func (h *Handler) authenticate(c echo.Context, userID pgtype.UUID, verifiesEmail bool) (bool, error) {
+	cred, err := h.Repo.GetTotpCredential(c.Request().Context(), userID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to get TOTP credential").SetInternal(err)
+	}
+	if err != nil || !cred.ConfirmedAt.Valid {
+		return false, h.signIn(c, userID, verifiesEmail)
+	}
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to get session").SetInternal(err)
+	}
+
+	sess.Values["totpUserID"] = userID.String()
+	sess.Values["totpExpiresAt"] = time.Now().Add(totpChallengeValidity).Unix()
+	sess.Values["totpVerifiesEmail"] = verifiesEmail
+	if err = sess.Save(c.Request(), c.Response()); err != nil {
+		return false, echo.NewHTTPError(http.StatusInternalServerError, "failed to save session").SetInternal(err)
+	}
+
+	return true, nil
+}
+
+// EnrollTOTP starts setting up an authenticator app. It only takes effect once confirmed with a first code, until then enrolling again replaces the secret.
+func (h *Handler) EnrollTOTP(c echo.Context) error {
+	encKey, err := h.encryptionKey()
+	if err != nil {
+		return err
+	}
+	user := handlerutil.AuthenticatedUser(c)
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      h.Config.Get().AppName,
+		AccountName: user.Email,
+	})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate TOTP secret").SetInternal(err)
+	}
+
+	secretEncrypted, err := util.EncryptAES([]byte(key.Secret()), encKey)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to encrypt TOTP secret").SetInternal(err)
+	}
+
+	if _, err = h.Repo.UpsertTotpCredential(c.Request().Context(), repository.UpsertTotpCredentialParams{
+		UserID:          user.ID,
+		SecretEncrypted: secretEncrypted,
+	}); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusConflict, APIErrorResponse{
+				Error: "two-factor authentication is already enabled",
+			})
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to save TOTP credential").SetInternal(err)
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: echo.Map{
+			"secret":      key.Secret(),
+			"otpauth_uri": key.URL(),
+		},
+	})
+}
+
+// ConfirmTOTP enables two-factor authentication with the first code from the authenticator app & returns recovery codes. They are only ever shown here.
+func (h *Handler) ConfirmTOTP(c echo.Context) error {
+	var req struct {
+		Code string `json:"code" validate:"required,len=6,numeric"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	user := handlerutil.AuthenticatedUser(c)
+
+	cred, err := h.Repo.GetTotpCredential(c.Request().Context(), user.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, APIErrorResponse{
+				Error: "two-factor authentication enrollment not found",
+			})
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get TOTP credential").SetInternal(err)
+	}
+	if cred.ConfirmedAt.Valid {
+		return c.JSON(http.StatusConflict, APIErrorResponse{
+			Error: "two-factor authentication is already enabled",
+		})
+	}
+
+	if err = h.checkTOTP(c, cred, req.Code); err != nil {
+		return err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to generate recovery codes").SetInternal(err)
+	}
+
+	err = h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		n, err := repo.ConfirmTotpCredential(c.Request().Context(), user.ID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to confirm TOTP credential").SetInternal(err)
+		}
+		if n == 0 {
+			return echo.NewHTTPError(http.StatusConflict, "two-factor authentication is already enabled")
+		}
+
+		if err = repo.DeleteRecoveryCodes(c.Request().Context(), user.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete recovery codes").SetInternal(err)
+		}
+		if err = repo.CreateRecoveryCodes(c.Request().Context(), repository.CreateRecoveryCodesParams{
+			UserID:     user.ID,
+			CodeHashes: hashes,
+		}); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to create recovery codes").SetInternal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, APISuccessResponse{
+		Data: echo.Map{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTOTP turns two-factor authentication off, which takes a current code so that a stolen session can't.
+func (h *Handler) DisableTOTP(c echo.Context) error {
+	var req struct {
+		Code string `json:"code" validate:"required,len=6,numeric"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	user := handlerutil.AuthenticatedUser(c)
+
+	cred, err := h.Repo.GetTotpCredential(c.Request().Context(), user.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c.JSON(http.StatusNotFound, APIErrorResponse{
+				Error: "two-factor authentication is not enabled",
+			})
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get TOTP credential").SetInternal(err)
+	}
+
+	// Unconfirmed credentials can be removed without a code, since they never protected anything.
+	if cred.ConfirmedAt.Valid {
+		if err = h.checkTOTP(c, cred, req.Code); err != nil {
+			return err
+		}
+	}
+
+	err = h.inTx(c.Request().Context(), func(repo repository.Querier) error {
+		if _, err := repo.DeleteTotpCredential(c.Request().Context(), user.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete TOTP credential").SetInternal(err)
+		}
+		if err := repo.DeleteRecoveryCodes(c.Request().Context(), user.ID); err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to delete recovery codes").SetInternal(err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// VerifyTOTPChallenge completes the partial session started by 'authenticate' with a code from the authenticator app or a recovery code.
+func (h *Handler) VerifyTOTPChallenge(c echo.Context) error {
+	var req struct {
+		Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+		RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=16"`
+	}
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to get session").SetInternal(err)
+	}
+	rawUserID, _ := sess.Values["totpUserID"].(string)
+	expiresAt, _ := sess.Values["totpExpiresAt"].(int64)
+	verifiesEmail, _ := sess.Values["totpVerifiesEmail"].(bool)
+	id, err := uuid.Parse(rawUserID)
+	if err != nil || time.Now().Unix() >= expiresAt {
+		return echo.NewHTTPError(http.StatusUnauthorized, "two-factor challenge not found or expired")
+	}
+	userID := pgtype.UUID{Bytes: id, Valid: true}
+
+	subject := totpLockoutSubject(userID)
+	lockedFor, err := h.OTPGuard.LockedFor(c.Request().Context(), subject)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to check TOTP lockout").SetInternal(err)
+	}
+	if lockedFor > 0 {
+		return retryLater(c, lockedFor, "too many invalid codes, try again later")
+	}
+
+	var ok bool
+	if req.Code != "" {
+		cred, err := h.Repo.GetTotpCredential(c.Request().Context(), userID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to get TOTP credential").SetInternal(err)
+		}
+		ok, err = h.validateTOTP(c.Request().Context(), cred, req.Code)
+		if err != nil {
+			return err
+		}
+	} else {
+		ok, err = useRecoveryCode(c.Request().Context(), h.Repo, userID, req.RecoveryCode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !ok {
+		lockout, err := h.OTPGuard.RecordFailure(c.Request().Context(), subject)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "failed to record TOTP failure").SetInternal(err)
+		}
+		if lockout > 0 {
+			return retryLater(c, lockout, "too many invalid codes, try again later")
+		}
+		return c.JSON(http.StatusBadRequest, APIErrorResponse{
+			Error: "invalid code",
+		})
+	}
+
+	if err = h.OTPGuard.Reset(c.Request().Context(), subject); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "failed to reset TOTP failures").SetInternal(err)
+	}
+
+	// Saved along with the new session by 'signIn'.
+	delete(sess.Values, "totpUserID")
+	delete(sess.Values, "totpExpiresAt")
+	delete(sess.Values, "totpVerifiesEmail")
+	if err = h.signIn(c, userID, verifiesEmail); err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+// wrongTOTPCode returns a code that isn't valid for the secret right now.
+func wrongTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	code, err := totp.GenerateCode(secret, time.Now())
+	if err != nil {
+		t.Fatalf("failed to generate TOTP code: %v", err)
+	}
+	n, _ := strconv.Atoi(code)
+	return fmt.Sprintf("%06d", (n+500_000)%1_000_000)
+}
+
+func TestTOTPCodesAreLockedOut(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.EncryptionKey = strings.Repeat("k", 32)
+	repo := newFakeRepo()
+	srv, _ := newTestServer(t, cfg, repo)
+
+	enroll := func(t *testing.T, client *http.Client) string {
+		t.Helper()
+
+		var res struct {
+			Data struct {
+				Secret string `json:"secret"`
+			} `json:"data"`
+		}
+		if status := doJSON(t, client, http.MethodPost, srv.URL+"/users/me/totp", nil, &res); status != http.StatusOK {
+			t.Fatalf("enroll: status = %d, want %d", status, http.StatusOK)
+		}
+		return res.Data.Secret
+	}
+
+	// The default guard locks out after 5 consecutive failures.
+	assertLockout := func(t *testing.T, client *http.Client, method string, path string, secret string) {
+		t.Helper()
+
+		for i := range 5 {
+			status := doJSON(t, client, method, srv.URL+path, map[string]string{"code": wrongTOTPCode(t, secret)}, nil)
+			want := http.StatusBadRequest
+			if i == 4 {
+				want = http.StatusTooManyRequests
+			}
+			if status != want {
+				t.Fatalf("attempt %d: status = %d, want %d", i+1, status, want)
+			}
+		}
+
+		code, _ := totp.GenerateCode(secret, time.Now())
+		if status := doJSON(t, client, method, srv.URL+path, map[string]string{"code": code}, nil); status != http.StatusTooManyRequests {
+			t.Errorf("valid code while locked out: status = %d, want %d", status, http.StatusTooManyRequests)
+		}
+	}
+
+	t.Run("confirm", func(t *testing.T) {
+		user, sessionID := repo.addSession("confirm@example.com")
+		client := newSignedInClient(t, srv, cfg, sessionID)
+		secret := enroll(t, client)
+
+		assertLockout(t, client, http.MethodPost, "/users/me/totp/confirm", secret)
+		if cred, _ := repo.GetTotpCredential(t.Context(), user.ID); cred.ConfirmedAt.Valid {
+			t.Error("credential was confirmed while locked out")
+		}
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		user, sessionID := repo.addSession("disable@example.com")
+		client := newSignedInClient(t, srv, cfg, sessionID)
+		secret := enroll(t, client)
+
+		// A failure before a valid code doesn't count towards the lockout anymore.
+		if status := doJSON(t, client, http.MethodPost, srv.URL+"/users/me/totp/confirm", map[string]string{"code": wrongTOTPCode(t, secret)}, nil); status != http.StatusBadRequest {
+			t.Fatalf("confirm with invalid code: status = %d, want %d", status, http.StatusBadRequest)
+		}
+		code, _ := totp.GenerateCode(secret, time.Now())
+		if status := doJSON(t, client, http.MethodPost, srv.URL+"/users/me/totp/confirm", map[string]string{"code": code}, nil); status != http.StatusOK {
+			t.Fatalf("confirm: status = %d, want %d", status, http.StatusOK)
+		}
+
+		assertLockout(t, client, http.MethodDelete, "/users/me/totp", secret)
+		if _, err := repo.GetTotpCredential(t.Context(), user.ID); err != nil {
+			t.Error("credential was deleted while locked out")
+		}
+	})
+}
@@ -3,18 +3,20 @@ package util
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
-	"reflect"
 	"strings"
-	"time"
 
-	"github.com/golang-jwt/jwt"
+	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/argon2"
 )
 
@@ -25,7 +27,7 @@ func padKey(key []byte) []byte {
 		return key
 	}
 	padLen := 16 - padDiff
-	pad := make([]byte, 0, padLen)
+	pad := make([]byte, padLen)
 	for i := range padLen {
 		pad[i] = byte(padLen)
 	}
@@ -42,7 +44,7 @@ func EncryptAES(data []byte, key []byte) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
-	nonce := make([]byte, 0, gcm.NonceSize())
+	nonce := make([]byte, gcm.NonceSize())
 	_, err = rand.Read(nonce)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create nonce: %w", err)
@@ -63,6 +65,9 @@ func DecryptAES(encryptedData []byte, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 	nonceSize := gcm.NonceSize()
+	if len(encryptedData) < nonceSize {
+		return nil, errors.New("failed to decrypt: data is too short")
+	}
 
 	//Get nonce from encrypted data
 	nonce, cipher := encryptedData[:nonceSize], encryptedData[nonceSize:]
@@ -98,7 +103,7 @@ func Base32Hash(text string) string {
 }
 
 func generateSalt(length int) ([]byte, error) {
-	salt := make([]byte, 0, length)
+	salt := make([]byte, length)
 	_, err := rand.Read(salt)
 	if err != nil {
 		return nil, err
@@ -139,94 +144,22 @@ func VerifySecureHash(data []byte, saltedHash []byte) bool {
 	return subtle.ConstantTimeCompare(hash, computed) == 1
 }
 
-func GenerateJWT[T any](data T, expiresIn time.Duration, secret string) (string, error) {
-	t := time.Now()
-	claims := jwt.MapClaims{
-		"data": data,
-		"iat":  t.Unix(),
-		"nbf":  t.Unix(),
-		"exp":  t.Add(expiresIn).Unix(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenStr, err := token.SignedString([]byte(secret))
-	if err != nil {
-		return "", err
-	}
-	return tokenStr, nil
-}
-
-// The type of claims may not be the same as the type given during the token creation. For example, non-float numbers get converted to float when parsed due to how JWT processes data. Be cautious and don't put non-primitive types in claims.
-func verifyJWTUnsafe[T any](tokenStr string, secret string) (T, error) {
-	var data T
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(secret), nil
-	})
-	if err != nil {
-		return data, err
-	}
-	if claims, ok := token.Claims.(jwt.MapClaims); ok {
-		if err = claims.Valid(); err != nil {
-			return data, err
-		}
-		if data, ok = claims["data"].(T); ok {
-			return data, nil
-		}
-		return data, fmt.Errorf("expected data to have type %T, but got %T", data, claims["data"])
-	}
-	return data, errors.New("invalid token")
-}
-
-func convertToType[T any](data any) (T, bool) {
-	var zero T
-	targetType := reflect.TypeOf(zero)
-
-	// Special handling for maps
-	if targetType.Kind() == reflect.Map {
-		dataValue := reflect.ValueOf(data)
-		if dataValue.Kind() == reflect.Map {
-			// Create a new map of the target type
-			newMap := reflect.MakeMap(targetType)
-
-			// Iterate through source map and convert each key-value pair
-			iter := dataValue.MapRange()
-			for iter.Next() {
-				k := iter.Key()
-				v := iter.Value()
-
-				// Ensure correct type conversion for keys and values
-				convertedK := k.Interface()
-				convertedV := v.Interface()
-
-				newMap.SetMapIndex(reflect.ValueOf(convertedK), reflect.ValueOf(convertedV))
-			}
-
-			return newMap.Interface().(T), true
-		}
-	}
-
-	// General type conversion
-	if reflect.TypeOf(data).ConvertibleTo(targetType) {
-		converted := reflect.ValueOf(data).Convert(targetType).Interface()
-		return converted.(T), true
-	}
-
-	return zero, false
+// SignJWT signs the claims with the Ed25519 key (EdDSA).
+func SignJWT(claims jwt.Claims, key ed25519.PrivateKey) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(key)
 }
 
-// Works only for primitive types and maps.
-func VerifyJWT[T any](tokenStr string, secret string) (T, error) {
-	var zero T
-	anyData, err := verifyJWTUnsafe[any](tokenStr, secret)
-	if err != nil {
-		return zero, err
-	}
-	if data, ok := convertToType[T](anyData); ok {
-		return data, nil
-	}
-	return zero, fmt.Errorf("expected data to have type %T, but got %T", zero, anyData)
+// VerifyJWT parses a token signed by 'SignJWT' into the claims, which must have the issuer, the audience & an expiry. Claims are typed, so that parsed values keep the types they were signed with.
+func VerifyJWT(tokenStr string, claims jwt.Claims, key ed25519.PublicKey, issuer string, audience string) error {
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(*jwt.Token) (any, error) {
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	return err
 }
 
 // Exclude similar looking characters like 0, O, I, 1, l
@@ -252,3 +185,30 @@ func GenerateAlphaNumCode(size int) (string, error) {
 
 	return code.String(), nil
 }
+
+// GenerateToken returns a URL-safe random token with 'size' bytes of entropy.
+func GenerateToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// HashToken returns the hex encoded SHA-256 of a token generated by GenerateToken, so that it can be stored & looked up. Low-entropy secrets like OTPs must use GenerateSecureHash instead.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignMessage returns the URL-safe HMAC-SHA256 signature of the message.
+func SignMessage(message []byte, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(message)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyMessage reports whether the signature was made by SignMessage with the same secret, in constant time.
+func VerifyMessage(message []byte, signature string, secret []byte) bool {
+	return hmac.Equal([]byte(SignMessage(message, secret)), []byte(signature))
+}